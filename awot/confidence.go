@@ -0,0 +1,348 @@
+package awot
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"gonum.org/v1/gonum/graph"
+)
+
+// A ConfidenceConfig holds the parameters of the computation of the confidence levels.
+// The confidence of a key is the probability that at least one of the shortest paths to its owner is trustworthy.
+// With few paths, it is computed exactly with the inclusion-exclusion formula, which is exponential in the number of paths.
+// Otherwise it is computed exactly by factoring on the nodes of the paths, within a budget of steps,
+// and estimated by Monte Carlo sampling if the budget is exceeded.
+type ConfidenceConfig struct {
+	MaxPaths        int // maximum number of shortest paths considered for a key, further paths are ignored (lower bound)
+	MaxExactPaths   int // maximum number of paths for the inclusion-exclusion formula
+	FactoringBudget int // maximum number of factoring steps before falling back to sampling
+	Samples         int // number of samples of the Monte Carlo estimation
+}
+
+// DefaultConfidenceConfig returns the default parameters of the computation of the confidence levels
+func DefaultConfidenceConfig() ConfidenceConfig {
+	return ConfidenceConfig{
+		MaxPaths:        256,
+		MaxExactPaths:   10,
+		FactoringBudget: 20000,
+		Samples:         4000,
+	}
+}
+
+// sampleErrorBound returns the bound of the error of a Monte Carlo estimation with given number of samples, at 95% confidence (Hoeffding)
+func sampleErrorBound(samples int) float32 {
+	if samples <= 0 {
+		return 1.0
+	}
+	return float32(math.Sqrt(math.Log(2/0.05) / (2 * float64(samples))))
+}
+
+// A confidenceEngine holds the state of the incremental computation of the confidence levels
+// thread unsafe, protected by the mutex of the ring
+type confidenceEngine struct {
+	metric TrustMetric // metric computing the confidence levels
+	config ConfidenceConfig
+	dirty  map[string]bool // names of the nodes whose confidence must be computed again
+	all    bool            // if set, the confidence of every node must be computed again
+	random *rand.Rand      // source for the Monte Carlo estimations
+}
+
+// newConfidenceEngine creates a confidenceEngine with given parameters, for which every confidence must be computed
+func newConfidenceEngine(config ConfidenceConfig) *confidenceEngine {
+	return &confidenceEngine{
+		metric: NewPathMetric(),
+		config: config,
+		dirty:  make(map[string]bool),
+		all:    true,
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// needsUpdate checks if the confidence of the node with given name must be computed again
+func (engine *confidenceEngine) needsUpdate(name string) bool {
+	return engine.all || engine.dirty[name]
+}
+
+// done marks every confidence as up to date
+func (engine *confidenceEngine) done() {
+	engine.all = false
+	engine.dirty = make(map[string]bool)
+}
+
+// probability computes the probability that at least one of the given paths is trustworthy, and the bound of the error of the result
+// The paths are the shortest paths from the source to a terminal, the terminal is not accounted for.
+func (engine *confidenceEngine) probability(minpaths []Path) (float32, float32) {
+	if len(minpaths) <= engine.config.MaxExactPaths {
+		return probabilityOfMinPaths(minpaths), 0.0
+	}
+
+	// sets of nodes of the paths, without the terminal
+	probabilities := make(map[int64]float64)
+	sets := make([]nodeSet, 0, len(minpaths))
+	for _, p := range minpaths {
+		set := make(nodeSet)
+		for i, n := range p {
+			if i == len(p)-1 {
+				break
+			}
+			set[n.ID()] = true
+			probabilities[n.ID()] = float64(*(n.(Node).probability))
+		}
+		sets = append(sets, set)
+	}
+	sets = absorb(sets)
+
+	budget := engine.config.FactoringBudget
+	if p, ok := factoring(sets, probabilities, &budget); ok {
+		return float32(p), 0.0
+	}
+
+	return engine.sample(sets, probabilities), sampleErrorBound(engine.config.Samples)
+}
+
+// sample estimates the probability that at least one of the given sets of nodes is trustworthy by Monte Carlo sampling
+func (engine *confidenceEngine) sample(sets []nodeSet, probabilities map[int64]float64) float32 {
+	ids := make([]int64, 0, len(probabilities))
+	for id := range probabilities {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	trustworthy := make(map[int64]bool, len(ids))
+	successes := 0
+	for s := 0; s < engine.config.Samples; s++ {
+		for _, id := range ids {
+			trustworthy[id] = engine.random.Float64() < probabilities[id]
+		}
+		for _, set := range sets {
+			if set.all(trustworthy) {
+				successes++
+				break
+			}
+		}
+	}
+	return float32(successes) / float32(engine.config.Samples)
+}
+
+// A nodeSet is a set of node ids
+type nodeSet map[int64]bool
+
+// all checks if every node of the set is true in the given map
+func (set nodeSet) all(values map[int64]bool) bool {
+	for id := range set {
+		if !values[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// contains checks if the set contains every node of other
+func (set nodeSet) contains(other nodeSet) bool {
+	if len(other) > len(set) {
+		return false
+	}
+	for id := range other {
+		if !set[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// absorb removes the sets containing another set : they do not change the probability of the union
+func absorb(sets []nodeSet) []nodeSet {
+	sort.Slice(sets, func(i, j int) bool { return len(sets[i]) < len(sets[j]) })
+	kept := make([]nodeSet, 0, len(sets))
+	for _, set := range sets {
+		absorbed := false
+		for _, k := range kept {
+			if set.contains(k) {
+				absorbed = true
+				break
+			}
+		}
+		if !absorbed {
+			kept = append(kept, set)
+		}
+	}
+	return kept
+}
+
+// factoring computes exactly the probability that at least one of the given sets of nodes is trustworthy,
+// by conditioning on the state of the node appearing in most sets : P = p * P(node trustworthy) + (1-p) * P(node not trustworthy)
+// Returns false if the budget of steps is exceeded.
+func factoring(sets []nodeSet, probabilities map[int64]float64, budget *int) (float64, bool) {
+	*budget--
+	if *budget < 0 {
+		return 0, false
+	}
+	if len(sets) == 0 {
+		return 0, true
+	}
+
+	// pivot : node appearing in most sets
+	occurrences := make(map[int64]int)
+	for _, set := range sets {
+		if len(set) == 0 {
+			// a set of trustworthy nodes only
+			return 1, true
+		}
+		for id := range set {
+			occurrences[id]++
+		}
+	}
+	pivot := int64(-1)
+	for id, occ := range occurrences {
+		if pivot < 0 || occ > occurrences[pivot] || (occ == occurrences[pivot] && id < pivot) {
+			pivot = id
+		}
+	}
+
+	// the pivot is trustworthy : remove it from the sets
+	up := make([]nodeSet, 0, len(sets))
+	// the pivot is not trustworthy : remove the sets containing it
+	down := make([]nodeSet, 0, len(sets))
+	for _, set := range sets {
+		if set[pivot] {
+			reduced := make(nodeSet, len(set)-1)
+			for id := range set {
+				if id != pivot {
+					reduced[id] = true
+				}
+			}
+			up = append(up, reduced)
+		} else {
+			up = append(up, set)
+			down = append(down, set)
+		}
+	}
+
+	pUp, ok := factoring(absorb(up), probabilities, budget)
+	if !ok {
+		return 0, false
+	}
+	pDown, ok := factoring(down, probabilities, budget)
+	if !ok {
+		return 0, false
+	}
+
+	p := probabilities[pivot]
+	return p*pUp + (1-p)*pDown, true
+}
+
+////////// Key Ring API
+
+// SetConfidenceConfig sets the parameters of the computation of the confidence levels, and computes them again
+func (ring *KeyRing) SetConfidenceConfig(config ConfidenceConfig) {
+	ring.mutex.Lock()
+	ring.confidence.config = config
+	ring.confidence.all = true
+	ring.mutex.Unlock()
+	ring.updateConfidence()
+}
+
+////////// Implementation
+
+// updateAllConfidence computes again the confidence of every key, e.g. after a change of the rules of the computation
+func (ring *KeyRing) updateAllConfidence() {
+	ring.mutex.Lock()
+	ring.confidence.all = true
+	ring.mutex.Unlock()
+	ring.updateConfidence()
+}
+
+// markDownstream marks the given node and every node reachable from it as needing a new computation of their confidence :
+// only their shortest paths from the source may have changed
+// thread unsafe
+func (ring *KeyRing) markDownstream(n Node) {
+	if ring.confidence.all {
+		return
+	}
+	queue := []graph.Node{n}
+	visited := map[int64]bool{n.ID(): true}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		ring.confidence.dirty[current.(Node).name] = true
+		for _, next := range ring.graph.From(current) {
+			if !visited[next.ID()] {
+				visited[next.ID()] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+}
+
+// A shortestPaths is the result of a breadth first search from the source of the ring
+type shortestPaths struct {
+	source graph.Node
+	dist   map[int64]int          // node id -> distance from the source
+	preds  map[int64][]graph.Node // node id -> predecessors on the shortest paths from the source
+}
+
+// searchFromSource computes the shortest paths from the source to every node
+// thread unsafe
+func (ring *KeyRing) searchFromSource() shortestPaths {
+	source := ring.graph.Node(ring.ids[ring.source].id)
+	sp := shortestPaths{
+		source: source,
+		dist:   map[int64]int{source.ID(): 0},
+		preds:  make(map[int64][]graph.Node),
+	}
+
+	queue := []graph.Node{source}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		next := ring.graph.From(current)
+		sort.Slice(next, func(i, j int) bool { return next[i].ID() < next[j].ID() })
+		for _, n := range next {
+			d, seen := sp.dist[n.ID()]
+			if !seen {
+				sp.dist[n.ID()] = sp.dist[current.ID()] + 1
+				queue = append(queue, n)
+			}
+			if !seen || d == sp.dist[current.ID()]+1 {
+				sp.preds[n.ID()] = append(sp.preds[n.ID()], current)
+			}
+		}
+	}
+	return sp
+}
+
+// distance returns the distance from the source to the given node, +Inf if it is not reachable
+func (sp shortestPaths) distance(n graph.Node) float64 {
+	d, ok := sp.dist[n.ID()]
+	if !ok {
+		return math.Inf(1)
+	}
+	return float64(d)
+}
+
+// allTo returns at most limit shortest paths from the source to the given node
+func (sp shortestPaths) allTo(target graph.Node, limit int) [][]graph.Node {
+	if _, ok := sp.dist[target.ID()]; !ok {
+		return nil
+	}
+	paths := make([][]graph.Node, 0)
+	// backward depth first enumeration
+	var walk func(n graph.Node, suffix []graph.Node)
+	walk = func(n graph.Node, suffix []graph.Node) {
+		if len(paths) >= limit {
+			return
+		}
+		suffix = append([]graph.Node{n}, suffix...)
+		if n.ID() == sp.source.ID() {
+			paths = append(paths, suffix)
+			return
+		}
+		for _, pred := range sp.preds[n.ID()] {
+			walk(pred, suffix)
+		}
+	}
+	walk(target, nil)
+	return paths
+}
@@ -0,0 +1,114 @@
+// Tests for the computation of the confidence levels
+package awot
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"math"
+	mrand "math/rand"
+	"testing"
+	"time"
+)
+
+// randomPaths creates n random paths of given maximum length on a set of nodes with random probabilities, ending with a common terminal
+func randomPaths(r *mrand.Rand, n, nodes, length int) []Path {
+	ns := make([]Node, nodes)
+	for i := range ns {
+		p := float32(r.Float64())
+		ns[i] = Node{name: fmt.Sprint(i), id: int64(i), probability: &p}
+	}
+	pt := float32(1.0)
+	terminal := Node{name: "terminal", id: int64(nodes), probability: &pt}
+
+	paths := make([]Path, n)
+	for i := range paths {
+		l := 1 + r.Intn(length)
+		for _, j := range r.Perm(nodes)[:l] {
+			paths[i] = append(paths[i], ns[j])
+		}
+		paths[i] = append(paths[i], terminal)
+	}
+	return paths
+}
+
+// TestConfidenceModes tests that the factoring and sampling modes agree with the inclusion-exclusion formula
+func TestConfidenceModes(t *testing.T) {
+	r := mrand.New(mrand.NewSource(42))
+	for i := 0; i < 20; i++ {
+		paths := randomPaths(r, 1+r.Intn(8), 10, 4)
+		exact := probabilityOfMinPaths(paths)
+
+		factoringEngine := newConfidenceEngine(ConfidenceConfig{MaxPaths: 256, MaxExactPaths: 0, FactoringBudget: 100000, Samples: 0})
+		p, bound := factoringEngine.probability(paths)
+		if bound != 0 || math.Abs(float64(p-exact)) > 1e-4 {
+			t.Fatalf("factoring should be exact : expected %v, got %v (error %v)", exact, p, bound)
+		}
+
+		samplingEngine := newConfidenceEngine(ConfidenceConfig{MaxPaths: 256, MaxExactPaths: 0, FactoringBudget: 0, Samples: 20000})
+		samplingEngine.random = mrand.New(mrand.NewSource(int64(i)))
+		p, bound = samplingEngine.probability(paths)
+		if bound == 0 || math.Abs(float64(p-exact)) > 2*float64(bound) {
+			t.Fatalf("sampling should be close to the exact value : expected %v, got %v (error %v)", exact, p, bound)
+		}
+	}
+}
+
+// TestConfidenceManyPaths tests that the confidence of a key with many signers is computed in reasonable time
+func TestConfidenceManyPaths(t *testing.T) {
+	r := mrand.New(mrand.NewSource(7))
+	paths := randomPaths(r, 200, 60, 3)
+
+	engine := newConfidenceEngine(DefaultConfidenceConfig())
+	start := time.Now()
+	p, bound := engine.probability(paths)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("computation with 200 paths took %v", elapsed)
+	}
+	if p < 0 || p > 1 || bound < 0 || bound > 0.1 {
+		t.Fatalf("invalid confidence %v with error %v", p, bound)
+	}
+}
+
+// TestIncrementalConfidence tests that only the nodes downstream of a change are computed again
+func TestIncrementalConfidence(t *testing.T) {
+	keys := make(map[string]*rsa.PrivateKey)
+	for _, name := range []string{"source", "A", "B", "C", "D"} {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			t.Fatalf("could not generate rsa key: %v", err)
+		}
+		keys[name] = key
+	}
+
+	// source -> A -> B -> C, source -> D
+	trusted := []TrustedKeyRecord{
+		{KeyRecord: KeyRecord{Owner: "A", KeyPub: keys["A"].PublicKey}, Confidence: 1.0},
+		{KeyRecord: KeyRecord{Owner: "D", KeyPub: keys["D"].PublicKey}, Confidence: 1.0},
+	}
+	ring := NewKeyRing("source", keys["source"].PublicKey, trusted, 0.0)
+	ring.Add(KeyRecord{Owner: "B", KeyPub: keys["B"].PublicKey}, "A", 1.0)
+	ring.Add(KeyRecord{Owner: "C", KeyPub: keys["C"].PublicKey}, "B", 1.0)
+
+	if ring.confidence.all || len(ring.confidence.dirty) != 0 {
+		t.Fatalf("every confidence should be up to date after an update")
+	}
+
+	ring.mutex.Lock()
+	ring.markDownstream(*ring.ids["B"])
+	dirty := ring.confidence.dirty
+	ring.mutex.Unlock()
+	if !dirty["B"] || !dirty["C"] || dirty["A"] || dirty["D"] || dirty["source"] {
+		t.Fatalf("only B and C should be computed again, got %v", dirty)
+	}
+	ring.updateConfidence()
+
+	// the confidence of C depends on the path through B
+	before, _ := ring.GetRecord("C")
+	ring.addNode("B", 0.1)
+	ring.updateConfidence()
+	after, _ := ring.GetRecord("C")
+	if after.Confidence >= before.Confidence {
+		t.Fatalf("confidence of C should decrease with the probability of B : was %v, got %v", before.Confidence, after.Confidence)
+	}
+}
@@ -0,0 +1,207 @@
+package awot
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// sasPrefix separates the hash of a short authentication string from other hashes of fingerprints
+const sasPrefix = "AWOTSAS"
+
+// sasWords is the number of words of a short authentication string (64 bits).
+// Without a commitment to the keys before they are revealed, a man in the middle can try many keys to match the string,
+// so it must be long enough to make this search infeasible.
+const sasWords = 8
+
+// fingerprintWords has one word for each byte value, for reading fingerprints aloud
+var fingerprintWords = [256]string{
+	"acid", "acorn", "actor", "alley", "amber", "apple", "apron", "armor",
+	"arrow", "atlas", "attic", "autumn", "bacon", "badge", "bagel", "baker",
+	"bamboo", "banjo", "barrel", "basil", "basket", "beach", "beaver", "bench",
+	"berry", "bison", "blade", "blanket", "blossom", "bottle", "boxer", "bread",
+	"brick", "bridge", "bronze", "broom", "bubble", "bucket", "buffalo", "butter",
+	"button", "cabin", "cactus", "camel", "camera", "candle", "canoe", "canvas",
+	"carbon", "carpet", "carrot", "castle", "cedar", "cello", "chalk", "cherry",
+	"chimney", "cider", "circus", "citrus", "clover", "cobalt", "cocoa", "comet",
+	"copper", "coral", "cotton", "cougar", "crayon", "cricket", "crystal", "cuckoo",
+	"daisy", "dancer", "desert", "diamond", "dolphin", "domino", "donkey", "dragon",
+	"drawer", "dune", "eagle", "easel", "echo", "eclipse", "elbow", "ember",
+	"emerald", "eraser", "fabric", "falcon", "feather", "fennel", "ferry", "fiddle",
+	"fossil", "fox", "frost", "fudge", "funnel", "garden", "garlic", "gazelle",
+	"geyser", "ginger", "giraffe", "glacier", "glove", "goblet", "gondola", "gorilla",
+	"granite", "grape", "gravel", "guitar", "hammer", "harbor", "harvest", "hazel",
+	"helmet", "hermit", "hickory", "honey", "hornet", "igloo", "iguana", "inkwell",
+	"island", "ivory", "jacket", "jaguar", "jasmine", "jelly", "jigsaw", "jockey",
+	"jungle", "juniper", "kayak", "kernel", "kettle", "kitten", "koala", "ladder",
+	"lagoon", "lantern", "lemon", "leopard", "lettuce", "lilac", "linen", "lizard",
+	"lobster", "locket", "lotus", "lumber", "magnet", "mango", "maple", "marble",
+	"meadow", "melon", "mirror", "mitten", "monkey", "muffin", "mustard", "napkin",
+	"nectar", "needle", "nickel", "noodle", "nutmeg", "oasis", "ocean", "olive",
+	"onion", "orange", "orbit", "orchid", "otter", "oyster", "paddle", "palace",
+	"panda", "papaya", "parrot", "pebble", "pelican", "pepper", "piano", "pickle",
+	"pigeon", "pillow", "pirate", "planet", "plum", "pocket", "pollen", "potato",
+	"pretzel", "pumpkin", "puzzle", "quartz", "quilt", "rabbit", "raccoon", "radar",
+	"radish", "raisin", "raven", "ribbon", "robin", "rocket", "rooster", "ruby",
+	"saddle", "salmon", "sandal", "saturn", "scarf", "sequoia", "shadow", "shovel",
+	"silver", "sparrow", "spider", "spinach", "sunset", "swan", "tango", "teapot",
+	"temple", "thimble", "thunder", "tiger", "timber", "tomato", "trumpet", "tulip",
+	"tunnel", "turnip", "tuxedo", "unicorn", "valley", "velvet", "violin", "volcano",
+	"walnut", "walrus", "wizard", "yacht", "yogurt", "zebra", "zephyr", "zipper",
+}
+
+// FingerprintBytes returns the SHA-256 hash of the DER encoded SubjectPublicKeyInfo of the given public key,
+// nil if the key cannot be encoded
+func FingerprintBytes(pub crypto.PublicKey) []byte {
+	if k, ok := rsaKey(pub); ok && k.N == nil {
+		return nil
+	}
+	der, err := x509.MarshalPKIXPublicKey(normalizeKey(pub))
+	if err != nil {
+		return nil
+	}
+	sum := sha256.Sum256(der)
+	return sum[:]
+}
+
+// Fingerprint returns the hex formatted fingerprint of the given public key, bytes being separated by ":"
+// This is the form stored in the manual trust decisions.
+func Fingerprint(pub crypto.PublicKey) string {
+	return formatFingerprint(FingerprintBytes(pub))
+}
+
+// FingerprintGroups returns the fingerprint of the given public key as groups of 4 upper case hex digits
+func FingerprintGroups(pub crypto.PublicKey) string {
+	s := strings.ToUpper(hex.EncodeToString(FingerprintBytes(pub)))
+	groups := make([]string, 0, len(s)/4)
+	for i := 0; i+4 <= len(s); i += 4 {
+		groups = append(groups, s[i:i+4])
+	}
+	return strings.Join(groups, " ")
+}
+
+// FingerprintWordList returns the fingerprint of the given public key as one word per byte
+func FingerprintWordList(pub crypto.PublicKey) string {
+	return toWords(FingerprintBytes(pub))
+}
+
+// ParseFingerprint parses a fingerprint given in hex (with or without separators) or as a word list,
+// and returns it in the form returned by Fingerprint
+func ParseFingerprint(s string) (string, error) {
+	fp, err := parseFingerprintBytes(s)
+	if err != nil {
+		return "", err
+	}
+	if len(fp) != sha256.Size && len(fp) != md5.Size {
+		return "", errors.New("fingerprint has an invalid length")
+	}
+	return formatFingerprint(fp), nil
+}
+
+// FingerprintMatches checks that the given fingerprint, in any form accepted by ParseFingerprint, is the one of the given key
+// Fingerprints of the MD5 format used before are accepted too.
+func FingerprintMatches(fingerprint string, pub crypto.PublicKey) bool {
+	fp, err := parseFingerprintBytes(fingerprint)
+	if err != nil {
+		return false
+	}
+	switch len(fp) {
+	case sha256.Size:
+		return bytes.Equal(fp, FingerprintBytes(pub))
+	case md5.Size:
+		return bytes.Equal(fp, legacyFingerprintBytes(pub))
+	}
+	return false
+}
+
+// isLegacyFingerprint checks if the given fingerprint is of the MD5 format used before
+func isLegacyFingerprint(fingerprint string) bool {
+	fp, err := parseFingerprintBytes(fingerprint)
+	return err == nil && len(fp) == md5.Size
+}
+
+// ShortAuthString returns the short authentication string of the given pair of keys, the same for both orders of the keys.
+// Two users compare out of band the string computed from their own key and the key they received for the other one :
+// if any of the two keys was substituted, the strings differ.
+func ShortAuthString(a, b crypto.PublicKey) string {
+	fa, fb := FingerprintBytes(a), FingerprintBytes(b)
+	if bytes.Compare(fa, fb) > 0 {
+		fa, fb = fb, fa
+	}
+	h := sha256.New()
+	h.Write([]byte(sasPrefix))
+	h.Write(fa)
+	h.Write(fb)
+	return toWords(h.Sum(nil)[:sasWords])
+}
+
+// legacyFingerprintBytes returns the MD5 fingerprint used before, over the exponent and modulus of RSA keys
+func legacyFingerprintBytes(pub crypto.PublicKey) []byte {
+	h := md5.New()
+	if k, ok := rsaKey(pub); ok {
+		binary.Write(h, binary.LittleEndian, k.E)
+		h.Write(k.N.Bytes())
+	} else if k, ok := ed25519Key(pub); ok {
+		h.Write(k)
+	}
+	return h.Sum(nil)
+}
+
+// formatFingerprint formats the given fingerprint in hex, bytes being separated by ":"
+func formatFingerprint(fp []byte) string {
+	s := hex.EncodeToString(fp)
+
+	// add ":" every two char
+	for i := 2; i < len(s); i += 3 {
+		s = s[:i] + ":" + s[i:]
+	}
+	return s
+}
+
+// parseFingerprintBytes decodes a fingerprint given in hex or as a word list
+func parseFingerprintBytes(s string) ([]byte, error) {
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) > 0 && wordIndex(fields[0]) >= 0 {
+		fp := make([]byte, len(fields))
+		for i, w := range fields {
+			index := wordIndex(w)
+			if index < 0 {
+				return nil, errors.New("unknown fingerprint word " + w)
+			}
+			fp[i] = byte(index)
+		}
+		return fp, nil
+	}
+
+	hexa := strings.NewReplacer(":", "", " ", "", "-", "").Replace(strings.ToLower(s))
+	fp, err := hex.DecodeString(hexa)
+	if err != nil {
+		return nil, errors.New("fingerprint is neither hex nor words")
+	}
+	return fp, nil
+}
+
+// toWords encodes the given bytes with one word per byte
+func toWords(data []byte) string {
+	words := make([]string, len(data))
+	for i, b := range data {
+		words[i] = fingerprintWords[b]
+	}
+	return strings.Join(words, " ")
+}
+
+// wordIndex returns the byte encoded by the given word, -1 if it is not a fingerprint word
+func wordIndex(word string) int {
+	for i, w := range fingerprintWords {
+		if w == word {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,126 @@
+// Tests for the fingerprints and the verification of keys
+package awot
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"strings"
+	"testing"
+)
+
+// TestFingerprintFormats tests that every form of a fingerprint is parsed back to the same fingerprint
+func TestFingerprintFormats(t *testing.T) {
+	for _, alg := range []KeyAlgorithm{KeyAlgorithmRSA, KeyAlgorithmEd25519} {
+		t.Run(string(alg), func(t *testing.T) {
+			priK, err := GenerateKey(alg, 1024)
+			if err != nil {
+				t.Fatalf("could not generate key: %v", err)
+			}
+			key := priK.Public()
+
+			der, err := x509.MarshalPKIXPublicKey(key)
+			if err != nil {
+				t.Fatalf("could not marshal key: %v", err)
+			}
+			sum := sha256.Sum256(der)
+			if fp := Fingerprint(key); fp != formatFingerprint(sum[:]) {
+				t.Fatalf("fingerprint should be the SHA-256 of the SPKI, got %v", fp)
+			}
+
+			for _, form := range []string{Fingerprint(key), FingerprintGroups(key), FingerprintWordList(key)} {
+				fp, err := ParseFingerprint(form)
+				if err != nil || fp != Fingerprint(key) {
+					t.Fatalf("could not parse fingerprint %q: %v", form, err)
+				}
+				if !FingerprintMatches(form, key) {
+					t.Fatalf("fingerprint %q should match its key", form)
+				}
+			}
+		})
+	}
+
+	if _, err := ParseFingerprint("not a fingerprint"); err == nil {
+		t.Fatalf("parsing an invalid fingerprint should fail")
+	}
+	if _, err := ParseFingerprint("ab:cd"); err == nil {
+		t.Fatalf("parsing a fingerprint of invalid length should fail")
+	}
+}
+
+// TestLegacyFingerprint tests that manual decisions taken on MD5 fingerprints are migrated
+func TestLegacyFingerprint(t *testing.T) {
+	sourceK, _ := GenerateKey(KeyAlgorithmEd25519, 0)
+	bK, _ := GenerateKey(KeyAlgorithmEd25519, 0)
+
+	legacy := formatFingerprint(legacyFingerprintBytes(bK.Public()))
+	if !FingerprintMatches(legacy, bK.Public()) {
+		t.Fatalf("legacy fingerprint should match its key")
+	}
+
+	ring := NewKeyRing("source", sourceK.Public(), nil, 0.0)
+	ring.ImportKey(KeyRecord{Owner: "B", KeyPub: bK.Public()}, 0.5)
+	decisions := ring.TrustDecisions()
+	decisions.Pinned["B"] = legacy
+	ring.RestoreTrustDecisions(decisions)
+
+	if _, ok := ring.GetKey("B"); !ok {
+		t.Fatalf("key of B matching the legacy pinned fingerprint should be returned")
+	}
+	if fp := ring.TrustDecisions().Pinned["B"]; fp != Fingerprint(bK.Public()) {
+		t.Fatalf("legacy pinned fingerprint should be migrated, got %v", fp)
+	}
+}
+
+// TestKeyVerification tests the comparison of short authentication strings and the promotion to full trust
+func TestKeyVerification(t *testing.T) {
+	aK, _ := GenerateKey(KeyAlgorithmEd25519, 0)
+	bK, _ := GenerateKey(KeyAlgorithmEd25519, 0)
+	mK, _ := GenerateKey(KeyAlgorithmEd25519, 0)
+
+	if ShortAuthString(aK.Public(), bK.Public()) != ShortAuthString(bK.Public(), aK.Public()) {
+		t.Fatalf("short authentication string should not depend on the order of the keys")
+	}
+	if words := strings.Fields(ShortAuthString(aK.Public(), bK.Public())); len(words) != sasWords {
+		t.Fatalf("short authentication string should have %d words, got %v", sasWords, words)
+	}
+
+	// A received the key of B, while B received the key of a man in the middle instead of the one of A
+	ringA := NewKeyRing("A", aK.Public(), nil, 0.0)
+	ringA.ImportKey(KeyRecord{Owner: "B", KeyPub: bK.Public()}, 0.5)
+	ringB := NewKeyRing("B", bK.Public(), nil, 0.0)
+	ringB.ImportKey(KeyRecord{Owner: "A", KeyPub: mK.Public()}, 0.5)
+
+	verifA, err := ringA.Verification("B")
+	if err != nil {
+		t.Fatalf("could not get verification of B: %v", err)
+	}
+	verifB, err := ringB.Verification("A")
+	if err != nil {
+		t.Fatalf("could not get verification of A: %v", err)
+	}
+	if verifA.SAS == verifB.SAS {
+		t.Fatalf("short authentication strings should differ when a key is substituted")
+	}
+	if err = ringB.ConfirmVerification("A", verifA.SAS); err == nil {
+		t.Fatalf("confirming the string of another key should fail")
+	}
+
+	// without substitution, the strings match and the key is promoted
+	ringB.ImportKey(KeyRecord{Owner: "A", KeyPub: aK.Public()}, 0.5)
+	verifB, _ = ringB.Verification("A")
+	if verifA.SAS != verifB.SAS {
+		t.Fatalf("short authentication strings should match")
+	}
+	if err = ringB.ConfirmVerification("A", verifA.SAS); err != nil {
+		t.Fatalf("could not confirm verification: %v", err)
+	}
+	if rec, _ := ringB.GetRecord("A"); rec.Confidence != 1.0 {
+		t.Fatalf("verified key should be fully trusted, got %v", rec.Confidence)
+	}
+	if fp := ringB.TrustDecisions().Pinned["A"]; fp != Fingerprint(aK.Public()) {
+		t.Fatalf("verified key should be pinned")
+	}
+	if _, err = ringB.Verification("unknown"); err == nil {
+		t.Fatalf("verifying an unknown peer should fail")
+	}
+}
@@ -0,0 +1,255 @@
+package awot
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strconv"
+)
+
+// A KeyAlgorithm is the algorithm of the keys of a peer.
+// The keys of the ring are crypto.PublicKey values : *rsa.PublicKey (or rsa.PublicKey) or ed25519.PublicKey,
+// and the private keys are crypto.Signer values : *rsa.PrivateKey or ed25519.PrivateKey.
+type KeyAlgorithm string
+
+const (
+	// KeyAlgorithmRSA signs with RSA-PSS and encrypts with RSA-OAEP, both with SHA-256
+	KeyAlgorithmRSA KeyAlgorithm = "rsa"
+	// KeyAlgorithmEd25519 signs with Ed25519 and encrypts with X25519, using the Montgomery form of the Ed25519 key
+	KeyAlgorithmEd25519 KeyAlgorithm = "ed25519"
+)
+
+func init() {
+	// the keys are stored in interfaces of persisted records (e.g. TrustDecisions)
+	gob.Register(&rsa.PublicKey{})
+	gob.Register(ed25519.PublicKey{})
+}
+
+// ParseKeyAlgorithm returns the KeyAlgorithm with given name : "rsa" or "ed25519"
+func ParseKeyAlgorithm(name string) (KeyAlgorithm, error) {
+	switch KeyAlgorithm(name) {
+	case KeyAlgorithmRSA, KeyAlgorithmEd25519:
+		return KeyAlgorithm(name), nil
+	}
+	return "", errors.New("unknown key algorithm " + name)
+}
+
+// Algorithm returns the algorithm of the given public key
+func Algorithm(key crypto.PublicKey) (KeyAlgorithm, error) {
+	if _, ok := rsaKey(key); ok {
+		return KeyAlgorithmRSA, nil
+	}
+	if _, ok := ed25519Key(key); ok {
+		return KeyAlgorithmEd25519, nil
+	}
+	return "", errors.New("unsupported public key type")
+}
+
+// GenerateKey generates a private key of given algorithm, of given size in bits for RSA
+func GenerateKey(algorithm KeyAlgorithm, rsaBits int) (crypto.Signer, error) {
+	switch algorithm {
+	case KeyAlgorithmRSA:
+		return rsa.GenerateKey(rand.Reader, rsaBits)
+	case KeyAlgorithmEd25519:
+		_, priK, err := ed25519.GenerateKey(rand.Reader)
+		return priK, err
+	}
+	return nil, errors.New("unknown key algorithm " + string(algorithm))
+}
+
+// Sign signs the given SHA-256 hash with the given private key
+func Sign(priK crypto.Signer, hashed []byte) ([]byte, error) {
+	switch k := priK.(type) {
+	case *rsa.PrivateKey:
+		return rsa.SignPSS(rand.Reader, k, crypto.SHA256, hashed, nil)
+	case ed25519.PrivateKey:
+		return ed25519.Sign(k, hashed), nil
+	}
+	return nil, errors.New("unsupported private key type")
+}
+
+// VerifySignature verifies that the given signature of the SHA-256 hash was made with the private key of the given public key
+// Returns nil if valid, an error otherwise
+func VerifySignature(key crypto.PublicKey, hashed, signature []byte) error {
+	if k, ok := rsaKey(key); ok {
+		return rsa.VerifyPSS(k, crypto.SHA256, hashed, signature, nil)
+	}
+	if k, ok := ed25519Key(key); ok {
+		if !ed25519.Verify(k, hashed, signature) {
+			return errors.New("ed25519 verification error")
+		}
+		return nil
+	}
+	return errors.New("unsupported public key type")
+}
+
+// Encrypt encrypts the given plaintext for the owner of the given public key
+// RSA keys use RSA-OAEP, Ed25519 keys an ephemeral X25519 key exchange followed by AES-GCM :
+// the ciphertext is the ephemeral public key, the nonce and the sealed plaintext.
+func Encrypt(key crypto.PublicKey, plaintext []byte) ([]byte, error) {
+	if k, ok := rsaKey(key); ok {
+		return rsa.EncryptOAEP(sha256.New(), rand.Reader, k, plaintext, nil)
+	}
+	k, ok := ed25519Key(key)
+	if !ok {
+		return nil, errors.New("unsupported public key type")
+	}
+
+	recipient, err := x25519PublicKey(k)
+	if err != nil {
+		return nil, err
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := sealingKey(shared, ephemeral.PublicKey().Bytes(), recipient.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	ciphertext := append(ephemeral.PublicKey().Bytes(), nonce...)
+	return aead.Seal(ciphertext, nonce, plaintext, nil), nil
+}
+
+// Decrypt decrypts a ciphertext produced by Encrypt with the public key of the given private key
+func Decrypt(priK crypto.Signer, ciphertext []byte) ([]byte, error) {
+	switch k := priK.(type) {
+	case *rsa.PrivateKey:
+		return rsa.DecryptOAEP(sha256.New(), rand.Reader, k, ciphertext, nil)
+	case ed25519.PrivateKey:
+		own, err := x25519PrivateKey(k)
+		if err != nil {
+			return nil, err
+		}
+		size := len(own.PublicKey().Bytes())
+		if len(ciphertext) < size {
+			return nil, errors.New("ciphertext too short")
+		}
+		ephemeral, err := ecdh.X25519().NewPublicKey(ciphertext[:size])
+		if err != nil {
+			return nil, err
+		}
+		shared, err := own.ECDH(ephemeral)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := sealingKey(shared, ephemeral.Bytes(), own.PublicKey().Bytes())
+		if err != nil {
+			return nil, err
+		}
+		if len(ciphertext) < size+aead.NonceSize() {
+			return nil, errors.New("ciphertext too short")
+		}
+		nonce := ciphertext[size : size+aead.NonceSize()]
+		return aead.Open(nil, nonce, ciphertext[size+aead.NonceSize():], nil)
+	}
+	return nil, errors.New("unsupported private key type")
+}
+
+////////// Implementation
+
+// rsaKey returns the given key as an RSA public key, if it is one
+func rsaKey(key crypto.PublicKey) (*rsa.PublicKey, bool) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return k, k != nil
+	case rsa.PublicKey:
+		return &k, true
+	}
+	return nil, false
+}
+
+// ed25519Key returns the given key as an Ed25519 public key, if it is a well formed one
+func ed25519Key(key crypto.PublicKey) (ed25519.PublicKey, bool) {
+	k, ok := key.(ed25519.PublicKey)
+	return k, ok && len(k) == ed25519.PublicKeySize
+}
+
+// normalizeKey returns the given key in the form kept by the ring : *rsa.PublicKey or ed25519.PublicKey
+func normalizeKey(key crypto.PublicKey) crypto.PublicKey {
+	if k, ok := rsaKey(key); ok {
+		return k
+	}
+	return key
+}
+
+// keyID returns a string uniquely identifying the given public key
+func keyID(key crypto.PublicKey) string {
+	if k, ok := rsaKey(key); ok {
+		return k.N.String() + "-" + strconv.Itoa(k.E)
+	}
+	if k, ok := ed25519Key(key); ok {
+		return string(KeyAlgorithmEd25519) + "-" + hex.EncodeToString(k)
+	}
+	return ""
+}
+
+// fieldPrime is the prime 2^255 - 19 of the field of Curve25519 and Ed25519
+var fieldPrime = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+// x25519PublicKey converts the given Ed25519 public key to its X25519 form, u = (1 + y) / (1 - y)
+func x25519PublicKey(key ed25519.PublicKey) (*ecdh.PublicKey, error) {
+	// y is encoded in little endian, the top bit being the sign of x
+	encoded := make([]byte, len(key))
+	for i, b := range key {
+		encoded[len(key)-1-i] = b
+	}
+	encoded[0] &= 0x7f
+	y := new(big.Int).SetBytes(encoded)
+
+	denominator := new(big.Int).Sub(big.NewInt(1), y)
+	denominator.Mod(denominator, fieldPrime)
+	if denominator.Sign() == 0 {
+		return nil, errors.New("ed25519 key has no x25519 form")
+	}
+	u := new(big.Int).Add(big.NewInt(1), y)
+	u.Mul(u, denominator.ModInverse(denominator, fieldPrime))
+	u.Mod(u, fieldPrime)
+
+	bytes := make([]byte, 32)
+	u.FillBytes(bytes)
+	for i, j := 0, len(bytes)-1; i < j; i, j = i+1, j-1 {
+		bytes[i], bytes[j] = bytes[j], bytes[i]
+	}
+	return ecdh.X25519().NewPublicKey(bytes)
+}
+
+// x25519PrivateKey converts the given Ed25519 private key to its X25519 form, the scalar derived from its seed
+func x25519PrivateKey(key ed25519.PrivateKey) (*ecdh.PrivateKey, error) {
+	h := sha512.Sum512(key.Seed())
+	// the scalar is clamped by X25519 as by Ed25519
+	return ecdh.X25519().NewPrivateKey(h[:32])
+}
+
+// sealingKey derives the AES-GCM key of an encryption from the X25519 shared secret and both public keys
+func sealingKey(shared, ephemeral, recipient []byte) (cipher.AEAD, error) {
+	h := sha256.New()
+	h.Write([]byte(KeyAlgorithmEd25519))
+	h.Write(shared)
+	h.Write(ephemeral)
+	h.Write(recipient)
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,117 @@
+// Tests for the signature and encryption algorithms
+package awot
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"testing"
+	"time"
+)
+
+// TestKeyAlgorithms tests signature, encryption and serialization with every supported algorithm
+func TestKeyAlgorithms(t *testing.T) {
+	for _, alg := range []KeyAlgorithm{KeyAlgorithmRSA, KeyAlgorithmEd25519} {
+		t.Run(string(alg), func(t *testing.T) {
+			priK, err := GenerateKey(alg, 1024)
+			if err != nil {
+				t.Fatalf("could not generate key: %v", err)
+			}
+			if got, err := Algorithm(priK.Public()); err != nil || got != alg {
+				t.Fatalf("algorithm of the key should be %v, got %v (%v)", alg, got, err)
+			}
+
+			hashed := sha256.Sum256([]byte("metafile"))
+			sig, err := Sign(priK, hashed[:])
+			if err != nil {
+				t.Fatalf("could not sign: %v", err)
+			}
+			if err = VerifySignature(priK.Public(), hashed[:], sig); err != nil {
+				t.Fatalf("valid signature rejected: %v", err)
+			}
+			hashed[0] ^= 1
+			if err = VerifySignature(priK.Public(), hashed[:], sig); err == nil {
+				t.Fatalf("signature of other data accepted")
+			}
+
+			secret := []byte("private message")
+			ciphertext, err := Encrypt(priK.Public(), secret)
+			if err != nil {
+				t.Fatalf("could not encrypt: %v", err)
+			}
+			plaintext, err := Decrypt(priK, ciphertext)
+			if err != nil || !bytes.Equal(plaintext, secret) {
+				t.Fatalf("could not decrypt: %v", err)
+			}
+
+			keybytes, err := SerializeKey(priK.Public())
+			if err != nil {
+				t.Fatalf("could not serialize key: %v", err)
+			}
+			key, err := DeserializeKey(keybytes)
+			if err != nil || !pubKeyEquals(key, priK.Public()) {
+				t.Fatalf("deserialized key differs: %v", err)
+			}
+		})
+	}
+}
+
+// TestX25519Conversion tests that both conversions of an Ed25519 key pair give the same X25519 key pair
+func TestX25519Conversion(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	xpub, err := x25519PublicKey(pub)
+	if err != nil {
+		t.Fatalf("could not convert public key: %v", err)
+	}
+	xpriv, err := x25519PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("could not convert private key: %v", err)
+	}
+	if !xpub.Equal(xpriv.PublicKey()) {
+		t.Fatalf("converted public key does not match converted private key")
+	}
+}
+
+// TestMixedAlgorithmsRing tests that RSA and Ed25519 peers sign keys for each other in a key ring
+func TestMixedAlgorithmsRing(t *testing.T) {
+	sourceK, _ := GenerateKey(KeyAlgorithmEd25519, 0)
+	rsaK, err := GenerateKey(KeyAlgorithmRSA, 1024)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	edK, _ := GenerateKey(KeyAlgorithmEd25519, 0)
+
+	trusted := []TrustedKeyRecord{
+		{KeyRecord: KeyRecord{Owner: "A", KeyPub: rsaK.Public()}, Confidence: 1.0},
+	}
+	ring := NewKeyRing("source", sourceK.Public(), trusted, 0.0)
+
+	// A (rsa) signs the key of B (ed25519)
+	keybytes, err := SerializeKey(edK.Public())
+	if err != nil {
+		t.Fatalf("could not serialize key: %v", err)
+	}
+	msg := create(keybytes, "B", rsaK, "A", time.Now(), time.Hour)
+	if err = ring.AddMessage(msg, 1.0); err != nil {
+		t.Fatalf("could not add message signed with rsa: %v", err)
+	}
+	if key, ok := ring.GetKey("B"); !ok || !pubKeyEquals(key, edK.Public()) {
+		t.Fatalf("ed25519 key of B should be in the ring")
+	}
+
+	// B (ed25519) signs the key of A (rsa)
+	keybytes, err = SerializeKey(rsaK.Public())
+	if err != nil {
+		t.Fatalf("could not serialize key: %v", err)
+	}
+	msg = create(keybytes, "A", edK, "B", time.Now(), time.Hour)
+	if err = Verify(msg, edK.Public()); err != nil {
+		t.Fatalf("message signed with ed25519 rejected: %v", err)
+	}
+	if err = Verify(msg, rsaK.Public()); err == nil {
+		t.Fatalf("message verified with the key of another algorithm")
+	}
+}
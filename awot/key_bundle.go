@@ -0,0 +1,625 @@
+package awot
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// KeyBundleVersion is the version of the KeyBundle formats
+const KeyBundleVersion = 1
+
+// A KeyBundle is an export of a KeyRing : its keys, the signatures of the keys and the confidence levels of the exporting ring.
+// A bundle is self-verifying : every signature refers to the signed key by its fingerprint, and can be verified
+// with the key of its origin found in the bundle. The signed key bytes are the PEM encoding given by SerializeKey.
+//
+// Two encodings are available :
+//
+// JWKS-like JSON (MarshalJWKS) :
+// 	{
+// 	  "version": 1, "owner": "A", "created": <unix time>,
+// 	  "keys": [{"kty": "RSA", "alg": "PS256", "use": "sig", "kid": <fingerprint>, "n": <base64url>, "e": <base64url>,
+// 	            "owner": "B", "confidence": 0.8, "selected": true}, ...],
+// 	  "signatures": [{"ver": 1, "owner": "B", "origin": "A", "kid": <fingerprint of the signed key>,
+// 	                  "iat": <unix time>, "exp": <unix time>, "sig": <base64url>}, ...]
+// 	}
+//
+// PEM bundle (MarshalPEM) : an "AWOT KEY BUNDLE" block with headers Version, Owner and Created,
+// one "RSA PUBLIC KEY" (RSA) or "PUBLIC KEY" (Ed25519) block per key with headers Owner, Fingerprint, Confidence and Selected,
+// and one "AWOT KEY SIGNATURE" block per signature, the signature as content, with headers
+// Version, Owner, Origin, Fingerprint, Issued-At and Expires-At.
+type KeyBundle struct {
+	Version    int
+	Owner      string    // owner of the exported ring
+	Created    time.Time // time of the export
+	Keys       []BundleKey
+	Signatures []KeyExchangeMessage
+}
+
+// signedEdges caches the signatures made by the owner of a ring for its edges without message,
+// so that answering many requests for a key does not sign it again each time
+type signedEdges struct {
+	messages map[string]KeyExchangeMessage // owner/signed key/signing key -> signature
+	mutex    *sync.Mutex
+}
+
+// A BundleKey is a key of a KeyBundle, with the confidence of the exporting ring
+type BundleKey struct {
+	Owner       string
+	KeyPub      crypto.PublicKey
+	Fingerprint string  // fingerprint of the key
+	Confidence  float32 // confidence level in the key, 0 if not selected
+	Selected    bool    // the key is the one used for Owner by the exporting ring
+}
+
+// An ImportResult reports the signatures of a KeyBundle imported in a KeyRing
+type ImportResult struct {
+	Added    int                  // number of signatures added to the ring
+	Rejected []KeyExchangeMessage // signatures that do not verify, or expired
+	Unknown  []KeyExchangeMessage // signatures whose origin has no trusted key in the ring
+}
+
+// Check verifies every signature of the bundle with the key of its origin given in the bundle
+// Returns nil if the bundle is consistent, an error otherwise
+func (b KeyBundle) Check() error {
+	selected := make(map[string]crypto.PublicKey)
+	for _, k := range b.Keys {
+		if k.Selected {
+			selected[k.Owner] = k.KeyPub
+		}
+	}
+	for _, msg := range b.Signatures {
+		key, ok := selected[msg.Origin]
+		if !ok {
+			return fmt.Errorf("no key for the origin %s of the signature of %s", msg.Origin, msg.Owner)
+		}
+		if err := Verify(msg, key); err != nil {
+			return fmt.Errorf("signature of %s by %s: %v", msg.Owner, msg.Origin, err)
+		}
+	}
+	return nil
+}
+
+////////// Key Ring API
+
+// Export returns a KeyBundle with every key and signature of the ring.
+// The keys the owner of the ring fully trusts without a signature (e.g. bootstrap keys) are signed with the given private key,
+// valid for the given duration, the keys it only partly trusts (e.g. imported with a lower confidence) are not.
+// Other signatures without a KeyExchangeMessage cannot be exported.
+func (ring *KeyRing) Export(priK crypto.Signer, validity time.Duration) KeyBundle {
+	now := time.Now()
+	ring.mutex.Lock()
+	edges := ring.graph.Edges()
+	ring.mutex.Unlock()
+
+	bundle := KeyBundle{
+		Version:    KeyBundleVersion,
+		Owner:      ring.source,
+		Created:    now,
+		Keys:       make([]BundleKey, 0),
+		Signatures: make([]KeyExchangeMessage, 0, len(edges)),
+	}
+
+	keys := make(map[string]BundleKey)
+	addKey := func(owner string, key crypto.PublicKey) {
+		id := owner + "/" + keyID(key)
+		if _, present := keys[id]; present {
+			return
+		}
+		k := BundleKey{
+			Owner:       owner,
+			KeyPub:      key,
+			Fingerprint: Fingerprint(key),
+		}
+		if rec, ok := ring.keyTable.get(owner); ok && keyID(rec.KeyPub) == keyID(key) {
+			k.Confidence = rec.Confidence
+			k.Selected = true
+		}
+		keys[id] = k
+	}
+
+	for _, owner := range ring.GetPeerList() {
+		if rec, ok := ring.keyTable.get(owner); ok {
+			addKey(owner, rec.KeyPub)
+		}
+	}
+
+	for _, edge := range edges {
+		msg := ring.edgeMessage(edge.(Edge), priK, now, validity)
+		if msg == nil {
+			continue
+		}
+		key, err := DeserializeKey(msg.KeyBytes)
+		if err != nil {
+			continue
+		}
+		addKey(msg.Owner, key)
+		bundle.Signatures = append(bundle.Signatures, *msg)
+	}
+
+	for _, k := range keys {
+		bundle.Keys = append(bundle.Keys, k)
+	}
+	sort.Slice(bundle.Keys, func(i, j int) bool {
+		if bundle.Keys[i].Owner != bundle.Keys[j].Owner {
+			return bundle.Keys[i].Owner < bundle.Keys[j].Owner
+		}
+		return bundle.Keys[i].Fingerprint < bundle.Keys[j].Fingerprint
+	})
+	sort.Slice(bundle.Signatures, func(i, j int) bool {
+		if bundle.Signatures[i].Owner != bundle.Signatures[j].Owner {
+			return bundle.Signatures[i].Owner < bundle.Signatures[j].Owner
+		}
+		return bundle.Signatures[i].Origin < bundle.Signatures[j].Origin
+	})
+	return bundle
+}
+
+// Signatures returns the unexpired signatures of the keys of the peer with given name, e.g. to answer a peer looking for its key.
+// As for Export, the keys the owner of the ring fully trusts without a signature are signed with the given private key.
+// These signatures are reused while they are valid for at least half the given duration.
+func (ring *KeyRing) Signatures(name string, priK crypto.Signer, validity time.Duration) []KeyExchangeMessage {
+	now := time.Now()
+	edges := make([]Edge, 0)
+	ring.mutex.Lock()
+	if vertex, present := ring.ids[name]; present {
+		for _, from := range ring.graph.To(*vertex) {
+			if edge := ring.graph.Edge(from, *vertex); edge != nil {
+				edges = append(edges, edge.(Edge))
+			}
+		}
+	}
+	ring.mutex.Unlock()
+
+	signatures := make([]KeyExchangeMessage, 0, len(edges))
+	for _, e := range edges {
+		if e.Expired(now) {
+			continue
+		}
+		if msg := ring.edgeMessage(e, priK, now, validity); msg != nil {
+			signatures = append(signatures, *msg)
+		}
+	}
+	sort.Slice(signatures, func(i, j int) bool {
+		return signatures[i].Origin < signatures[j].Origin
+	})
+	return signatures
+}
+
+// Import adds the signatures of the given bundle to the ring, with given reputation of their owners.
+// The keys and confidence levels of the bundle are not trusted : every signature is verified with the key the ring gives
+// for its origin before being added, the signatures whose origin becomes known through the bundle itself are added afterwards.
+func (ring *KeyRing) Import(bundle KeyBundle, reputationOwner float32) ImportResult {
+	result := ImportResult{
+		Rejected: make([]KeyExchangeMessage, 0),
+		Unknown:  make([]KeyExchangeMessage, 0),
+	}
+
+	remaining := bundle.Signatures
+	for progress := true; progress; {
+		progress = false
+		next := make([]KeyExchangeMessage, 0, len(remaining))
+		for _, msg := range remaining {
+			key, ok := ring.GetKey(msg.Origin)
+			if !ok {
+				next = append(next, msg)
+				continue
+			}
+			if err := Verify(msg, key); err != nil {
+				result.Rejected = append(result.Rejected, msg)
+				continue
+			}
+			if err := ring.AddMessage(msg, reputationOwner); err != nil {
+				result.Rejected = append(result.Rejected, msg)
+				continue
+			}
+			result.Added++
+			progress = true
+		}
+		remaining = next
+	}
+	result.Unknown = append(result.Unknown, remaining...)
+	return result
+}
+
+////////// JWKS-like encoding
+
+// edgeMessage returns the KeyExchangeMessage of the signature represented by the edge, nil if there is none.
+// A signature of the owner of the ring without message is signed with the given private key, valid from now for the given duration,
+// if the signed key is fully trusted, as for FullyTrustedKeys : the owner of the ring does not vouch for keys it partly trusts.
+func (ring KeyRing) edgeMessage(e Edge, priK crypto.Signer, now time.Time, validity time.Duration) *KeyExchangeMessage {
+	if e.message != nil {
+		return e.message
+	}
+	if e.F.name != ring.source {
+		return nil
+	}
+	if rec, ok := ring.keyTable.get(e.T.name); !ok || rec.Confidence < 1.0 || keyID(rec.KeyPub) != keyID(e.Key) {
+		return nil
+	}
+	id := e.T.name + "/" + keyID(e.Key) + "/" + keyID(priK.Public())
+	if msg, ok := ring.signed.get(id, now.Add(validity/2)); ok {
+		return &msg
+	}
+	keybytes, err := SerializeKey(e.Key)
+	if err != nil {
+		return nil
+	}
+	signed := create(keybytes, e.T.name, priK, ring.source, now, validity)
+	ring.signed.put(id, signed)
+	return &signed
+}
+
+// newSignedEdges creates an empty signedEdges
+func newSignedEdges() *signedEdges {
+	return &signedEdges{
+		messages: make(map[string]KeyExchangeMessage),
+		mutex:    &sync.Mutex{},
+	}
+}
+
+// get returns the signature cached with given id and true if it is still valid at given time, otherwise returns false
+func (s *signedEdges) get(id string, validAt time.Time) (KeyExchangeMessage, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	msg, ok := s.messages[id]
+	if !ok || msg.Expired(validAt) {
+		delete(s.messages, id)
+		return KeyExchangeMessage{}, false
+	}
+	return msg, true
+}
+
+// put caches the given signature with given id
+func (s *signedEdges) put(id string, msg KeyExchangeMessage) {
+	s.mutex.Lock()
+	s.messages[id] = msg
+	s.mutex.Unlock()
+}
+
+// jwksBundle is the JSON form of a KeyBundle
+type jwksBundle struct {
+	Version    int             `json:"version"`
+	Owner      string          `json:"owner"`
+	Created    int64           `json:"created"`
+	Keys       []jwksKey       `json:"keys"`
+	Signatures []jwksSignature `json:"signatures"`
+}
+
+// jwksKey is the JSON form of a BundleKey, a JSON Web Key with the fields of the ring
+type jwksKey struct {
+	Kty        string  `json:"kty"`
+	Alg        string  `json:"alg"`
+	Use        string  `json:"use"`
+	Kid        string  `json:"kid"`
+	N          string  `json:"n,omitempty"`   // RSA modulus
+	E          string  `json:"e,omitempty"`   // RSA exponent
+	Crv        string  `json:"crv,omitempty"` // curve of an OKP key
+	X          string  `json:"x,omitempty"`   // OKP public key
+	Owner      string  `json:"owner"`
+	Confidence float32 `json:"confidence"`
+	Selected   bool    `json:"selected"`
+}
+
+// publicKey decodes the public key of the JSON Web Key
+func (jk jwksKey) publicKey() (crypto.PublicKey, error) {
+	switch {
+	case jk.Kty == "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case jk.Kty == "OKP" && jk.Crv == "Ed25519":
+		x, err := base64.RawURLEncoding.DecodeString(jk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("wrong ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, errors.New("unsupported key type " + jk.Kty)
+}
+
+// jwksSignature is the JSON form of a KeyExchangeMessage
+type jwksSignature struct {
+	Version   uint32 `json:"ver"`
+	Owner     string `json:"owner"`
+	Origin    string `json:"origin"`
+	Kid       string `json:"kid"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	Signature string `json:"sig"`
+}
+
+// MarshalJWKS encodes the bundle in the JWKS-like JSON format
+func (b KeyBundle) MarshalJWKS() ([]byte, error) {
+	jb := jwksBundle{
+		Version:    b.Version,
+		Owner:      b.Owner,
+		Created:    b.Created.Unix(),
+		Keys:       make([]jwksKey, 0, len(b.Keys)),
+		Signatures: make([]jwksSignature, 0, len(b.Signatures)),
+	}
+	for _, k := range b.Keys {
+		jk := jwksKey{
+			Use:        "sig",
+			Kid:        k.Fingerprint,
+			Owner:      k.Owner,
+			Confidence: k.Confidence,
+			Selected:   k.Selected,
+		}
+		if key, ok := rsaKey(k.KeyPub); ok {
+			jk.Kty = "RSA"
+			jk.Alg = "PS256"
+			jk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+			jk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+		} else if key, ok := ed25519Key(k.KeyPub); ok {
+			jk.Kty = "OKP"
+			jk.Alg = "EdDSA"
+			jk.Crv = "Ed25519"
+			jk.X = base64.RawURLEncoding.EncodeToString(key)
+		} else {
+			return nil, errors.New("unsupported public key type")
+		}
+		jb.Keys = append(jb.Keys, jk)
+	}
+	for _, msg := range b.Signatures {
+		key, err := DeserializeKey(msg.KeyBytes)
+		if err != nil {
+			return nil, err
+		}
+		jb.Signatures = append(jb.Signatures, jwksSignature{
+			Version:   msg.Version,
+			Owner:     msg.Owner,
+			Origin:    msg.Origin,
+			Kid:       Fingerprint(key),
+			IssuedAt:  msg.IssuedAt,
+			ExpiresAt: msg.ExpiresAt,
+			Signature: base64.RawURLEncoding.EncodeToString(msg.Signature),
+		})
+	}
+	return json.MarshalIndent(jb, "", "  ")
+}
+
+// ParseJWKS decodes a bundle in the JWKS-like JSON format
+// The signatures are not verified, see KeyBundle.Check and KeyRing.Import
+func ParseJWKS(data []byte) (KeyBundle, error) {
+	var jb jwksBundle
+	if err := json.Unmarshal(data, &jb); err != nil {
+		return KeyBundle{}, err
+	}
+	if jb.Version != KeyBundleVersion {
+		return KeyBundle{}, errors.New("unsupported key bundle version")
+	}
+
+	b := KeyBundle{
+		Version:    jb.Version,
+		Owner:      jb.Owner,
+		Created:    time.Unix(jb.Created, 0),
+		Keys:       make([]BundleKey, 0, len(jb.Keys)),
+		Signatures: make([]KeyExchangeMessage, 0, len(jb.Signatures)),
+	}
+	for _, jk := range jb.Keys {
+		key, err := jk.publicKey()
+		if err != nil {
+			return KeyBundle{}, fmt.Errorf("key %s: %v", jk.Kid, err)
+		}
+		b.Keys = append(b.Keys, BundleKey{
+			Owner:       jk.Owner,
+			KeyPub:      key,
+			Fingerprint: Fingerprint(key),
+			Confidence:  jk.Confidence,
+			Selected:    jk.Selected,
+		})
+	}
+	for _, js := range jb.Signatures {
+		signature, err := base64.RawURLEncoding.DecodeString(js.Signature)
+		if err != nil {
+			return KeyBundle{}, fmt.Errorf("signature of %s by %s: %v", js.Owner, js.Origin, err)
+		}
+		msg, err := b.message(js.Version, js.Owner, js.Origin, js.Kid, js.IssuedAt, js.ExpiresAt, signature)
+		if err != nil {
+			return KeyBundle{}, err
+		}
+		b.Signatures = append(b.Signatures, msg)
+	}
+	return b, nil
+}
+
+////////// PEM bundle encoding
+
+const (
+	pemBundleType    = "AWOT KEY BUNDLE"
+	pemKeyType       = "PUBLIC KEY"
+	pemRSAKeyType    = "RSA PUBLIC KEY"
+	pemSignatureType = "AWOT KEY SIGNATURE"
+)
+
+// MarshalPEM encodes the bundle as a sequence of PEM blocks
+func (b KeyBundle) MarshalPEM() ([]byte, error) {
+	var buf bytes.Buffer
+	err := pem.Encode(&buf, &pem.Block{
+		Type: pemBundleType,
+		Headers: map[string]string{
+			"Version": strconv.Itoa(b.Version),
+			"Owner":   b.Owner,
+			"Created": strconv.FormatInt(b.Created.Unix(), 10),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range b.Keys {
+		serialized, err := SerializeKey(k.KeyPub)
+		if err != nil {
+			return nil, err
+		}
+		block, _ := pem.Decode(serialized)
+		err = pem.Encode(&buf, &pem.Block{
+			Type: block.Type,
+			Headers: map[string]string{
+				"Owner":       k.Owner,
+				"Fingerprint": k.Fingerprint,
+				"Confidence":  strconv.FormatFloat(float64(k.Confidence), 'f', -1, 32),
+				"Selected":    strconv.FormatBool(k.Selected),
+			},
+			Bytes: block.Bytes,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, msg := range b.Signatures {
+		key, err := DeserializeKey(msg.KeyBytes)
+		if err != nil {
+			return nil, err
+		}
+		err = pem.Encode(&buf, &pem.Block{
+			Type: pemSignatureType,
+			Headers: map[string]string{
+				"Version":     strconv.FormatUint(uint64(msg.Version), 10),
+				"Owner":       msg.Owner,
+				"Origin":      msg.Origin,
+				"Fingerprint": Fingerprint(key),
+				"Issued-At":   strconv.FormatInt(msg.IssuedAt, 10),
+				"Expires-At":  strconv.FormatInt(msg.ExpiresAt, 10),
+			},
+			Bytes: msg.Signature,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// ParsePEMBundle decodes a bundle encoded as a sequence of PEM blocks
+// The signatures are not verified, see KeyBundle.Check and KeyRing.Import
+func ParsePEMBundle(data []byte) (KeyBundle, error) {
+	b := KeyBundle{
+		Keys:       make([]BundleKey, 0),
+		Signatures: make([]KeyExchangeMessage, 0),
+	}
+	signatures := make([]*pem.Block, 0)
+
+	for {
+		block, rest := pem.Decode(data)
+		if block == nil {
+			break
+		}
+		data = rest
+
+		switch block.Type {
+		case pemBundleType:
+			version, err := strconv.Atoi(block.Headers["Version"])
+			if err != nil || version != KeyBundleVersion {
+				return KeyBundle{}, errors.New("unsupported key bundle version")
+			}
+			created, err := strconv.ParseInt(block.Headers["Created"], 10, 64)
+			if err != nil {
+				return KeyBundle{}, fmt.Errorf("key bundle creation time: %v", err)
+			}
+			b.Version = version
+			b.Owner = block.Headers["Owner"]
+			b.Created = time.Unix(created, 0)
+		case pemKeyType, pemRSAKeyType:
+			key, err := DeserializeKey(pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: block.Bytes}))
+			if err != nil {
+				return KeyBundle{}, err
+			}
+			confidence, err := strconv.ParseFloat(block.Headers["Confidence"], 32)
+			if err != nil {
+				return KeyBundle{}, fmt.Errorf("confidence of a key of %s: %v", block.Headers["Owner"], err)
+			}
+			b.Keys = append(b.Keys, BundleKey{
+				Owner:       block.Headers["Owner"],
+				KeyPub:      key,
+				Fingerprint: Fingerprint(key),
+				Confidence:  float32(confidence),
+				Selected:    block.Headers["Selected"] == "true",
+			})
+		case pemSignatureType:
+			// the signed keys may come later in the bundle
+			signatures = append(signatures, block)
+		}
+	}
+
+	if b.Version != KeyBundleVersion {
+		return KeyBundle{}, errors.New("missing key bundle header")
+	}
+
+	for _, block := range signatures {
+		version, err := strconv.ParseUint(block.Headers["Version"], 10, 32)
+		if err != nil {
+			return KeyBundle{}, fmt.Errorf("signature version: %v", err)
+		}
+		issuedAt, err := strconv.ParseInt(block.Headers["Issued-At"], 10, 64)
+		if err != nil {
+			return KeyBundle{}, fmt.Errorf("signature issue time: %v", err)
+		}
+		expiresAt, err := strconv.ParseInt(block.Headers["Expires-At"], 10, 64)
+		if err != nil {
+			return KeyBundle{}, fmt.Errorf("signature expiration time: %v", err)
+		}
+		msg, err := b.message(uint32(version), block.Headers["Owner"], block.Headers["Origin"], block.Headers["Fingerprint"], issuedAt, expiresAt, block.Bytes)
+		if err != nil {
+			return KeyBundle{}, err
+		}
+		b.Signatures = append(b.Signatures, msg)
+	}
+	return b, nil
+}
+
+// ParseKeyBundle decodes a bundle in either the JWKS-like JSON format or the PEM bundle format
+func ParseKeyBundle(data []byte) (KeyBundle, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return ParseJWKS(data)
+	}
+	return ParsePEMBundle(data)
+}
+
+// message rebuilds the KeyExchangeMessage of a signature of the bundle, the signed key being found by its fingerprint
+func (b KeyBundle) message(version uint32, owner, origin, fingerprint string, issuedAt, expiresAt int64, signature []byte) (KeyExchangeMessage, error) {
+	for _, k := range b.Keys {
+		if k.Owner != owner || !FingerprintMatches(fingerprint, k.KeyPub) {
+			continue
+		}
+		keybytes, err := SerializeKey(k.KeyPub)
+		if err != nil {
+			return KeyExchangeMessage{}, err
+		}
+		return KeyExchangeMessage{
+			Version:   version,
+			KeyBytes:  keybytes,
+			Owner:     owner,
+			Origin:    origin,
+			IssuedAt:  issuedAt,
+			ExpiresAt: expiresAt,
+			Signature: signature,
+		}, nil
+	}
+	return KeyExchangeMessage{}, fmt.Errorf("signature of %s by %s refers to an unknown key %s", owner, origin, fingerprint)
+}
@@ -0,0 +1,245 @@
+// Tests for the export and import of key rings
+package awot
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+)
+
+// exportedRing returns the keys of the peers and a bundle of the ring of "source" : source -> A (bootstrap), A -> B (signed)
+func exportedRing(t *testing.T) (map[string]*rsa.PrivateKey, KeyBundle) {
+	keys := make(map[string]*rsa.PrivateKey)
+	for _, name := range []string{"source", "A", "B", "other"} {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			t.Fatalf("could not generate rsa key: %v", err)
+		}
+		keys[name] = key
+	}
+
+	trusted := []TrustedKeyRecord{
+		{KeyRecord: KeyRecord{Owner: "A", KeyPub: keys["A"].PublicKey}, Confidence: 1.0},
+	}
+	ring := NewKeyRing("source", keys["source"].PublicKey, trusted, 0.0)
+
+	keybytes, err := SerializeKey(keys["B"].PublicKey)
+	if err != nil {
+		t.Fatalf("could not serialize key: %v", err)
+	}
+	msg := create(keybytes, "B", keys["A"], "A", time.Now(), time.Hour)
+	if err = ring.AddMessage(msg, 1.0); err != nil {
+		t.Fatalf("could not add message: %v", err)
+	}
+
+	bundle := ring.Export(keys["source"], time.Hour)
+	if len(bundle.Signatures) != 2 {
+		t.Fatalf("bundle should have 2 signatures, got %v", len(bundle.Signatures))
+	}
+	if len(bundle.Keys) != 3 {
+		t.Fatalf("bundle should have 3 keys, got %v", len(bundle.Keys))
+	}
+	if err = bundle.Check(); err != nil {
+		t.Fatalf("exported bundle should be consistent: %v", err)
+	}
+	return keys, bundle
+}
+
+// TestExportFullyTrusted tests that the owner of the ring only signs the keys it fully trusts in a bundle
+func TestExportFullyTrusted(t *testing.T) {
+	keys, _ := exportedRing(t)
+	trusted := []TrustedKeyRecord{
+		{KeyRecord: KeyRecord{Owner: "A", KeyPub: keys["A"].PublicKey}, Confidence: 1.0},
+	}
+	ring := NewKeyRing("source", keys["source"].PublicKey, trusted, 0.0)
+
+	signed := func(bundle KeyBundle, owner string) bool {
+		for _, msg := range bundle.Signatures {
+			if msg.Owner == owner && msg.Origin == "source" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if err := ring.ImportKey(KeyRecord{Owner: "other", KeyPub: keys["other"].PublicKey}, 0.3); err != nil {
+		t.Fatalf("could not import key: %v", err)
+	}
+	bundle := ring.Export(keys["source"], time.Hour)
+	if !signed(bundle, "A") {
+		t.Fatalf("bootstrap key of A should be signed by the source")
+	}
+	if signed(bundle, "other") {
+		t.Fatalf("key imported with confidence 0.3 should not be signed by the source")
+	}
+	if err := bundle.Check(); err != nil {
+		t.Fatalf("exported bundle should be consistent: %v", err)
+	}
+
+	if err := ring.ImportKey(KeyRecord{Owner: "other", KeyPub: keys["other"].PublicKey}, 1.0); err != nil {
+		t.Fatalf("could not import key: %v", err)
+	}
+	if !signed(ring.Export(keys["source"], time.Hour), "other") {
+		t.Fatalf("key imported with full confidence should be signed by the source")
+	}
+
+	if err := ring.Distrust("A"); err != nil {
+		t.Fatalf("could not distrust A: %v", err)
+	}
+	if signed(ring.Export(keys["source"], time.Hour), "A") {
+		t.Fatalf("distrusted key of A should not be signed by the source")
+	}
+}
+
+// TestKeyBundleEncodings tests that both encodings of a bundle give back the same bundle
+func TestKeyBundleEncodings(t *testing.T) {
+	_, bundle := exportedRing(t)
+
+	jwks, err := bundle.MarshalJWKS()
+	if err != nil {
+		t.Fatalf("could not marshal bundle to jwks: %v", err)
+	}
+	pemBundle, err := bundle.MarshalPEM()
+	if err != nil {
+		t.Fatalf("could not marshal bundle to pem: %v", err)
+	}
+
+	for name, data := range map[string][]byte{"jwks": jwks, "pem": pemBundle} {
+		t.Run(name, func(t *testing.T) {
+			parsed, err := ParseKeyBundle(data)
+			if err != nil {
+				t.Fatalf("could not parse bundle: %v", err)
+			}
+			if parsed.Owner != "source" || len(parsed.Keys) != len(bundle.Keys) || len(parsed.Signatures) != len(bundle.Signatures) {
+				t.Fatalf("parsed bundle differs from the exported one")
+			}
+			for i, k := range parsed.Keys {
+				if !pubKeyEquals(k.KeyPub, bundle.Keys[i].KeyPub) || k.Confidence != bundle.Keys[i].Confidence || k.Selected != bundle.Keys[i].Selected {
+					t.Fatalf("key %v of the parsed bundle differs from the exported one", i)
+				}
+			}
+			if err = parsed.Check(); err != nil {
+				t.Fatalf("parsed bundle should be consistent: %v", err)
+			}
+		})
+	}
+}
+
+// TestKeyBundleImport tests that only verified signatures of a bundle are added to a ring
+func TestKeyBundleImport(t *testing.T) {
+	keys, bundle := exportedRing(t)
+
+	// a ring trusting the exporter learns A then B
+	trusted := []TrustedKeyRecord{
+		{KeyRecord: KeyRecord{Owner: "source", KeyPub: keys["source"].PublicKey}, Confidence: 1.0},
+	}
+	ring := NewKeyRing("other", keys["other"].PublicKey, trusted, 0.0)
+	result := ring.Import(bundle, 1.0)
+	if result.Added != 2 || len(result.Rejected) != 0 || len(result.Unknown) != 0 {
+		t.Fatalf("every signature should be added, got %+v", result)
+	}
+	if key, ok := ring.GetKey("B"); !ok || !pubKeyEquals(key, keys["B"].PublicKey) {
+		t.Fatalf("key of B should be imported")
+	}
+
+	// a ring that does not know the exporter imports nothing
+	ring = NewKeyRing("other", keys["other"].PublicKey, nil, 0.0)
+	result = ring.Import(bundle, 1.0)
+	if result.Added != 0 || len(result.Unknown) != 2 {
+		t.Fatalf("signatures of unknown origins should not be added, got %+v", result)
+	}
+
+	// a tampered signature is rejected
+	ring = NewKeyRing("other", keys["other"].PublicKey, trusted, 0.0)
+	tampered := bundle
+	tampered.Signatures = append([]KeyExchangeMessage(nil), bundle.Signatures...)
+	for i, msg := range tampered.Signatures {
+		if msg.Owner == "B" {
+			tampered.Signatures[i].Owner = "A"
+		}
+	}
+	if err := tampered.Check(); err == nil {
+		t.Fatalf("tampered bundle should not be consistent")
+	}
+	result = ring.Import(tampered, 1.0)
+	if result.Added != 1 || len(result.Rejected) != 1 {
+		t.Fatalf("tampered signature should be rejected, got %+v", result)
+	}
+	if _, ok := ring.GetKey("B"); ok {
+		t.Fatalf("key of B should not be imported from a tampered bundle")
+	}
+}
+
+// TestSignatures tests that the signatures returned for a peer let another ring learn its key
+func TestSignatures(t *testing.T) {
+	keys := make(map[string]crypto.Signer)
+	for _, name := range []string{"source", "A", "B", "other"} {
+		keys[name], _ = GenerateKey(KeyAlgorithmEd25519, 0)
+	}
+
+	// source -> A (bootstrap), A -> B (signed)
+	trusted := []TrustedKeyRecord{
+		{KeyRecord: KeyRecord{Owner: "A", KeyPub: keys["A"].Public()}, Confidence: 1.0},
+	}
+	ring := NewKeyRing("source", keys["source"].Public(), trusted, 0.0)
+	keybytes, _ := SerializeKey(keys["B"].Public())
+	if err := ring.AddMessage(create(keybytes, "B", keys["A"], "A", time.Now(), time.Hour), 1.0); err != nil {
+		t.Fatalf("could not add message: %v", err)
+	}
+
+	if sigs := ring.Signatures("unknown", keys["source"], time.Hour); len(sigs) != 0 {
+		t.Fatalf("no signature should be returned for an unknown peer")
+	}
+	sigsA := ring.Signatures("A", keys["source"], time.Hour)
+	if len(sigsA) != 1 || sigsA[0].Origin != "source" || Verify(sigsA[0], keys["source"].Public()) != nil {
+		t.Fatalf("bootstrap key of A should be signed by the source")
+	}
+	sigsB := ring.Signatures("B", keys["source"], time.Hour)
+	if len(sigsB) != 1 || sigsB[0].Origin != "A" {
+		t.Fatalf("signature of B by A should be returned, got %v", sigsB)
+	}
+
+	// the signature of the source is made once, and reused while valid for long enough
+	for id, msg := range ring.signed.messages {
+		msg.IssuedAt-- // marks the cached signature
+		ring.signed.messages[id] = msg
+	}
+	if again := ring.Signatures("A", keys["source"], time.Hour); len(again) != 1 || again[0].IssuedAt != sigsA[0].IssuedAt-1 {
+		t.Fatalf("signature of A by the source should be reused")
+	}
+	if again := ring.Signatures("A", keys["source"], 4*time.Hour); len(again) != 1 || bytes.Equal(again[0].Signature, sigsA[0].Signature) {
+		t.Fatalf("signature of A by the source should be made again for a longer validity")
+	}
+	if again := ring.Signatures("A", keys["other"], time.Hour); len(again) != 1 || Verify(again[0], keys["other"].Public()) != nil {
+		t.Fatalf("signature of A should be made again with another private key")
+	}
+
+	// the source does not vouch for a key it partly trusts
+	if err := ring.ImportKey(KeyRecord{Owner: "C", KeyPub: keys["other"].Public()}, 0.3); err != nil {
+		t.Fatalf("could not import key: %v", err)
+	}
+	if sigs := ring.Signatures("C", keys["source"], time.Hour); len(sigs) != 0 {
+		t.Fatalf("key imported with confidence 0.3 should not be signed by the source, got %v", sigs)
+	}
+
+	// a ring trusting the source learns A then B from the signatures
+	trusted = []TrustedKeyRecord{
+		{KeyRecord: KeyRecord{Owner: "source", KeyPub: keys["source"].Public()}, Confidence: 1.0},
+	}
+	other := NewKeyRing("other", keys["other"].Public(), trusted, 0.0)
+	for _, msg := range append(sigsA, sigsB...) {
+		signerKey, ok := other.GetKey(msg.Origin)
+		if !ok || Verify(msg, signerKey) != nil {
+			t.Fatalf("could not verify signature of %v by %v", msg.Owner, msg.Origin)
+		}
+		if err := other.AddMessage(msg, 1.0); err != nil {
+			t.Fatalf("could not add message: %v", err)
+		}
+	}
+	if key, ok := other.GetKey("B"); !ok || !pubKeyEquals(key, keys["B"].Public()) {
+		t.Fatalf("key of B should be learnt from the signatures")
+	}
+}
@@ -0,0 +1,327 @@
+package awot
+
+import (
+	"crypto"
+	"errors"
+	"sort"
+	"sync"
+
+	"gonum.org/v1/gonum/graph"
+)
+
+// A CollisionPolicy decides which key is used when several keys are advertised for the same owner
+type CollisionPolicy int
+
+const (
+	// CollisionMostPaths selects the key with the highest number of shortest paths from the source
+	CollisionMostPaths CollisionPolicy = iota
+	// CollisionHighestConfidence selects the key with the highest confidence level
+	CollisionHighestConfidence
+	// CollisionReject does not select any key while the collision lasts
+	CollisionReject
+)
+
+// ParseCollisionPolicy returns the CollisionPolicy with given name : "paths", "confidence" or "reject"
+func ParseCollisionPolicy(name string) (CollisionPolicy, error) {
+	switch name {
+	case "paths":
+		return CollisionMostPaths, nil
+	case "confidence":
+		return CollisionHighestConfidence, nil
+	case "reject":
+		return CollisionReject, nil
+	}
+	return CollisionMostPaths, errors.New("unknown collision policy " + name)
+}
+
+// A KeyCandidate is one of the keys advertised for an owner, with the peers that signed it
+type KeyCandidate struct {
+	KeyPub      crypto.PublicKey
+	Fingerprint string   // fingerprint of the key
+	Signers     []string // names of the peers that signed the key
+	Confidence  float32  // confidence level of the key, considering only its own signatures
+}
+
+// A KeyCollision is the set of competing keys advertised for the same owner
+type KeyCollision struct {
+	Owner      string
+	Candidates []KeyCandidate // competing keys, by decreasing confidence
+	Selected   int            // index of the key selected by the policy, -1 if none
+	Decisive   bool           // the selected key is trusted by the source, or more confident than the others, not only on more paths
+	Suspicious []int          // indexes of the losing keys of a decisive selection, whose signers were not reported yet
+}
+
+// Losers returns the candidates that were not selected
+func (c KeyCollision) Losers() []KeyCandidate {
+	losers := make([]KeyCandidate, 0)
+	for i, candidate := range c.Candidates {
+		if i != c.Selected {
+			losers = append(losers, candidate)
+		}
+	}
+	return losers
+}
+
+// SuspiciousSigners returns the signers of the losing keys that were not reported yet, see Suspicious
+func (c KeyCollision) SuspiciousSigners() []string {
+	signers := make([]string, 0)
+	for _, i := range c.Suspicious {
+		signers = append(signers, c.Candidates[i].Signers...)
+	}
+	return signers
+}
+
+// A collisionTracker keeps the current collisions of a KeyRing and its resolution policy, thread safe
+type collisionTracker struct {
+	policy   CollisionPolicy
+	current  map[string]KeyCollision // owner -> collision
+	reported map[string]bool         // owner/key id of the losing keys already reported as suspicious
+	handler  func(KeyCollision)      // called when a collision appears
+	mutex    *sync.Mutex
+}
+
+// newCollisionTracker creates a collisionTracker without collision, using the CollisionMostPaths policy
+func newCollisionTracker() *collisionTracker {
+	return &collisionTracker{
+		policy:   CollisionMostPaths,
+		current:  make(map[string]KeyCollision),
+		reported: make(map[string]bool),
+		mutex:    &sync.Mutex{},
+	}
+}
+
+// getPolicy returns the resolution policy
+func (tracker *collisionTracker) getPolicy() CollisionPolicy {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	return tracker.policy
+}
+
+// update records the collision, sets its suspicious keys, and returns true if it is new, if its set of keys or
+// its selected key changed, or if it has suspicious keys.
+// The losing keys of a decisive selection are suspicious once, so that a selection flipping back and forth
+// does not report the same signers again.
+func (tracker *collisionTracker) update(c *KeyCollision) bool {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	if c.Decisive {
+		for i, candidate := range c.Candidates {
+			id := c.Owner + "/" + keyID(candidate.KeyPub)
+			if i != c.Selected && !tracker.reported[id] {
+				tracker.reported[id] = true
+				c.Suspicious = append(c.Suspicious, i)
+			}
+		}
+	}
+	old, present := tracker.current[c.Owner]
+	tracker.current[c.Owner] = *c
+	if !present || len(old.Candidates) != len(c.Candidates) || selectedID(old) != selectedID(*c) ||
+		len(c.Suspicious) > 0 {
+		return true
+	}
+	known := make(map[string]bool)
+	for _, candidate := range old.Candidates {
+		known[keyID(candidate.KeyPub)] = true
+	}
+	for _, candidate := range c.Candidates {
+		if !known[keyID(candidate.KeyPub)] {
+			return true
+		}
+	}
+	return false
+}
+
+// selectedID returns the id of the selected key of the collision, or an empty string if none
+func selectedID(c KeyCollision) string {
+	if c.Selected < 0 {
+		return ""
+	}
+	return keyID(c.Candidates[c.Selected].KeyPub)
+}
+
+// clear removes the collision of given owner, if any
+func (tracker *collisionTracker) clear(owner string) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	delete(tracker.current, owner)
+}
+
+// rejects checks if no key is to be given for the owner because of a collision
+func (tracker *collisionTracker) rejects(owner string) bool {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	c, present := tracker.current[owner]
+	return present && c.Selected < 0
+}
+
+// notify calls the handler for each given collision
+func (tracker *collisionTracker) notify(collisions []KeyCollision) {
+	tracker.mutex.Lock()
+	handler := tracker.handler
+	tracker.mutex.Unlock()
+	if handler == nil {
+		return
+	}
+	for _, c := range collisions {
+		handler(c)
+	}
+}
+
+////////// Key Ring API
+
+// SetCollisionPolicy sets the policy used for selecting a key when several keys are advertised for the same owner
+func (ring *KeyRing) SetCollisionPolicy(policy CollisionPolicy) {
+	ring.collisions.mutex.Lock()
+	ring.collisions.policy = policy
+	ring.collisions.mutex.Unlock()
+	ring.updateAllConfidence()
+}
+
+// SetCollisionHandler sets the function called when a collision appears or changes.
+// The handler must not modify the KeyRing.
+func (ring *KeyRing) SetCollisionHandler(handler func(KeyCollision)) {
+	ring.collisions.mutex.Lock()
+	ring.collisions.handler = handler
+	ring.collisions.mutex.Unlock()
+}
+
+// Collisions returns the current collisions of keys
+func (ring KeyRing) Collisions() []KeyCollision {
+	ring.collisions.mutex.Lock()
+	defer ring.collisions.mutex.Unlock()
+	collisions := make([]KeyCollision, 0, len(ring.collisions.current))
+	for _, c := range ring.collisions.current {
+		collisions = append(collisions, c)
+	}
+	sort.Slice(collisions, func(i, j int) bool { return collisions[i].Owner < collisions[j].Owner })
+	return collisions
+}
+
+////////// Implementation
+
+// resolveCollision takes the shortest paths to the node with given name, and returns the paths corresponding to the key selected for it.
+// If several acceptable keys are signed for the node, the collision is recorded and the key is selected according to the policy.
+// Returns the collision if it is new, nil otherwise.
+// thread unsafe
+func (ring *KeyRing) resolveCollision(tg *TrustGraph, name string, vertex *Node, paths [][]graph.Node) ([][]graph.Node, crypto.PublicKey, *KeyCollision) {
+	candidates := ring.candidates(tg, name, vertex)
+	if len(candidates) < 2 {
+		ring.collisions.clear(name)
+		bestPaths, bestKey := ring.selectBestPaths(name, paths)
+		return bestPaths, bestKey, nil
+	}
+
+	collision := KeyCollision{
+		Owner:      name,
+		Candidates: candidates,
+		Selected:   -1,
+	}
+
+	var bestPaths [][]graph.Node
+	var bestKey crypto.PublicKey
+
+	switch ring.collisions.getPolicy() {
+	case CollisionMostPaths:
+		bestPaths, bestKey = ring.selectBestPaths(name, paths)
+	case CollisionHighestConfidence:
+		if candidates[0].Confidence > 0 {
+			key := candidates[0].KeyPub
+			bestPaths, bestKey = ring.pathsWithKey(paths, key), key
+		}
+	case CollisionReject:
+	}
+
+	if bestKey != nil {
+		for i, candidate := range candidates {
+			if keyID(candidate.KeyPub) == keyID(bestKey) {
+				collision.Selected = i
+			}
+		}
+		collision.Decisive = ring.isDecisive(collision)
+	}
+
+	if ring.collisions.update(&collision) {
+		return bestPaths, bestKey, &collision
+	}
+	return bestPaths, bestKey, nil
+}
+
+// isDecisive checks if the selected key of the collision tells which signers are honest : it is signed by the source,
+// or it was selected by its confidence, strictly higher than the one of the other keys.
+// A key selected for its number of paths alone is not decisive, as anyone can add paths to a key.
+// thread unsafe
+func (ring *KeyRing) isDecisive(c KeyCollision) bool {
+	selected := c.Candidates[c.Selected]
+	for _, signer := range selected.Signers {
+		if signer == ring.source {
+			return true
+		}
+	}
+	if ring.collisions.getPolicy() != CollisionHighestConfidence {
+		return false
+	}
+	for i, candidate := range c.Candidates {
+		if i != c.Selected && candidate.Confidence >= selected.Confidence {
+			return false
+		}
+	}
+	return true
+}
+
+// candidates returns the acceptable keys signed for the node with given name, by decreasing confidence
+// thread unsafe
+func (ring *KeyRing) candidates(tg *TrustGraph, name string, vertex *Node) []KeyCandidate {
+	byKey := make(map[string]*KeyCandidate)
+	for _, from := range ring.graph.To(*vertex) {
+		edge := ring.graph.Edge(from, *vertex)
+		if edge == nil {
+			continue
+		}
+		key := edge.(Edge).Key
+		if !ring.manual.accepts(name, key) {
+			continue
+		}
+		id := keyID(key)
+		if byKey[id] == nil {
+			byKey[id] = &KeyCandidate{
+				KeyPub:      key,
+				Fingerprint: Fingerprint(key),
+			}
+		}
+		byKey[id].Signers = append(byKey[id].Signers, from.(Node).name)
+	}
+
+	candidates := make([]KeyCandidate, 0, len(byKey))
+	for _, candidate := range byKey {
+		candidate.Confidence, _ = ring.confidence.metric.Confidence(tg, name, candidate.KeyPub)
+		sort.Strings(candidate.Signers)
+		candidates = append(candidates, *candidate)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Confidence != candidates[j].Confidence {
+			return candidates[i].Confidence > candidates[j].Confidence
+		}
+		if len(candidates[i].Signers) != len(candidates[j].Signers) {
+			return len(candidates[i].Signers) > len(candidates[j].Signers)
+		}
+		return candidates[i].Fingerprint < candidates[j].Fingerprint
+	})
+	return candidates
+}
+
+// pathsWithKey returns the given paths whose last edge carries the given key
+// thread unsafe
+func (ring *KeyRing) pathsWithKey(paths [][]graph.Node, key crypto.PublicKey) [][]graph.Node {
+	id := keyID(key)
+	selected := make([][]graph.Node, 0)
+	for _, p := range paths {
+		if len(p) < 2 {
+			continue
+		}
+		if keyID(ring.lastKey(p)) == id {
+			selected = append(selected, p)
+		}
+	}
+	return selected
+}
@@ -0,0 +1,131 @@
+// Tests for key collisions
+package awot
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+)
+
+// TestKeyCollision tests the detection and resolution of competing keys for the same owner
+func TestKeyCollision(t *testing.T) {
+	keys := make(map[string]*rsa.PrivateKey)
+	for _, name := range []string{"source", "A", "C", "D", "B", "B2"} {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			t.Fatalf("could not generate rsa key: %v", err)
+		}
+		keys[name] = key
+	}
+
+	// source fully trusts A, C and D
+	trusted := []TrustedKeyRecord{
+		{KeyRecord: KeyRecord{Owner: "A", KeyPub: keys["A"].PublicKey}, Confidence: 1.0},
+		{KeyRecord: KeyRecord{Owner: "C", KeyPub: keys["C"].PublicKey}, Confidence: 1.0},
+		{KeyRecord: KeyRecord{Owner: "D", KeyPub: keys["D"].PublicKey}, Confidence: 1.0},
+	}
+	ring := NewKeyRing("source", keys["source"].PublicKey, trusted, 0.0)
+
+	notified := make([]KeyCollision, 0)
+	ring.SetCollisionHandler(func(c KeyCollision) {
+		notified = append(notified, c)
+	})
+
+	// A signs a key for B, C and D sign another one
+	ring.Add(KeyRecord{Owner: "B", KeyPub: keys["B"].PublicKey}, "A", 1.0)
+	if len(notified) != 0 {
+		t.Fatalf("a single key should not be a collision")
+	}
+	ring.Add(KeyRecord{Owner: "B", KeyPub: keys["B2"].PublicKey}, "C", 1.0)
+	ring.Add(KeyRecord{Owner: "B", KeyPub: keys["B2"].PublicKey}, "D", 1.0)
+
+	if len(notified) == 0 {
+		t.Fatalf("collision should be notified")
+	}
+	count := len(notified)
+	collision := notified[count-1]
+	if collision.Owner != "B" || len(collision.Candidates) != 2 {
+		t.Fatalf("collision should contain the two keys of B")
+	}
+	losers := collision.Losers()
+	if len(losers) != 1 || !pubKeyEquals(losers[0].KeyPub, keys["B"].PublicKey) ||
+		len(losers[0].Signers) != 1 || losers[0].Signers[0] != "A" {
+		t.Fatalf("the key signed by A only should lose the collision")
+	}
+	if len(ring.Collisions()) != 1 {
+		t.Fatalf("collision should be listed")
+	}
+
+	// signing again does not notify again
+	ring.Add(KeyRecord{Owner: "B", KeyPub: keys["B2"].PublicKey}, "D", 1.0)
+	if len(notified) != count {
+		t.Fatalf("unchanged collision should not be notified again")
+	}
+
+	key, ok := ring.GetKey("B")
+	if !ok || !pubKeyEquals(key, keys["B2"].PublicKey) {
+		t.Fatalf("key with most paths should be selected")
+	}
+
+	if collision.Decisive || len(collision.Suspicious) != 0 {
+		t.Fatalf("a key selected for its number of paths should not make the losers suspicious")
+	}
+
+	t.Run("decisive", func(t *testing.T) {
+		// both keys are fully confident, the highest confidence does not tell which one is honest
+		ring.SetCollisionPolicy(CollisionHighestConfidence)
+		if suspicious := suspiciousSigners(notified); len(suspicious) != 0 {
+			t.Fatalf("keys of equal confidence should not make the losers suspicious, got %v", suspicious)
+		}
+
+		// once the source signs a key, the signers of the other one are suspicious, once
+		ring.Add(KeyRecord{Owner: "B", KeyPub: keys["B2"].PublicKey}, "source", 1.0)
+		suspicious := suspiciousSigners(notified)
+		if len(suspicious) != 1 || suspicious[0] != "A" {
+			t.Fatalf("the signer of the losing key should be suspicious once, got %v", suspicious)
+		}
+
+		// a selection flipping back and forth does not report the signers again
+		ring.SetCollisionPolicy(CollisionReject)
+		ring.SetCollisionPolicy(CollisionMostPaths)
+		ring.SetCollisionPolicy(CollisionReject)
+		ring.SetCollisionPolicy(CollisionHighestConfidence)
+		if suspicious := suspiciousSigners(notified); len(suspicious) != 1 {
+			t.Fatalf("the signer of the losing key should not be reported again, got %v", suspicious)
+		}
+		ring.SetCollisionPolicy(CollisionMostPaths)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		ring.SetCollisionPolicy(CollisionReject)
+		if _, ok := ring.GetKey("B"); ok {
+			t.Fatalf("no key should be returned while the collision lasts")
+		}
+		ring.SetCollisionPolicy(CollisionHighestConfidence)
+		key, ok := ring.GetKey("B")
+		if !ok || !pubKeyEquals(key, keys["B2"].PublicKey) {
+			t.Fatalf("key with highest confidence should be selected")
+		}
+	})
+
+	t.Run("resolved", func(t *testing.T) {
+		ring.removeEdge("A", "B")
+		ring.updateConfidence()
+		if len(ring.Collisions()) != 0 {
+			t.Fatalf("collision should be removed when only one key is left")
+		}
+	})
+
+	if _, err := ParseCollisionPolicy("unknown"); err == nil {
+		t.Fatalf("unknown policy should not be parsed")
+	}
+}
+
+// suspiciousSigners returns the suspicious signers of the given collisions
+func suspiciousSigners(collisions []KeyCollision) []string {
+	signers := make([]string, 0)
+	for _, c := range collisions {
+		signers = append(signers, c.SuspiciousSigners()...)
+	}
+	return signers
+}
@@ -2,27 +2,80 @@ package awot
 
 import (
 	"crypto"
-	"crypto/rsa"
 	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"time"
 )
 
+// KeyExchangeVersion is the version of the KeyExchangeMessage format, part of the signed data
+const KeyExchangeVersion = 1
+
+// DefaultKeyValidity is the validity of a KeyExchangeMessage when none is given
+const DefaultKeyValidity = 24 * time.Hour
+
+// MaxClockSkew is the tolerated difference between the clock of the signer and the local clock
+const MaxClockSkew = 5 * time.Minute
+
+// keyExchangePrefix separates the signed data of a KeyExchangeMessage from the one of other messages
+const keyExchangePrefix = "KEYEXCHANGE"
+
 // A KeyExchangeMessage is a signed relation (publickey - owner)
 // This should be used to share a known and relatively trusted public key to other peers
+// The signature is valid only between IssuedAt and ExpiresAt, so that a captured message cannot be replayed forever.
 type KeyExchangeMessage struct {
+	Version   uint32 // version of the message format
 	KeyBytes  []byte // serialized public key
 	Owner     string // owner of the public key
 	Origin    string // signer
-	Signature []byte // signature of (keyPub <-> owner)
+	IssuedAt  int64  // unix time of the signature, in seconds
+	ExpiresAt int64  // unix time of the expiration of the signature, in seconds
+	Signature []byte // signature of (KEYEXCHANGE <-> version <-> keyPub <-> owner <-> issuedAt <-> expiresAt)
+}
+
+// Issued returns the time at which the message was signed
+func (msg KeyExchangeMessage) Issued() time.Time {
+	return time.Unix(msg.IssuedAt, 0)
 }
 
-// Verify verifies that the received message is signed by the pretended origin
+// Expires returns the time after which the message is not valid anymore
+func (msg KeyExchangeMessage) Expires() time.Time {
+	return time.Unix(msg.ExpiresAt, 0)
+}
+
+// Expired checks if the message is expired at given time
+func (msg KeyExchangeMessage) Expired(now time.Time) bool {
+	return now.After(msg.Expires())
+}
+
+// Verify verifies that the received message is signed by the pretended origin, and that it is currently valid
 // Returns nil if valid, an error otherwise
-func Verify(msg KeyExchangeMessage, OriginKeyPub rsa.PublicKey) error {
-	data := append(msg.KeyBytes, []byte(msg.Owner)...)
+func Verify(msg KeyExchangeMessage, OriginKeyPub crypto.PublicKey) error {
+	if msg.Version != KeyExchangeVersion {
+		return errors.New("unsupported key exchange message version")
+	}
+	now := time.Now()
+	if msg.Expired(now) {
+		return errors.New("key exchange message expired")
+	}
+	if msg.Issued().After(now.Add(MaxClockSkew)) {
+		return errors.New("key exchange message issued in the future")
+	}
+	if msg.ExpiresAt < msg.IssuedAt {
+		return errors.New("key exchange message expires before being issued")
+	}
+	hashed := keyExchangeHash(msg.Version, msg.KeyBytes, msg.Owner, msg.IssuedAt, msg.ExpiresAt)
+	return VerifySignature(OriginKeyPub, hashed, msg.Signature)
+}
+
+// keyExchangeHash returns the hash of the data signed in a KeyExchangeMessage
+func keyExchangeHash(version uint32, keybytes []byte, owner string, issuedAt, expiresAt int64) []byte {
 	newhash := sha256.New()
-	newhash.Write(data)
-	hashed := newhash.Sum(nil)
-	var opts rsa.PSSOptions
-	opts.SaltLength = rsa.PSSSaltLengthAuto
-	return rsa.VerifyPSS(&OriginKeyPub, crypto.SHA256, hashed, msg.Signature, nil) //&opts)
+	newhash.Write([]byte(keyExchangePrefix))
+	binary.Write(newhash, binary.BigEndian, version)
+	newhash.Write(keybytes)
+	newhash.Write([]byte(owner))
+	binary.Write(newhash, binary.BigEndian, issuedAt)
+	binary.Write(newhash, binary.BigEndian, expiresAt)
+	return newhash.Sum(nil)
 }
@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"testing"
+	"time"
 )
 
 // Generates key for A and B
@@ -38,7 +39,7 @@ func TestKeyExchangeSigning(t *testing.T) {
 		t.Errorf("Could not serialize the key: %v", err)
 	}
 
-	msg := create(keyBBytes, recordForB.Owner, *keyA, A)
+	msg := create(keyBBytes, recordForB.Owner, keyA, A, time.Now(), DefaultKeyValidity)
 
 	// check that the signature is correct
 	err = Verify(msg, keyA.PublicKey)
@@ -52,7 +53,7 @@ func TestKeyExchangeSigning(t *testing.T) {
 		Confidence: 1.0,
 	}
 
-	msg = trustedRecordForB.ConstructMessage(*keyA, A)
+	msg = trustedRecordForB.ConstructMessage(keyA, A, DefaultKeyValidity)
 
 	err = Verify(msg, keyA.PublicKey)
 
@@ -60,3 +61,74 @@ func TestKeyExchangeSigning(t *testing.T) {
 		t.Errorf("Signature of message is wrong: %v", err)
 	}
 }
+
+// TestKeyExchangeValidity tests that expired messages are rejected and expired signatures are dropped from the ring
+func TestKeyExchangeValidity(t *testing.T) {
+	keys := make(map[string]*rsa.PrivateKey)
+	for _, name := range []string{"source", "A", "B"} {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			t.Fatalf("could not generate rsa key: %v", err)
+		}
+		keys[name] = key
+	}
+	keyBBytes, err := SerializeKey(keys["B"].PublicKey)
+	if err != nil {
+		t.Fatalf("could not serialize the key: %v", err)
+	}
+
+	now := time.Now()
+
+	expired := create(keyBBytes, "B", keys["A"], "A", now.Add(-2*time.Hour), time.Hour)
+	if err = Verify(expired, keys["A"].PublicKey); err == nil {
+		t.Fatalf("expired message should be rejected")
+	}
+
+	future := create(keyBBytes, "B", keys["A"], "A", now.Add(time.Hour), time.Hour)
+	if err = Verify(future, keys["A"].PublicKey); err == nil {
+		t.Fatalf("message issued in the future should be rejected")
+	}
+
+	// the validity is part of the signed data
+	msg := create(keyBBytes, "B", keys["A"], "A", now, time.Hour)
+	extended := msg
+	extended.ExpiresAt += 3600
+	if err = Verify(extended, keys["A"].PublicKey); err == nil {
+		t.Fatalf("message with modified expiration should be rejected")
+	}
+
+	trusted := []TrustedKeyRecord{
+		{KeyRecord: KeyRecord{Owner: "A", KeyPub: keys["A"].PublicKey}, Confidence: 1.0},
+	}
+	ring := NewKeyRing("source", keys["source"].PublicKey, trusted, 0.0)
+	if err = ring.AddMessage(msg, 1.0); err != nil {
+		t.Fatalf("could not add message: %v", err)
+	}
+	if err = ring.AddMessage(expired, 1.0); err == nil {
+		t.Fatalf("adding an expired message should fail")
+	}
+
+	// a replayed older message does not replace the newer signature
+	older := create(keyBBytes, "B", keys["A"], "A", now.Add(-50*time.Minute), time.Hour)
+	ring.AddMessage(older, 1.0)
+	edge := ring.graph.Edge(ring.ids["A"], ring.ids["B"]).(Edge)
+	if !edge.ExpiresAt.Equal(msg.Expires()) {
+		t.Fatalf("older message should not replace the newer signature")
+	}
+
+	ring.removeExpiredEdges(now.Add(30 * time.Minute))
+	if !ring.graph.HasEdgeBetween(ring.ids["A"], ring.ids["B"]) {
+		t.Fatalf("valid signature should not be removed")
+	}
+	ring.removeExpiredEdges(now.Add(2 * time.Hour))
+	if ring.graph.HasEdgeBetween(ring.ids["A"], ring.ids["B"]) {
+		t.Fatalf("expired signature should be removed")
+	}
+	if !ring.graph.HasEdgeBetween(ring.ids["source"], ring.ids["A"]) {
+		t.Fatalf("bootstrap signature should never expire")
+	}
+	ring.updateConfidence()
+	if rec, _ := ring.GetRecord("B"); rec.Confidence != 0 {
+		t.Fatalf("key with only expired signatures should have confidence 0, got %v", rec.Confidence)
+	}
+}
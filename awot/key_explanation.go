@@ -0,0 +1,207 @@
+package awot
+
+import (
+	"crypto"
+	"errors"
+	"math"
+
+	"gonum.org/v1/gonum/graph"
+)
+
+// An ExplainedNode is a signer on a shortest path to a key, with the trust put in it for signing keys
+type ExplainedNode struct {
+	Name        string
+	Distance    int     // number of signatures from the owner of the ring
+	Probability float32 // trust put in the peer for signing keys
+	Reputation  float32 // reputation term r of phi = min(1/d, r) last used for the peer, -1 if never computed
+	Reason      string  // how the probability was obtained
+}
+
+// An ExplainedPath is a shortest path from the owner of the ring to a key
+type ExplainedPath struct {
+	Nodes       []ExplainedNode // signers on the path, from the owner of the ring, the owner of the key excluded
+	Probability float32         // probability that every signer of the path is trustworthy
+}
+
+// An ExplainedKey is a key signed for a peer, with its support in the ring
+type ExplainedKey struct {
+	Fingerprint string
+	Signers     []string // peers that signed the key, sorted
+	Paths       int      // number of shortest paths ending with the key
+	Confidence  float32  // confidence the trust metric gives to the key
+	Selected    bool     // true if the key is the one given for the peer
+}
+
+// An InclusionExclusionTerm is a term of the inclusion-exclusion formula :
+// the signed sum, over every combination of Size paths, of the probability that all the paths of the combination are trustworthy
+type InclusionExclusionTerm struct {
+	Size  int     // number of paths combined
+	Sets  int     // number of combinations
+	Sign  int     // +1 or -1
+	Value float32 // signed sum of the probabilities of the combinations
+}
+
+// A KeyExplanation explains the confidence level of the key of a peer
+type KeyExplanation struct {
+	Owner       string
+	Fingerprint string                   // fingerprint of the selected key, empty if none
+	Confidence  float32                  // confidence level of the selected key
+	ErrorBound  float32                  // bound of the error of the confidence level, 0 if exact
+	Metric      string                   // name of the trust metric
+	Method      string                   // how the confidence level was obtained
+	Paths       []ExplainedPath          // shortest paths ending with the selected key
+	Keys        []ExplainedKey           // acceptable keys signed for the peer, competing if more than one
+	Terms       []InclusionExclusionTerm // terms of the inclusion-exclusion formula, if the confidence was computed with it
+}
+
+// Methods of the computation of a confidence level, see KeyExplanation
+const (
+	MethodSource             = "owner of the ring"
+	MethodImported           = "imported"
+	MethodNoKey              = "no acceptable key"
+	MethodInclusionExclusion = "inclusion-exclusion"
+	MethodFactoring          = "factoring"
+	MethodSampling           = "sampling"
+)
+
+////////// Key Ring API
+
+// Explain returns why the key of the peer with given name has its confidence level :
+// the shortest paths to the selected key with the probability and reputation of each signer, the competing keys,
+// and the terms of the inclusion-exclusion formula if the confidence was computed with it.
+// The explanation is computed from the current state of the ring, which may have changed since the last computation of the confidence levels.
+func (ring *KeyRing) Explain(name string) (KeyExplanation, error) {
+	rec, hasKey := ring.keyTable.get(name)
+
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+
+	vertex, present := ring.ids[name]
+	if !present {
+		return KeyExplanation{}, errors.New("unknown peer " + name)
+	}
+
+	explanation := KeyExplanation{
+		Owner:      name,
+		Confidence: rec.Confidence,
+		ErrorBound: rec.ConfidenceError,
+		Metric:     ring.confidence.metric.Name(),
+		Paths:      make([]ExplainedPath, 0),
+		Keys:       make([]ExplainedKey, 0),
+		Terms:      make([]InclusionExclusionTerm, 0),
+	}
+	var selected crypto.PublicKey
+	if hasKey && rec.KeyPub != nil {
+		selected = rec.KeyPub
+		explanation.Fingerprint = FingerprintGroups(selected)
+	}
+
+	if name == ring.source {
+		explanation.Method = MethodSource
+		return explanation, nil
+	}
+	if _, ok := ring.manual.imported(name); ok {
+		explanation.Method = MethodImported
+		return explanation, nil
+	}
+
+	sp := ring.searchFromSource()
+	tg := ring.trustGraph(sp)
+	paths := sp.allTo(*vertex, ring.confidence.config.MaxPaths)
+
+	for _, candidate := range ring.candidates(tg, name, vertex) {
+		explanation.Keys = append(explanation.Keys, ExplainedKey{
+			Fingerprint: FingerprintGroups(candidate.KeyPub),
+			Signers:     candidate.Signers,
+			Paths:       len(ring.pathsWithKey(paths, candidate.KeyPub)),
+			Confidence:  candidate.Confidence,
+			Selected:    selected != nil && keyID(candidate.KeyPub) == keyID(selected),
+		})
+	}
+
+	if selected == nil {
+		explanation.Method = MethodNoKey
+		return explanation, nil
+	}
+
+	bestPaths := ring.pathsWithKey(paths, selected)
+	for _, p := range bestPaths {
+		explanation.Paths = append(explanation.Paths, ring.explainPath(sp, p))
+	}
+
+	switch {
+	case explanation.Metric != NewPathMetric().Name():
+		explanation.Method = explanation.Metric
+	case len(bestPaths) <= ring.confidence.config.MaxExactPaths:
+		explanation.Method = MethodInclusionExclusion
+		explanation.Terms = inclusionExclusionTerms(bestPaths)
+	case rec.ConfidenceError > 0:
+		explanation.Method = MethodSampling
+	default:
+		explanation.Method = MethodFactoring
+	}
+	return explanation, nil
+}
+
+////////// Implementation
+
+// explainPath returns the explanation of the given shortest path, the terminal excluded
+// thread unsafe
+func (ring *KeyRing) explainPath(sp shortestPaths, p []graph.Node) ExplainedPath {
+	explained := ExplainedPath{
+		Nodes:       make([]ExplainedNode, 0, len(p)),
+		Probability: 1.0,
+	}
+	for i, n := range p {
+		if i == len(p)-1 {
+			break
+		}
+		node := ring.explainNode(sp, n.(Node))
+		explained.Nodes = append(explained.Nodes, node)
+		explained.Probability *= node.Probability
+	}
+	return explained
+}
+
+// explainNode returns the probability of the given node and how it was obtained
+// thread unsafe
+func (ring *KeyRing) explainNode(sp shortestPaths, n Node) ExplainedNode {
+	explained := ExplainedNode{
+		Name:        n.name,
+		Distance:    int(sp.distance(n)),
+		Probability: *(n.probability),
+		Reputation:  -1,
+	}
+	reputation, known := ring.reputations[n.name]
+	if known {
+		explained.Reputation = reputation
+	}
+
+	if n.name == ring.source {
+		explained.Reason = MethodSource
+		return explained
+	}
+	if _, ok := ring.manualProbability(n.name); ok {
+		explained.Reason = "manual trust decision"
+		return explained
+	}
+	if rec, ok := ring.keyTable.get(n.name); ok && ring.IsRevoked(n.name, rec.KeyPub) {
+		explained.Reason = "revoked key"
+		return explained
+	}
+	if !known {
+		explained.Reason = "initial trust"
+		return explained
+	}
+
+	inverseDistance := 1.0 / math.Max(sp.distance(n), 1)
+	phi := float32(math.Min(inverseDistance, float64(reputation)))
+	if ring.sybils.capProbability(n.name, phi) < phi {
+		explained.Reason = "capped, suspected Sybil identity"
+	} else if float64(reputation) < inverseDistance {
+		explained.Reason = "phi = reputation"
+	} else {
+		explained.Reason = "phi = 1/distance"
+	}
+	return explained
+}
@@ -0,0 +1,75 @@
+// Tests for the explanations of the confidence levels
+package awot
+
+import (
+	"crypto"
+	"math"
+	"testing"
+)
+
+// TestExplain tests that the explanation of a key gives its paths, the competing keys and the terms of its confidence
+func TestExplain(t *testing.T) {
+	keys := make(map[string]crypto.Signer)
+	for _, name := range []string{"source", "A", "B", "C", "C2", "D"} {
+		keys[name], _ = GenerateKey(KeyAlgorithmEd25519, 0)
+	}
+
+	// source fully trusts A and B, which sign C ; D, signed by A, signs another key for C
+	trusted := []TrustedKeyRecord{
+		{KeyRecord: KeyRecord{Owner: "A", KeyPub: keys["A"].Public()}, Confidence: 1.0},
+		{KeyRecord: KeyRecord{Owner: "B", KeyPub: keys["B"].Public()}, Confidence: 1.0},
+	}
+	ring := NewKeyRing("source", keys["source"].Public(), trusted, 0.0)
+	ring.Add(KeyRecord{Owner: "C", KeyPub: keys["C"].Public()}, "A", 1.0)
+	ring.Add(KeyRecord{Owner: "C", KeyPub: keys["C"].Public()}, "B", 1.0)
+	ring.Add(KeyRecord{Owner: "D", KeyPub: keys["D"].Public()}, "A", 1.0)
+	ring.Add(KeyRecord{Owner: "C", KeyPub: keys["C2"].Public()}, "D", 1.0)
+	ring.updateTrust(nil)
+	ring.updateAllConfidence()
+
+	explanation, err := ring.Explain("C")
+	if err != nil {
+		t.Fatalf("could not explain key of C: %v", err)
+	}
+	if explanation.Fingerprint != FingerprintGroups(keys["C"].Public()) || explanation.Method != MethodInclusionExclusion {
+		t.Fatalf("key of C should be selected and computed exactly, got %+v", explanation)
+	}
+	if len(explanation.Paths) != 2 {
+		t.Fatalf("key of C should have 2 shortest paths, got %v", len(explanation.Paths))
+	}
+	for _, p := range explanation.Paths {
+		if len(p.Nodes) != 2 || p.Nodes[0].Name != "source" || p.Nodes[1].Distance != 1 {
+			t.Fatalf("path should go through a bootstrap peer, got %+v", p)
+		}
+		if p.Nodes[1].Reputation != 1.0 || p.Nodes[1].Reason != "phi = 1/distance" {
+			t.Fatalf("trust of a bootstrap peer should be explained, got %+v", p.Nodes[1])
+		}
+	}
+
+	// the terms add up to the confidence
+	sum := float32(0.0)
+	for _, term := range explanation.Terms {
+		sum += term.Value
+	}
+	if len(explanation.Terms) != 2 || explanation.Terms[1].Sign != -1 || math.Abs(float64(sum-explanation.Confidence)) > 1e-6 {
+		t.Fatalf("terms should add up to the confidence %v, got %+v", explanation.Confidence, explanation.Terms)
+	}
+
+	// the competing key has no shortest path
+	if len(explanation.Keys) != 2 {
+		t.Fatalf("both keys of C should be listed, got %+v", explanation.Keys)
+	}
+	for _, k := range explanation.Keys {
+		selected := k.Fingerprint == explanation.Fingerprint
+		if k.Selected != selected || (selected && k.Paths != 2) || (!selected && (k.Paths != 0 || k.Signers[0] != "D")) {
+			t.Fatalf("key %+v is not explained correctly", k)
+		}
+	}
+
+	if explanation, _ = ring.Explain("source"); explanation.Method != MethodSource {
+		t.Fatalf("key of the source should be explained as such, got %v", explanation.Method)
+	}
+	if _, err = ring.Explain("unknown"); err == nil {
+		t.Fatalf("explaining an unknown peer should fail")
+	}
+}
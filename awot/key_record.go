@@ -2,9 +2,7 @@ package awot
 
 import (
 	"crypto"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/sha256"
+	"time"
 
 	"github.com/No-Trust/peerster/common"
 )
@@ -12,57 +10,68 @@ import (
 // A KeyRecord is an association between a public key and an owner
 type KeyRecord struct {
 	Owner  string
-	KeyPub rsa.PublicKey
+	KeyPub crypto.PublicKey
 }
 
 // A TrustedKeyRecord is a KeyRecord with a confidence level corresponding to the trust put in the KeyRecord
 type TrustedKeyRecord struct {
 	KeyRecord                              // the record publik key - owner
 	Confidence         float32             // confidence level in the assocatiation owner - public key
+	ConfidenceError    float32             // bound of the error of the confidence level, 0 if exact
 	keyExchangeMessage *KeyExchangeMessage // the key exchange message to be advertised in the future
 }
 
 // ConstructMessage constructs a KeyExchangeMessage from a TrustedKeyRecord and signs it if needed with given private key and origin name
-func (rec *TrustedKeyRecord) ConstructMessage(priK rsa.PrivateKey, origin string) KeyExchangeMessage {
+// The message is signed again if it has passed half of its validity, the new one being valid for the given duration.
+func (rec *TrustedKeyRecord) ConstructMessage(priK crypto.Signer, origin string, validity time.Duration) KeyExchangeMessage {
 
-	rec.sign(priK, origin)
+	rec.sign(priK, origin, validity)
 
 	msg := rec.keyExchangeMessage
 
 	return *msg
 }
 
-// sign signs a TrustedKeyRecord if not yet signed, using given private key and origin name
-func (rec *TrustedKeyRecord) sign(priK rsa.PrivateKey, origin string) TrustedKeyRecord {
-	if rec.keyExchangeMessage == nil {
+// sign signs a TrustedKeyRecord if not yet signed or if its signature has passed half of its validity,
+// using given private key and origin name
+func (rec *TrustedKeyRecord) sign(priK crypto.Signer, origin string, validity time.Duration) TrustedKeyRecord {
+	now := time.Now()
+	if rec.keyExchangeMessage == nil || rec.keyExchangeMessage.Origin != origin || needsRenewal(*rec.keyExchangeMessage, now) {
 
 		keybytes, _ := SerializeKey(rec.KeyRecord.KeyPub)
 
-		msg := create(keybytes, rec.KeyRecord.Owner, priK, origin)
+		msg := create(keybytes, rec.KeyRecord.Owner, priK, origin, now, validity)
 
 		rec.keyExchangeMessage = &msg
 	}
 	return *rec
 }
 
+// needsRenewal checks if the given message has passed half of its validity at given time
+func needsRenewal(msg KeyExchangeMessage, now time.Time) bool {
+	half := msg.Expires().Sub(msg.Issued()) / 2
+	return !now.Before(msg.Issued().Add(half))
+}
+
 // create creates a KeyExchangeMessage by signing the public key record using given private key and attaching given origin name to the signature
-func create(keybytes []byte, owner string, ownPrivateKey rsa.PrivateKey, origin string) KeyExchangeMessage {
+// The message is issued at given time and valid for given duration
+func create(keybytes []byte, owner string, ownPrivateKey crypto.Signer, origin string, issued time.Time, validity time.Duration) KeyExchangeMessage {
 
-	data := append(keybytes, []byte(owner)...)
+	issuedAt := issued.Unix()
+	expiresAt := issued.Add(validity).Unix()
 
-	newhash := sha256.New()
-	newhash.Write(data)
-	hashed := newhash.Sum(nil)
+	hashed := keyExchangeHash(KeyExchangeVersion, keybytes, owner, issuedAt, expiresAt)
 
-	var opts rsa.PSSOptions
-	opts.SaltLength = rsa.PSSSaltLengthAuto
-	signature, err := rsa.SignPSS(rand.Reader, &ownPrivateKey, crypto.SHA256, hashed, nil) //&opts)
+	signature, err := Sign(ownPrivateKey, hashed)
 	common.CheckError(err)
 
 	msg := KeyExchangeMessage{
+		Version:   KeyExchangeVersion,
 		KeyBytes:  keybytes,
 		Owner:     owner,
 		Origin:    origin,
+		IssuedAt:  issuedAt,
+		ExpiresAt: expiresAt,
 		Signature: signature,
 	}
 
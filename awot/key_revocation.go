@@ -0,0 +1,192 @@
+package awot
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"sync"
+
+	"github.com/No-Trust/peerster/common"
+)
+
+// revocationPrefix separates the signed data of a revocation from the one of a KeyExchangeMessage
+const revocationPrefix = "REVOKE"
+
+// A KeyRevocationMessage is a signed withdrawal of a relation (publickey - owner)
+// If Origin is Owner, the message is self-signed with the revoked key : the key is compromised and must not be used anymore.
+// Otherwise Origin withdraws its signature of the key, e.g. because it signed the key by mistake.
+type KeyRevocationMessage struct {
+	KeyBytes  []byte // serialized revoked public key
+	Owner     string // owner of the revoked public key
+	Origin    string // signer of the revocation
+	Signature []byte // signature of (REVOKE <-> keyPub <-> owner)
+}
+
+// IsSelfSigned returns true if the revocation is issued by the owner of the revoked key
+func (msg KeyRevocationMessage) IsSelfSigned() bool {
+	return msg.Owner == msg.Origin
+}
+
+// CreateRevocation creates a KeyRevocationMessage for the given key of owner, signed using given private key and attaching given origin name to the signature
+// For revoking its own key, a peer must sign with the revoked key itself.
+func CreateRevocation(key crypto.PublicKey, owner string, signerKey crypto.Signer, origin string) (KeyRevocationMessage, error) {
+	keybytes, err := SerializeKey(key)
+	if err != nil {
+		return KeyRevocationMessage{}, err
+	}
+
+	signature, err := Sign(signerKey, revocationHash(keybytes, owner))
+	common.CheckError(err)
+
+	msg := KeyRevocationMessage{
+		KeyBytes:  keybytes,
+		Owner:     owner,
+		Origin:    origin,
+		Signature: signature,
+	}
+
+	return msg, nil
+}
+
+// VerifyRevocation verifies that the received revocation is signed by the pretended origin
+// For a self-signed revocation, the given key should be the revoked key.
+// Returns nil if valid, an error otherwise
+func VerifyRevocation(msg KeyRevocationMessage, OriginKeyPub crypto.PublicKey) error {
+	return VerifySignature(OriginKeyPub, revocationHash(msg.KeyBytes, msg.Owner), msg.Signature)
+}
+
+// revocationHash returns the hash of the data signed in a revocation
+func revocationHash(keybytes []byte, owner string) []byte {
+	newhash := sha256.New()
+	newhash.Write([]byte(revocationPrefix))
+	newhash.Write(keybytes)
+	newhash.Write([]byte(owner))
+	return newhash.Sum(nil)
+}
+
+// A revocationList is the set of keys revoked by their owner, and of the signatures withdrawn by their signer, thread safe
+type revocationList struct {
+	revoked   map[string]map[string]KeyRevocationMessage // owner -> key id -> self-signed revocation
+	withdrawn map[string]KeyRevocationMessage            // origin/owner/key id -> withdrawal
+	mutex     *sync.Mutex
+}
+
+// newRevocationList creates an empty revocationList
+func newRevocationList() *revocationList {
+	return &revocationList{
+		revoked:   make(map[string]map[string]KeyRevocationMessage),
+		withdrawn: make(map[string]KeyRevocationMessage),
+		mutex:     &sync.Mutex{},
+	}
+}
+
+// add adds a self-signed revocation to the list
+func (list *revocationList) add(msg KeyRevocationMessage, key crypto.PublicKey) {
+	list.mutex.Lock()
+	defer list.mutex.Unlock()
+	if list.revoked[msg.Owner] == nil {
+		list.revoked[msg.Owner] = make(map[string]KeyRevocationMessage)
+	}
+	list.revoked[msg.Owner][keyID(key)] = msg
+}
+
+// contains checks if the given key of owner has been revoked
+func (list *revocationList) contains(owner string, key crypto.PublicKey) bool {
+	list.mutex.Lock()
+	defer list.mutex.Unlock()
+	_, present := list.revoked[owner][keyID(key)]
+	return present
+}
+
+// addWithdrawal adds the withdrawal of a signature to the list
+func (list *revocationList) addWithdrawal(msg KeyRevocationMessage, key crypto.PublicKey) {
+	list.mutex.Lock()
+	defer list.mutex.Unlock()
+	list.withdrawn[withdrawalID(msg.Origin, msg.Owner, key)] = msg
+}
+
+// isWithdrawn checks if origin withdrew its signature of the given key of owner
+func (list *revocationList) isWithdrawn(origin, owner string, key crypto.PublicKey) bool {
+	list.mutex.Lock()
+	defer list.mutex.Unlock()
+	_, present := list.withdrawn[withdrawalID(origin, owner, key)]
+	return present
+}
+
+// withdrawalID returns the key of the withdrawal of the signature of the given key of owner by origin
+func withdrawalID(origin, owner string, key crypto.PublicKey) string {
+	return origin + "/" + owner + "/" + keyID(key)
+}
+
+////////// Key Ring API
+
+// AddRevocation verifies the given revocation and updates the KeyRing accordingly.
+// A self-signed revocation removes every signature of the revoked key, which will never be returned again by GetKey.
+// Otherwise, only the signature of the key by the origin of the revocation is removed, and will not be added again.
+// Returns an error if the revocation could not be verified.
+func (ring *KeyRing) AddRevocation(msg KeyRevocationMessage) error {
+	revokedKey, err := DeserializeKey(msg.KeyBytes)
+	if err != nil {
+		return err
+	}
+
+	if msg.Owner == ring.source {
+		return errors.New("cannot revoke own key in own key ring")
+	}
+
+	if msg.IsSelfSigned() {
+		// signed with the revoked key itself
+		err = VerifyRevocation(msg, revokedKey)
+		if err != nil {
+			return err
+		}
+		ring.revocations.add(msg, revokedKey)
+		ring.removeEdgesWithKey("", msg.Owner, revokedKey)
+	} else {
+		// signed by a peer withdrawing its signature
+		originKey, present := ring.GetKey(msg.Origin)
+		if !present {
+			return errors.New("unknown key of revocation origin " + msg.Origin)
+		}
+		err = VerifyRevocation(msg, originKey)
+		if err != nil {
+			return err
+		}
+		ring.revocations.addWithdrawal(msg, revokedKey)
+		ring.removeEdgesWithKey(msg.Origin, msg.Owner, revokedKey)
+	}
+
+	ring.updateAllConfidence()
+	return nil
+}
+
+// IsRevoked checks if the given key of owner has been revoked by its owner
+func (ring KeyRing) IsRevoked(owner string, key crypto.PublicKey) bool {
+	return ring.revocations.contains(owner, key)
+}
+
+////////// Implementation
+
+// removeEdgesWithKey removes the edges to the node named b carrying the given key
+// If a is not empty, only the edge from the node named a is removed.
+func (ring *KeyRing) removeEdgesWithKey(a, b string, key crypto.PublicKey) {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+
+	vB, present := ring.ids[b]
+	if !present {
+		return
+	}
+	id := keyID(key)
+
+	for _, from := range ring.graph.To(*vB) {
+		if a != "" && from.(Node).name != a {
+			continue
+		}
+		edge := ring.graph.Edge(from, *vB)
+		if edge != nil && keyID(edge.(Edge).Key) == id {
+			ring.graph.RemoveEdge(edge)
+			ring.markDownstream(*vB)
+		}
+	}
+}
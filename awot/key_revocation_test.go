@@ -0,0 +1,141 @@
+// Tests for key revocations
+package awot
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+)
+
+// TestRevocationSigning tests that revocations are verified against the origin key only
+func TestRevocationSigning(t *testing.T) {
+	keyA, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("could not generate rsa key: %v", err)
+	}
+	keyB, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("could not generate rsa key: %v", err)
+	}
+
+	msg, err := CreateRevocation(keyB.PublicKey, "B", keyA, "A")
+	if err != nil {
+		t.Fatalf("could not create revocation: %v", err)
+	}
+	if msg.IsSelfSigned() {
+		t.Fatalf("revocation of B by A is not self signed")
+	}
+	if err = VerifyRevocation(msg, keyA.PublicKey); err != nil {
+		t.Fatalf("signature of revocation is wrong: %v", err)
+	}
+	if err = VerifyRevocation(msg, keyB.PublicKey); err == nil {
+		t.Fatalf("revocation signed by A should not be verified with key of B")
+	}
+
+	// a key exchange signature must not be usable as a revocation
+	keyBBytes, err := SerializeKey(keyB.PublicKey)
+	if err != nil {
+		t.Fatalf("could not serialize the key: %v", err)
+	}
+	exchange := create(keyBBytes, "B", keyA, "A", time.Now(), DefaultKeyValidity)
+	forged := KeyRevocationMessage{
+		KeyBytes:  exchange.KeyBytes,
+		Owner:     exchange.Owner,
+		Origin:    exchange.Origin,
+		Signature: exchange.Signature,
+	}
+	if err = VerifyRevocation(forged, keyA.PublicKey); err == nil {
+		t.Fatalf("key exchange signature should not be a valid revocation")
+	}
+}
+
+// TestKeyRingRevocation tests the effect of revocations on a KeyRing
+func TestKeyRingRevocation(t *testing.T) {
+	keys := make(map[string]*rsa.PrivateKey)
+	for _, name := range []string{"source", "A", "B", "C"} {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			t.Fatalf("could not generate rsa key: %v", err)
+		}
+		keys[name] = key
+	}
+
+	// source fully trusts A and C, both signed the key of B
+	trusted := []TrustedKeyRecord{
+		{KeyRecord: KeyRecord{Owner: "A", KeyPub: keys["A"].PublicKey}, Confidence: 1.0},
+		{KeyRecord: KeyRecord{Owner: "C", KeyPub: keys["C"].PublicKey}, Confidence: 1.0},
+	}
+	ring := NewKeyRing("source", keys["source"].PublicKey, trusted, 0.0)
+	recB := KeyRecord{Owner: "B", KeyPub: keys["B"].PublicKey}
+	ring.Add(recB, "A", 1.0)
+	ring.Add(recB, "C", 1.0)
+
+	t.Run("withdrawal", func(t *testing.T) {
+		msg, err := CreateRevocation(keys["B"].PublicKey, "B", keys["A"], "A")
+		if err != nil {
+			t.Fatalf("could not create revocation: %v", err)
+		}
+		if err = ring.AddRevocation(msg); err != nil {
+			t.Fatalf("could not add revocation: %v", err)
+		}
+		if ring.graph.HasEdgeBetween(ring.ids["A"], ring.ids["B"]) {
+			t.Fatalf("withdrawn signature of A on B should be removed")
+		}
+		if !ring.graph.HasEdgeBetween(ring.ids["C"], ring.ids["B"]) {
+			t.Fatalf("signature of C on B should not be removed")
+		}
+		if _, ok := ring.GetKey("B"); !ok {
+			t.Fatalf("key of B still signed by C should be returned")
+		}
+
+		// the original signature re-gossiped after the withdrawal is ignored
+		keyBBytes, err := SerializeKey(keys["B"].PublicKey)
+		if err != nil {
+			t.Fatalf("could not serialize the key: %v", err)
+		}
+		original := create(keyBBytes, "B", keys["A"], "A", time.Now(), DefaultKeyValidity)
+		if err = ring.AddMessage(original, 1.0); err != nil {
+			t.Fatalf("could not add the original message: %v", err)
+		}
+		ring.Add(recB, "A", 1.0)
+		if ring.graph.HasEdgeBetween(ring.ids["A"], ring.ids["B"]) {
+			t.Fatalf("withdrawn signature of A on B should not be restored by the original message")
+		}
+	})
+
+	t.Run("forged", func(t *testing.T) {
+		msg, err := CreateRevocation(keys["B"].PublicKey, "B", keys["A"], "C")
+		if err != nil {
+			t.Fatalf("could not create revocation: %v", err)
+		}
+		if err = ring.AddRevocation(msg); err == nil {
+			t.Fatalf("revocation not signed by its origin should be rejected")
+		}
+		if !ring.graph.HasEdgeBetween(ring.ids["C"], ring.ids["B"]) {
+			t.Fatalf("rejected revocation should not remove signatures")
+		}
+	})
+
+	t.Run("self signed", func(t *testing.T) {
+		msg, err := CreateRevocation(keys["B"].PublicKey, "B", keys["B"], "B")
+		if err != nil {
+			t.Fatalf("could not create revocation: %v", err)
+		}
+		if err = ring.AddRevocation(msg); err != nil {
+			t.Fatalf("could not add revocation: %v", err)
+		}
+		if _, ok := ring.GetKey("B"); ok {
+			t.Fatalf("revoked key of B should not be returned")
+		}
+		if !ring.IsRevoked("B", keys["B"].PublicKey) {
+			t.Fatalf("key of B should be revoked")
+		}
+
+		// signing the revoked key again has no effect
+		ring.Add(recB, "A", 1.0)
+		if _, ok := ring.GetKey("B"); ok {
+			t.Fatalf("revoked key of B should not be returned after a new signature")
+		}
+	})
+}
@@ -1,8 +1,7 @@
 package awot
 
 import (
-	"container/list"
-	"crypto/rsa"
+	"crypto"
 	"errors"
 	"log"
 	"math"
@@ -10,7 +9,6 @@ import (
 	"time"
 
 	"gonum.org/v1/gonum/graph"
-	"gonum.org/v1/gonum/graph/path"
 	"gonum.org/v1/gonum/graph/simple"
 )
 
@@ -22,9 +20,18 @@ type Node struct {
 }
 
 // An Edge is a directed edge F->T in the key ring, representing that F signed the key for T
+// An edge with a zero ExpiresAt never expires (e.g. bootstrap or imported keys).
 type Edge struct {
-	F, T Node
-	Key  rsa.PublicKey
+	F, T      Node
+	Key       crypto.PublicKey
+	IssuedAt  time.Time           // time of the signature
+	ExpiresAt time.Time           // expiration of the signature
+	message   *KeyExchangeMessage // the signed message, nil if the key was not received in a KeyExchangeMessage
+}
+
+// Expired checks if the signature represented by the edge is expired at given time
+func (e Edge) Expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && now.After(e.ExpiresAt)
 }
 
 // From returns the from-node of the edge.
@@ -47,16 +54,24 @@ type ReputationTable interface {
 
 // A KeyRing is a directed graph of Node and Edge
 type KeyRing struct {
-	source       string               // the id of the source in the keyring
-	ids          map[string]*Node     // name -> Node mapping
-	graph        simple.DirectedGraph // graph
-	nextNode     int64                // for instanciating new nodes
-	keyTable                          // for updates
-	pending      *list.List           // pending KeyExchangeMessage
-	pendingMutex *sync.Mutex          // mutex for pending KeyExchangeMessage
-	mutex        *sync.Mutex          // mutex for the keyring itself
-	threshold    float32              // confidence threshold for trusted keys
-	stopped      bool                 // indicator for the state of the ring
+	source      string                      // the id of the source in the keyring
+	ids         map[string]*Node            // name -> Node mapping
+	graph       simple.DirectedGraph        // graph
+	nextNode    int64                       // for instanciating new nodes
+	keyTable                                // for updates
+	pending     *pendingStore               // KeyExchangeMessage waiting for the key of their signer
+	mutex       *sync.Mutex                 // mutex for the keyring itself
+	threshold   float32                     // confidence threshold for trusted keys
+	stopped     bool                        // indicator for the state of the ring
+	manual      *manualTrust                // manual trust decisions of the user
+	revocations *revocationList             // keys revoked by their owner
+	transitions *transitionList             // key rotations of the peers
+	collisions  *collisionTracker           // competing keys for the same peer
+	confidence  *confidenceEngine           // state of the computation of the confidence levels
+	sybils      *sybilAnalysis              // clusters of suspicious peers
+	reputations map[string]float32          // name -> reputation term last used in phi, see Explain
+	bootstrap   map[string]TrustedKeyRecord // owner -> fully trusted bootstrap record, restored by ResetTrust
+	signed      *signedEdges                // signatures of the owner of the ring made for Export and Signatures
 }
 
 ////////// Key Ring API
@@ -68,7 +83,7 @@ type KeyRing struct {
 // 	key : the public key of owner
 // 	trustedRecords : the fully trusted bootstrap records : trusted public keys of initiators
 // 	threshold : the confidence threshold; below it the keys will not be given to the user
-func NewKeyRing(owner string, key rsa.PublicKey, trustedRecords []TrustedKeyRecord, threshold float32) KeyRing {
+func NewKeyRing(owner string, key crypto.PublicKey, trustedRecords []TrustedKeyRecord, threshold float32) KeyRing {
 
 	keyTable := newKeyTable(owner, key)
 	nextNode := int64(0)
@@ -100,7 +115,10 @@ func NewKeyRing(owner string, key rsa.PublicKey, trustedRecords []TrustedKeyReco
 	})
 
 	// add each fully trusted key
+	bootstrap := make(map[string]TrustedKeyRecord)
 	for _, rec := range trustedRecords {
+		bootstrap[rec.Owner] = rec
+
 		// add node to graph
 		p := float32(1.0)
 		node := Node{
@@ -119,7 +137,7 @@ func NewKeyRing(owner string, key rsa.PublicKey, trustedRecords []TrustedKeyReco
 		edge := Edge{
 			F:   source,
 			T:   node,
-			Key: rec.KeyPub,
+			Key: normalizeKey(rec.KeyPub),
 		}
 		graph.SetEdge(edge)
 
@@ -135,16 +153,24 @@ func NewKeyRing(owner string, key rsa.PublicKey, trustedRecords []TrustedKeyReco
 	}
 
 	ring := KeyRing{
-		source:       owner,
-		ids:          ids,
-		graph:        *graph,
-		nextNode:     nextNode,
-		keyTable:     keyTable,
-		pending:      list.New(),
-		pendingMutex: &sync.Mutex{},
-		mutex:        &sync.Mutex{},
-		threshold:    threshold,
-		stopped:      false,
+		source:      owner,
+		ids:         ids,
+		graph:       *graph,
+		nextNode:    nextNode,
+		keyTable:    keyTable,
+		pending:     newPendingStore(DefaultPendingConfig()),
+		mutex:       &sync.Mutex{},
+		threshold:   threshold,
+		stopped:     false,
+		manual:      newManualTrust(),
+		revocations: newRevocationList(),
+		transitions: newTransitionList(),
+		collisions:  newCollisionTracker(),
+		confidence:  newConfidenceEngine(DefaultConfidenceConfig()),
+		sybils:      newSybilAnalysis(DefaultSybilConfig()),
+		reputations: make(map[string]float32),
+		bootstrap:   bootstrap,
+		signed:      newSignedEdges(),
 	}
 	// return
 	return ring
@@ -173,14 +199,29 @@ func (ring *KeyRing) Stop() {
 // GetKey returns the key of peer with given name and true if it exists, otherwise returns false.
 // If the confidence level is too low for the key, it does not return the key and reports as if there where none.
 // This should be used e.g. when trying to communicate with a peer and threfore needing its key.
-func (ring KeyRing) GetKey(name string) (rsa.PublicKey, bool) {
+func (ring KeyRing) GetKey(name string) (crypto.PublicKey, bool) {
 	rec, ok := ring.keyTable.get(name)
 	if !ok {
 		return rec.KeyPub, ok
 	}
 
 	if rec.Confidence < ring.threshold {
-		return rsa.PublicKey{}, false
+		return nil, false
+	}
+
+	if !ring.manual.accepts(name, rec.KeyPub) {
+		// distrusted or not pinned key
+		return nil, false
+	}
+
+	if ring.IsRevoked(name, rec.KeyPub) {
+		// revoked by its owner
+		return nil, false
+	}
+
+	if ring.collisions.rejects(name) {
+		// several keys for this peer, none selected
+		return nil, false
 	}
 
 	return rec.KeyPub, ok
@@ -193,21 +234,53 @@ func (ring KeyRing) GetRecord(name string) (TrustedKeyRecord, bool) {
 	return ring.keyTable.get(name)
 }
 
+// FullyTrustedKeys returns the records of the keys with a confidence level of 100% : the bootstrap keys,
+// the keys imported or confirmed by the user, and the keys fully trusted through the signatures of the others
+func (ring KeyRing) FullyTrustedKeys() []TrustedKeyRecord {
+	return ring.keyTable.getFullyTrustedKeys()
+}
+
 // GetPeerList returns the list of peer names the keyring has a public key for
 func (ring KeyRing) GetPeerList() []string {
 	return ring.keyTable.getPeerList()
 }
 
 // AddUnverified adds a KeyExchangeMessage that could not yet be verified (e.g. lack of signer's key)
+// It is verified once the key of its signer is known, unless it is dropped before (see PendingConfig).
 func (ring *KeyRing) AddUnverified(msg KeyExchangeMessage) {
-	ring.mutex.Lock()
-	defer ring.mutex.Unlock()
-	ring.pending.PushBack(msg)
+	ring.pending.add(msg, time.Now())
 }
 
 // Add updates the key ring with the given (verified) keyrecord and origin of the signature
 // It assumes that the record's signature has been verified
+// The signature never expires, for signatures with a validity use AddMessage.
 func (ring *KeyRing) Add(rec KeyRecord, sigOrigin string, reputationOwner float32) {
+	ring.add(rec, sigOrigin, reputationOwner, nil)
+}
+
+// AddMessage updates the key ring with the given (verified) KeyExchangeMessage
+// It assumes that the message's signature has been verified
+// The signature is dropped from the ring when the message expires, and a message older than the one known for the same signature is ignored.
+func (ring *KeyRing) AddMessage(msg KeyExchangeMessage, reputationOwner float32) error {
+	key, err := DeserializeKey(msg.KeyBytes)
+	if err != nil {
+		return err
+	}
+	if msg.Expired(time.Now()) {
+		return errors.New("key exchange message expired")
+	}
+	rec := KeyRecord{
+		Owner:  msg.Owner,
+		KeyPub: key,
+	}
+	ring.add(rec, msg.Origin, reputationOwner, &msg)
+	return nil
+}
+
+////////// Key Ring Implementation
+
+// add updates the key ring with the given (verified) keyrecord and origin of the signature, and the message carrying it if any
+func (ring *KeyRing) add(rec KeyRecord, sigOrigin string, reputationOwner float32, msg *KeyExchangeMessage) {
 	// do not update if the signer is unknown
 	if !ring.contains(sigOrigin) {
 		return
@@ -218,11 +291,24 @@ func (ring *KeyRing) Add(rec KeyRecord, sigOrigin string, reputationOwner float3
 		return
 	}
 
+	if ring.IsRevoked(rec.Owner, rec.KeyPub) {
+		// the owner revoked this key
+		return
+	}
+
+	// a signature of a rotated key is a signature of its latest key
+	rec.KeyPub = ring.transitions.latest(rec.Owner, rec.KeyPub)
+
+	if ring.revocations.isWithdrawn(sigOrigin, rec.Owner, rec.KeyPub) {
+		// the signer withdrew this signature, re-gossiped copies of it are ignored
+		return
+	}
+
 	// add owner of the key if not yet known, or update its probability
 	ring.addNode(rec.Owner, 0.0)
 
 	// add edge
-	err := ring.addEdge(sigOrigin, rec.Owner, rec.KeyPub)
+	err := ring.addSignedEdge(sigOrigin, rec.Owner, rec.KeyPub, msg)
 
 	if err != nil {
 		log.Fatal("KeyRing Add : could not add edge")
@@ -244,8 +330,6 @@ func (ring *KeyRing) Add(rec KeyRecord, sigOrigin string, reputationOwner float3
 	ring.updateConfidence()
 }
 
-////////// Key Ring Implementation
-
 // worker performs periodic updates on a keyring, at given rate
 func (ring *KeyRing) worker(rate time.Duration, reptable ReputationTable) {
 	// updating the ring with yet unverified pending messages
@@ -256,6 +340,7 @@ func (ring *KeyRing) worker(rate time.Duration, reptable ReputationTable) {
 			if ring.stopped {
 				break
 			}
+			ring.removeExpiredEdges(time.Now())
 			ring.updateTrust(reptable)
 			ring.updatePending(reptable)
 			ring.updateConfidence()
@@ -265,8 +350,23 @@ func (ring *KeyRing) worker(rate time.Duration, reptable ReputationTable) {
 
 // updateTrust recomputes the trust associated with each node to account for reputation updates or ring updates
 func (ring *KeyRing) updateTrust(reptable ReputationTable) {
+	ring.AnalyzeSybils()
+
+	ring.mutex.Lock()
+	sp := ring.searchFromSource()
+	ring.mutex.Unlock()
 
 	for name := range ring.ids {
+		if probability, ok := ring.manualProbability(name); ok {
+			// the user decided for this node
+			ring.addNode(name, probability)
+			continue
+		}
+		if rec, ok := ring.keyTable.get(name); ok && ring.IsRevoked(name, rec.KeyPub) {
+			// the only known key of this node is revoked
+			ring.addNode(name, 0.0)
+			continue
+		}
 		present := false
 		rep := float32(0.5)
 		if reptable != nil {
@@ -275,36 +375,64 @@ func (ring *KeyRing) updateTrust(reptable ReputationTable) {
 		if !present {
 			rep = 0.5
 		}
-		probability := ring.phi(name, 2*rep)
+		probability := ring.phiFrom(sp, name, 2*rep)
+		// limit the trust put in suspected Sybil identities
+		probability = ring.sybils.capProbability(name, probability)
 		ring.addNode(name, probability)
 	}
 }
 
 // updateConfidence updates the key table by computing new confidence levels for each key
+// The collision handler is called for each new collision of keys
 func (ring *KeyRing) updateConfidence() {
+	collisions := ring.computeConfidence()
+	ring.collisions.notify(collisions)
+}
+
+// computeConfidence updates the key table by computing new confidence levels for the keys whose shortest paths changed,
+// and returns the new collisions
+func (ring *KeyRing) computeConfidence() []KeyCollision {
 	ring.mutex.Lock()
 	defer ring.mutex.Unlock()
 
-	allShortest := path.DijkstraAllPaths(&ring.graph)
+	collisions := make([]KeyCollision, 0)
 
-	source := ring.graph.Node(ring.ids[ring.source].id)
+	sp := ring.searchFromSource()
+	tg := ring.trustGraph(sp)
 
-	// compute for each node
+	// compute for each node that changed
 	for terminalName, terminalVertex := range ring.ids {
+		if !ring.confidence.needsUpdate(terminalName) || terminalName == ring.source {
+			continue
+		}
+		if rec, ok := ring.manual.imported(terminalName); ok {
+			// the user imported this key, its confidence is not recomputed
+			ring.keyTable.updateConfidence(terminalName, rec.Confidence, rec.KeyPub)
+			continue
+		}
 		terminal := ring.graph.Node(terminalVertex.id)
 		// get shortest paths from source to node
-		minpaths, _ := allShortest.AllBetween(source, terminal)
-		minpaths, bestKey := ring.selectBestPaths(minpaths)
-		probability := probabilityOfMinPaths(minpaths)
+		minpaths := sp.allTo(terminal, ring.confidence.config.MaxPaths)
+		_, bestKey, collision := ring.resolveCollision(tg, terminalName, terminalVertex, minpaths)
+		if collision != nil {
+			collisions = append(collisions, *collision)
+		}
+		probability, errorBound := float32(0.0), float32(0.0)
+		if bestKey != nil {
+			probability, errorBound = ring.confidence.metric.Confidence(tg, terminalName, bestKey)
+		}
 		// update the key table
-		ring.keyTable.updateConfidence(terminalName, probability, bestKey)
+		ring.keyTable.updateConfidenceWithError(terminalName, probability, errorBound, bestKey)
 	}
+	ring.confidence.done()
+	return collisions
 }
 
-// selectBestPaths takes a set of paths and returns the biggest subset in which all paths corresponds to the same end public key
+// selectBestPaths takes a set of paths to the peer with given name and returns the biggest subset in which all paths corresponds to the same end public key
 // the key chosen is the one corresponding to the maximum number of paths
+// paths ending with a key rejected by the manual trust decisions are ignored
 // thread unsafe
-func (ring KeyRing) selectBestPaths(paths [][]graph.Node) ([][]graph.Node, *rsa.PublicKey) {
+func (ring KeyRing) selectBestPaths(name string, paths [][]graph.Node) ([][]graph.Node, crypto.PublicKey) {
 	if len(paths) == 0 {
 		return paths, nil
 	} else if len(paths) == 1 {
@@ -312,15 +440,11 @@ func (ring KeyRing) selectBestPaths(paths [][]graph.Node) ([][]graph.Node, *rsa.
 		if len(p) < 2 {
 			return paths, nil
 		}
-		s := p[len(p)-2]
-		t := p[len(p)-1]
-
-		edge := ring.graph.Edge(s, t)
-		if edge == nil {
-			log.Fatal("edge disappeared")
+		key := ring.lastKey(p)
+		if !ring.manual.accepts(name, key) {
+			return nil, nil
 		}
-		key := edge.(Edge).Key
-		return paths, &key
+		return paths, key
 	}
 
 	occurrences := make(map[string]int)
@@ -333,15 +457,11 @@ func (ring KeyRing) selectBestPaths(paths [][]graph.Node) ([][]graph.Node, *rsa.
 			// siging itself should not happen
 			continue
 		}
-		s := p[len(p)-2]
-		t := p[len(p)-1]
-
-		edge := ring.graph.Edge(s, t)
-		if edge == nil {
-			log.Fatal("edge disappeared")
+		key := ring.lastKey(p)
+		if !ring.manual.accepts(name, key) {
+			continue
 		}
-		key := edge.(Edge).Key
-		occurrences[key.N.String()+"-"+string(key.E)] += 1
+		occurrences[keyID(key)] += 1
 	}
 
 	// find max
@@ -354,87 +474,52 @@ func (ring KeyRing) selectBestPaths(paths [][]graph.Node) ([][]graph.Node, *rsa.
 		}
 	}
 
+	if max == 0 {
+		// no acceptable key
+		return nil, nil
+	}
+
 	bestPaths := make([][]graph.Node, 0)
-	var bestKey rsa.PublicKey
+	var bestKey crypto.PublicKey
 	for _, p := range paths {
 		if len(p) < 2 {
 			continue
 		}
-		s := p[len(p)-2]
-		t := p[len(p)-1]
-
-		edge := ring.graph.Edge(s, t)
-		if edge == nil {
-			log.Fatal("edge disappeared")
-		}
-		key := edge.(Edge).Key
-		if bkey == key.N.String()+"-"+string(key.E) {
+		key := ring.lastKey(p)
+		if bkey == keyID(key) {
 			bestPaths = append(bestPaths, p)
 			bestKey = key
 		}
 	}
 
-	return bestPaths, &bestKey
-}
-
-// updateMessage updates a KeyRing with given message, if update successful return true
-// an update is successful if the update was performed or enough information is known to declare the message not correct / trustworthy
-func (ring *KeyRing) updateMessage(msg KeyExchangeMessage, confidenceOwner float32) bool {
-	receivedKey, err := DeserializeKey(msg.KeyBytes)
-	if err != nil {
-		return true
-	}
-
-	record := KeyRecord{
-		Owner:  msg.Owner,
-		KeyPub: receivedKey,
-	}
-
-	kpub, present := ring.GetKey(msg.Origin)
-
-	if !present {
-		// still do not have a public key
-		return false
-	}
-	err = Verify(msg, kpub)
-
-	if err == nil {
-		ring.Add(record, msg.Origin, confidenceOwner)
-		return true
-	}
-	return false
+	return bestPaths, bestKey
 }
 
-// updatePending tries to update the KeyRing with old pending messages
-func (ring *KeyRing) updatePending(reptable ReputationTable) {
-	ring.pendingMutex.Lock()
-	defer ring.pendingMutex.Unlock()
-
-	toRemove := list.New()
-	for e := ring.pending.Front(); e != nil; e = e.Next() {
-
-		msg := e.Value.(KeyExchangeMessage)
-		ok := false
-		reputationOwner := float32(0.5)
-		if reptable != nil {
-			reputationOwner, ok = reptable.Reputation(msg.Owner)
-		}
-		if !ok {
-			reputationOwner = 0.5
-		}
-		if ring.updateMessage(msg, 2*reputationOwner) {
-			toRemove.PushBack(e)
-		}
-	}
+// lastKey returns the public key of the last edge of the given path, that is the key signed for the terminal
+// thread unsafe
+func (ring KeyRing) lastKey(p []graph.Node) crypto.PublicKey {
+	s := p[len(p)-2]
+	t := p[len(p)-1]
 
-	for e := ring.pending.Front(); e != nil; e = e.Next() {
-		ring.pending.Remove(e)
+	edge := ring.graph.Edge(s, t)
+	if edge == nil {
+		log.Fatal("edge disappeared")
 	}
+	return edge.(Edge).Key
 }
 
 // phi computes the probability of the node, independently of its current probability
 // the probability is the trust put in a node for advertising public keys
 func (ring KeyRing) phi(name string, reputation float32) float32 {
+	ring.mutex.Lock()
+	sp := ring.searchFromSource()
+	ring.mutex.Unlock()
+
+	return ring.phiFrom(sp, name, reputation)
+}
+
+// phiFrom computes phi as above, given the shortest paths from the source
+func (ring KeyRing) phiFrom(sp shortestPaths, name string, reputation float32) float32 {
 	ring.mutex.Lock()
 	defer ring.mutex.Unlock()
 
@@ -444,13 +529,12 @@ func (ring KeyRing) phi(name string, reputation float32) float32 {
 	}
 
 	// phi = min(1/d, rep)
+	ring.reputations[name] = reputation
 
 	destNode := ring.graph.Node(ring.ids[name].id)
-	sourceNode := ring.graph.Node(ring.ids[ring.source].id)
 
-	// compute the distance from source to destination
-	shortest := path.DijkstraFrom(sourceNode, &ring.graph)
-	distance := shortest.WeightTo(destNode)
+	// the distance from source to destination
+	distance := sp.distance(destNode)
 
 	if distance == 0 {
 		distance = 1
@@ -488,7 +572,10 @@ func (ring *KeyRing) addNode(name string, probability float32) {
 	// check if already in KeyRing
 	if vp, present := ring.ids[name]; present {
 		// update the probability
-		*(vp.probability) = probability
+		if *(vp.probability) != probability {
+			*(vp.probability) = probability
+			ring.markDownstream(*vp)
+		}
 		return
 	}
 
@@ -501,12 +588,21 @@ func (ring *KeyRing) addNode(name string, probability float32) {
 	ring.nextNode += 1
 	ring.graph.AddNode(node)
 	ring.ids[name] = &node
+	ring.markDownstream(node)
 
 	return
 }
 
 // addEdge adds a directed edge from node named a to node named b, given the public key associated with the signature from a of b's key (that is the supposed key of a)
-func (ring *KeyRing) addEdge(a, b string, key rsa.PublicKey) error {
+// The edge never expires
+func (ring *KeyRing) addEdge(a, b string, key crypto.PublicKey) error {
+	return ring.addSignedEdge(a, b, key, nil)
+}
+
+// addSignedEdge adds a directed edge as addEdge, for the signature carried by the given message, valid between its issue and expiration times
+// A nil message gives an edge that never expires.
+// If the same signature is already known with a later issue time, the edge is kept as is
+func (ring *KeyRing) addSignedEdge(a, b string, key crypto.PublicKey, msg *KeyExchangeMessage) error {
 	ring.mutex.Lock()
 	defer ring.mutex.Unlock()
 
@@ -527,6 +623,54 @@ func (ring *KeyRing) addEdge(a, b string, key rsa.PublicKey) error {
 	vA := ring.ids[a]
 	vB := ring.ids[b]
 
-	ring.graph.SetEdge(Edge{F: *vA, T: *vB, Key: key})
+	issued, expires := time.Time{}, time.Time{}
+	if msg != nil {
+		issued, expires = msg.Issued(), msg.Expires()
+		if signed, err := DeserializeKey(msg.KeyBytes); err != nil || keyID(signed) != keyID(key) {
+			// the message signs a previous key of b, it cannot be kept as the signature of the edge
+			msg = nil
+		}
+	}
+
+	if edge := ring.graph.Edge(*vA, *vB); edge != nil {
+		old := edge.(Edge)
+		if keyID(old.Key) == keyID(key) && old.IssuedAt.After(issued) {
+			// replayed older signature
+			return nil
+		}
+	}
+
+	ring.graph.SetEdge(Edge{F: *vA, T: *vB, Key: normalizeKey(key), IssuedAt: issued, ExpiresAt: expires, message: msg})
+	ring.markDownstream(*vB)
 	return nil
 }
+
+// removeExpiredEdges removes the edges whose signature is expired at given time
+func (ring *KeyRing) removeExpiredEdges(now time.Time) {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+
+	for _, edge := range ring.graph.Edges() {
+		if edge.(Edge).Expired(now) {
+			ring.graph.RemoveEdge(edge)
+			ring.markDownstream(edge.(Edge).T)
+		}
+	}
+}
+
+// removeEdge removes the directed edge from node named a to node named b, if it exists
+func (ring *KeyRing) removeEdge(a, b string) {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+
+	vA, aPresent := ring.ids[a]
+	vB, bPresent := ring.ids[b]
+	if !aPresent || !bPresent {
+		return
+	}
+
+	if edge := ring.graph.Edge(*vA, *vB); edge != nil {
+		ring.graph.RemoveEdge(edge)
+		ring.markDownstream(*vB)
+	}
+}
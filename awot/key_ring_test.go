@@ -246,7 +246,7 @@ func TestKeyRing(t *testing.T) {
 				t.Fatalf("could not serialize rsa public key: %v", err)
 			}
 
-			msg := create(bs, oid, tp.key, tp.id)
+			msg := create(bs, oid, tp.key, tp.id, time.Now(), DefaultKeyValidity)
 			msgs = append(msgs, msg)
 		}
 	}
@@ -1,10 +1,6 @@
 package awot
 
 import (
-	"crypto/md5"
-	"crypto/rsa"
-	"encoding/binary"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -18,6 +14,10 @@ type VertexViz struct {
 	Name        string
 	Probability float32
 	Confidence  float32
+	Suspicion   float32 // suspicion of being a Sybil identity, from the last Sybil analysis
+	Suspected   bool    // true if the suspicion reaches the threshold of the SybilConfig, the probability being capped
+	Fingerprint string  // fingerprint of the selected key, as hex groups
+	SAS         string  // short authentication string of the selected key and the key of the source
 }
 
 // EdgeViz is a Vertex for a visualization of a KeyRing
@@ -65,6 +65,8 @@ func GraphVizRepr(ring KeyRing) GraphViz {
 	ring.mutex.Lock()
 	defer ring.mutex.Unlock()
 
+	sourceKey, _ := ring.keyTable.getKey(ring.source)
+
 	nodes := make([]VertexViz, 0)
 	rnodes := ring.graph.Nodes()
 	for _, node := range rnodes {
@@ -75,6 +77,12 @@ func GraphVizRepr(ring KeyRing) GraphViz {
 			Name:        n.name,
 			Probability: *n.probability,
 			Confidence:  rec.Confidence,
+			Suspicion:   ring.sybils.suspicion(n.name),
+			Suspected:   ring.sybils.suspected(n.name),
+		}
+		if key, ok := ring.keyTable.getKey(n.name); ok {
+			v.Fingerprint = FingerprintGroups(key)
+			v.SAS = ShortAuthString(sourceKey, key)
 		}
 		nodes = append(nodes, v)
 	}
@@ -96,21 +104,3 @@ func GraphVizRepr(ring KeyRing) GraphViz {
 		Links: links,
 	}
 }
-
-// Fingerprint returns the hex formatted fingerprint of the given rsa public key
-func Fingerprint(pub rsa.PublicKey) string {
-	h := md5.New()
-	binary.Write(h, binary.LittleEndian, pub.E)
-	binary.Write(h, binary.LittleEndian, *pub.N)
-	re := h.Sum(nil)
-
-	re2 := make([]byte, hex.EncodedLen(len(re)))
-	hex.Encode(re2, re)
-	s := string(re2)
-
-	// add ":" every two char
-	for i := 2; i < len(s); i += 3 {
-		s = s[:i] + ":" + s[i:]
-	}
-	return s
-}
@@ -1,24 +1,30 @@
 package awot
 
 import (
-	"crypto/rsa"
+	"crypto"
+	"crypto/ed25519"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
 	"fmt"
-
-	"github.com/No-Trust/peerster/common"
 )
 
 // SerializeKey encodes the given public key to a x509 format and serializes it to a pem format
-func SerializeKey(key rsa.PublicKey) ([]byte, error) {
-	PubASN1, err := x509.MarshalPKIXPublicKey(&key)
+// RSA keys keep the "RSA PUBLIC KEY" block type understood by the older peers.
+func SerializeKey(key crypto.PublicKey) ([]byte, error) {
+	blockType := "PUBLIC KEY"
+	if k, ok := rsaKey(key); ok {
+		key = k
+		blockType = "RSA PUBLIC KEY"
+	}
+
+	PubASN1, err := x509.MarshalPKIXPublicKey(key)
 	if err != nil {
 		return nil, fmt.Errorf("could not marshal public key to x509: %v", err)
 	}
 
 	data := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA PUBLIC KEY",
+		Type:  blockType,
 		Bytes: PubASN1,
 	})
 
@@ -26,22 +32,26 @@ func SerializeKey(key rsa.PublicKey) ([]byte, error) {
 }
 
 // DeserializeKey deserializes a pem encoded x509 public key
-func DeserializeKey(bytes []byte) (rsa.PublicKey, error) {
+// The key is either a crypto.PublicKey or an ed25519.PublicKey
+func DeserializeKey(bytes []byte) (crypto.PublicKey, error) {
 	pemBlock, _ := pem.Decode(bytes)
 	if pemBlock == nil {
 		fmt.Println("pem block nil")
-		return rsa.PublicKey{}, errors.New("Key bytes does not conform to pem encoding")
+		return nil, errors.New("Key bytes does not conform to pem encoding")
 	}
 
 	keypub, err := x509.ParsePKIXPublicKey(pemBlock.Bytes)
-	common.CheckError(err)
-
-	original, ok := keypub.(*rsa.PublicKey)
-	if !ok {
-		fmt.Println("not ok")
-		return rsa.PublicKey{}, errors.New("Key does not conform to rsa")
+	if err != nil {
+		return nil, fmt.Errorf("could not parse x509 public key: %v", err)
 	}
 
-	return *original, nil
+	if _, ok := rsaKey(keypub); ok {
+		return keypub, nil
+	}
+	if _, ok := keypub.(ed25519.PublicKey); ok {
+		return keypub, nil
+	}
 
+	fmt.Println("not ok")
+	return nil, errors.New("Key does not conform to rsa or ed25519")
 }
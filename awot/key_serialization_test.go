@@ -2,14 +2,15 @@
 package awot
 
 import (
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"testing"
 )
 
 // pubKeyEquals checks for equality of given public keys and return true if they are equal
-func pubKeyEquals(a rsa.PublicKey, b rsa.PublicKey) bool {
-	return a.E == b.E && a.N.Cmp(b.N) == 0
+func pubKeyEquals(a crypto.PublicKey, b crypto.PublicKey) bool {
+	return keyID(a) != "" && keyID(a) == keyID(b)
 }
 
 // TestDeSerialization tests functions SerializeKey and DeserializeKey
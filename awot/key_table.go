@@ -1,7 +1,7 @@
 package awot
 
 import (
-	"crypto/rsa"
+	"crypto"
 	"sync"
 )
 
@@ -24,6 +24,7 @@ func (table keyTable) getPeerList() []string {
 
 // add adds a record to the key table, overwrites it if it already exists
 func (table *keyTable) add(rec TrustedKeyRecord) {
+	rec.KeyPub = normalizeKey(rec.KeyPub)
 	table.mutex.Lock()
 	table.db[rec.Owner] = rec
 	table.mutex.Unlock()
@@ -45,7 +46,7 @@ func newEmptyKeyTable() keyTable {
 }
 
 // NewKeyTable creates a new keyTable with own's key
-func newKeyTable(owner string, key rsa.PublicKey) keyTable {
+func newKeyTable(owner string, key crypto.PublicKey) keyTable {
 	table := newEmptyKeyTable()
 	table.add(TrustedKeyRecord{
 		KeyRecord: KeyRecord{
@@ -69,50 +70,49 @@ func (table keyTable) get(name string) (TrustedKeyRecord, bool) {
 // updateConfidence updates the confidence of the association key - peer, with peer's name given
 // If the association does not exist yet, do nothing if the key is not present
 // If a key is given, overwrites present key
-func (table *keyTable) updateConfidence(name string, confidence float32, key *rsa.PublicKey) {
+func (table *keyTable) updateConfidence(name string, confidence float32, key crypto.PublicKey) {
+	table.updateConfidenceWithError(name, confidence, 0.0, key)
+}
+
+// updateConfidenceWithError updates the confidence of the association key - peer as updateConfidence, with the bound of the error of the confidence
+func (table *keyTable) updateConfidenceWithError(name string, confidence, errorBound float32, key crypto.PublicKey) {
 	table.mutex.Lock()
 	r, present := table.db[name]
 	if present {
 		if key != nil {
-			r.KeyPub = *key
+			r.KeyPub = normalizeKey(key)
 		}
 		r.Confidence = confidence
+		r.ConfidenceError = errorBound
 		table.db[name] = r
 	} else if key != nil {
 		table.db[name] = TrustedKeyRecord{
 			KeyRecord: KeyRecord{
 				Owner:  name,
-				KeyPub: *key,
+				KeyPub: normalizeKey(key),
 			},
-			Confidence: confidence,
+			Confidence:      confidence,
+			ConfidenceError: errorBound,
 		}
 	}
 	table.mutex.Unlock()
 }
 
 // getKey returns the key of peer with given name and true if it exists, otherwise return false
-func (table keyTable) getKey(name string) (rsa.PublicKey, bool) {
+func (table keyTable) getKey(name string) (crypto.PublicKey, bool) {
 	rec, present := table.get(name)
 	return rec.KeyPub, present
 }
 
-// getFullyTrustedKeys retrieves the keys with a confidence level of 100%
-// If not yet signed, sign the keys
-func (table *keyTable) getFullyTrustedKeys(priK rsa.PrivateKey, origin string) []TrustedKeyRecord {
-	r := make([]TrustedKeyRecord, 0)
+// getFullyTrustedKeys returns the records with a confidence level of 100%
+func (table keyTable) getFullyTrustedKeys() []TrustedKeyRecord {
 	table.mutex.Lock()
-
-	for i, val := range table.db {
+	defer table.mutex.Unlock()
+	r := make([]TrustedKeyRecord, 0)
+	for _, val := range table.db {
 		if val.Confidence >= 1.0 {
-
-			// sign key
-			if val.keyExchangeMessage == nil {
-				table.db[i] = val.sign(priK, origin)
-			}
-
 			r = append(r, val)
 		}
 	}
-	table.mutex.Unlock()
 	return r
 }
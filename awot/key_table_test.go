@@ -38,18 +38,19 @@ func TestSigningExchangeMessage(t *testing.T) {
 	}
 
 	table.add(r1)
-	rec1, _ := table.get("node1")
+	table.add(TrustedKeyRecord{
+		KeyRecord:  KeyRecord{Owner: "node2", KeyPub: r1K.PublicKey},
+		Confidence: 0.5,
+	})
 
-	table.getFullyTrustedKeys(*r1K, "mynode")
-
-	if rec1.keyExchangeMessage != nil {
-		t.Errorf("getTrustedKeys returns a pointer")
+	trusted := table.getFullyTrustedKeys()
+	if len(trusted) != 1 || trusted[0].Owner != "node1" {
+		t.Errorf("getFullyTrustedKeys should only return the key of node1, got %v", trusted)
 	}
 
-	rec2, _ := table.get("node1")
-
-	if rec2.keyExchangeMessage == nil {
-		t.Errorf("getTrustedKeys does not sign")
+	msg := trusted[0].ConstructMessage(r1K, "mynode", DefaultKeyValidity)
+	if msg.Owner != "node1" || msg.Origin != "mynode" {
+		t.Errorf("ConstructMessage does not sign the record")
 	}
 }
 
@@ -97,7 +98,7 @@ func TestKeyTable(t *testing.T) {
 		t.Errorf("cannot retrieve existing key")
 	}
 
-	if r1K.PublicKey != pk1 {
+	if !pubKeyEquals(r1K.PublicKey, pk1) {
 		t.Errorf("keys are different")
 	}
 
@@ -147,7 +148,7 @@ func TestKeyTable(t *testing.T) {
 		t.Errorf("incorrect confidence : confidence has not been updated with updateConfidence")
 	}
 
-	if key != r2K.PublicKey {
+	if !pubKeyEquals(key, r2K.PublicKey) {
 		t.Errorf("incorrect public key : public key has not been updated with updateConfidence()")
 	}
 
@@ -0,0 +1,203 @@
+package awot
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"sync"
+
+	"github.com/No-Trust/peerster/common"
+)
+
+// transitionPrefix separates the signed data of a transition from the one of other messages
+const transitionPrefix = "TRANSITION"
+
+// A KeyTransitionMessage is a record of the rotation of the key of a peer from an old key to a new one.
+// It is signed by both keys, proving that the owner of the old key also owns the new one.
+type KeyTransitionMessage struct {
+	OldKeyBytes  []byte // serialized old public key
+	NewKeyBytes  []byte // serialized new public key
+	Owner        string // owner of both keys
+	OldSignature []byte // signature of (TRANSITION <-> oldKeyPub <-> newKeyPub <-> owner) by the old key
+	NewSignature []byte // same signature by the new key
+}
+
+// CreateTransition creates a KeyTransitionMessage from oldKey to newKey for given owner, signed by both keys
+func CreateTransition(oldKey, newKey crypto.Signer, owner string) (KeyTransitionMessage, error) {
+	oldbytes, err := SerializeKey(oldKey.Public())
+	if err != nil {
+		return KeyTransitionMessage{}, err
+	}
+	newbytes, err := SerializeKey(newKey.Public())
+	if err != nil {
+		return KeyTransitionMessage{}, err
+	}
+
+	hashed := transitionHash(oldbytes, newbytes, owner)
+
+	oldSignature, err := Sign(oldKey, hashed)
+	common.CheckError(err)
+	newSignature, err := Sign(newKey, hashed)
+	common.CheckError(err)
+
+	msg := KeyTransitionMessage{
+		OldKeyBytes:  oldbytes,
+		NewKeyBytes:  newbytes,
+		Owner:        owner,
+		OldSignature: oldSignature,
+		NewSignature: newSignature,
+	}
+	return msg, nil
+}
+
+// VerifyTransition verifies that the received transition is signed by both keys it contains
+// Returns the old and new keys if valid, an error otherwise
+func VerifyTransition(msg KeyTransitionMessage) (crypto.PublicKey, crypto.PublicKey, error) {
+	oldKey, err := DeserializeKey(msg.OldKeyBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	newKey, err := DeserializeKey(msg.NewKeyBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashed := transitionHash(msg.OldKeyBytes, msg.NewKeyBytes, msg.Owner)
+
+	if err = VerifySignature(oldKey, hashed, msg.OldSignature); err != nil {
+		return nil, nil, errors.New("wrong signature by old key")
+	}
+	if err = VerifySignature(newKey, hashed, msg.NewSignature); err != nil {
+		return nil, nil, errors.New("wrong signature by new key")
+	}
+
+	return oldKey, newKey, nil
+}
+
+// transitionHash returns the hash of the data signed in a transition
+func transitionHash(oldbytes, newbytes []byte, owner string) []byte {
+	newhash := sha256.New()
+	newhash.Write([]byte(transitionPrefix))
+	newhash.Write(oldbytes)
+	newhash.Write(newbytes)
+	newhash.Write([]byte(owner))
+	return newhash.Sum(nil)
+}
+
+// A transitionList records the key rotations of the peers, thread safe
+type transitionList struct {
+	next  map[string]map[string]crypto.PublicKey // owner -> old key id -> new key
+	mutex *sync.Mutex
+}
+
+// newTransitionList creates an empty transitionList
+func newTransitionList() *transitionList {
+	return &transitionList{
+		next:  make(map[string]map[string]crypto.PublicKey),
+		mutex: &sync.Mutex{},
+	}
+}
+
+// add records the rotation from oldKey to newKey of owner
+func (list *transitionList) add(owner string, oldKey, newKey crypto.PublicKey) {
+	list.mutex.Lock()
+	defer list.mutex.Unlock()
+	if list.next[owner] == nil {
+		list.next[owner] = make(map[string]crypto.PublicKey)
+	}
+	list.next[owner][keyID(oldKey)] = newKey
+}
+
+// latest follows the rotations of the given key of owner and returns the last key of the chain
+func (list *transitionList) latest(owner string, key crypto.PublicKey) crypto.PublicKey {
+	list.mutex.Lock()
+	defer list.mutex.Unlock()
+	visited := make(map[string]bool)
+	for {
+		id := keyID(key)
+		next, ok := list.next[owner][id]
+		if !ok || visited[id] {
+			return key
+		}
+		visited[id] = true
+		key = next
+	}
+}
+
+////////// Key Ring API
+
+// AddTransition verifies the given transition and moves the trust put in the old key of its owner to the new key.
+// Signatures of the old key become signatures of the new key, and later signatures of the old key are treated as such.
+// Returns an error if the transition could not be verified, or if the old key has been revoked.
+func (ring *KeyRing) AddTransition(msg KeyTransitionMessage) error {
+	oldKey, newKey, err := VerifyTransition(msg)
+	if err != nil {
+		return err
+	}
+
+	if ring.IsRevoked(msg.Owner, oldKey) {
+		// a compromised key cannot be used for rotating
+		return errors.New("old key of " + msg.Owner + " is revoked")
+	}
+
+	ring.transitions.add(msg.Owner, oldKey, newKey)
+
+	// move the signatures
+	ring.replaceEdgesKey(msg.Owner, oldKey, newKey)
+
+	// move the record
+	if rec, ok := ring.keyTable.get(msg.Owner); ok && keyID(rec.KeyPub) == keyID(oldKey) {
+		rec.KeyPub = newKey
+		rec.keyExchangeMessage = nil
+		ring.keyTable.add(rec)
+	}
+
+	// move the manual decisions
+	ring.manual.rotate(msg.Owner, oldKey, newKey)
+
+	ring.updateAllConfidence()
+	return nil
+}
+
+////////// Implementation
+
+// replaceEdgesKey replaces the key carried by the edges to the node named b from oldKey to newKey
+func (ring *KeyRing) replaceEdgesKey(b string, oldKey, newKey crypto.PublicKey) {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+
+	vB, present := ring.ids[b]
+	if !present {
+		return
+	}
+	id := keyID(oldKey)
+
+	for _, from := range ring.graph.To(*vB) {
+		edge := ring.graph.Edge(from, *vB)
+		if edge != nil && keyID(edge.(Edge).Key) == id {
+			e := edge.(Edge)
+			e.Key = newKey
+			// the message signed the old key
+			e.message = nil
+			ring.graph.SetEdge(e)
+			ring.markDownstream(*vB)
+		}
+	}
+}
+
+// rotate moves the manual decisions on the old key of owner to its new key
+func (m *manualTrust) rotate(owner string, oldKey, newKey crypto.PublicKey) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if rec, ok := m.decisions.Imported[owner]; ok && keyID(rec.KeyPub) == keyID(oldKey) {
+		rec.KeyPub = newKey
+		m.decisions.Imported[owner] = rec
+	}
+	if FingerprintMatches(m.decisions.Pinned[owner], oldKey) {
+		m.decisions.Pinned[owner] = Fingerprint(newKey)
+	}
+	if FingerprintMatches(m.decisions.Distrusted[owner], oldKey) {
+		// rotating does not clear the distrust
+		m.decisions.Distrusted[owner] = Fingerprint(newKey)
+	}
+}
@@ -0,0 +1,114 @@
+// Tests for key rotations
+package awot
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+)
+
+// TestTransitionSigning tests that transitions must be signed by both keys
+func TestTransitionSigning(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("could not generate rsa key: %v", err)
+	}
+	newKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("could not generate rsa key: %v", err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("could not generate rsa key: %v", err)
+	}
+
+	msg, err := CreateTransition(oldKey, newKey, "B")
+	if err != nil {
+		t.Fatalf("could not create transition: %v", err)
+	}
+	o, n, err := VerifyTransition(msg)
+	if err != nil {
+		t.Fatalf("signatures of transition are wrong: %v", err)
+	}
+	if !pubKeyEquals(o, oldKey.PublicKey) || !pubKeyEquals(n, newKey.PublicKey) {
+		t.Fatalf("VerifyTransition should return the keys of the transition")
+	}
+
+	// an attacker cannot rotate a key it does not own
+	forged, err := CreateTransition(otherKey, newKey, "B")
+	if err != nil {
+		t.Fatalf("could not create transition: %v", err)
+	}
+	forged.OldKeyBytes = msg.OldKeyBytes
+	if _, _, err = VerifyTransition(forged); err == nil {
+		t.Fatalf("transition not signed by the old key should be rejected")
+	}
+
+	// the owner is part of the signed data
+	msg.Owner = "C"
+	if _, _, err = VerifyTransition(msg); err == nil {
+		t.Fatalf("transition with modified owner should be rejected")
+	}
+}
+
+// TestKeyRingTransition tests that a KeyRing moves the trust to the new key
+func TestKeyRingTransition(t *testing.T) {
+	keys := make(map[string]*rsa.PrivateKey)
+	for _, name := range []string{"source", "A", "B", "B2", "B3"} {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			t.Fatalf("could not generate rsa key: %v", err)
+		}
+		keys[name] = key
+	}
+
+	trusted := []TrustedKeyRecord{
+		{KeyRecord: KeyRecord{Owner: "A", KeyPub: keys["A"].PublicKey}, Confidence: 1.0},
+	}
+	ring := NewKeyRing("source", keys["source"].PublicKey, trusted, 0.0)
+	ring.Add(KeyRecord{Owner: "B", KeyPub: keys["B"].PublicKey}, "A", 1.0)
+
+	before, _ := ring.GetRecord("B")
+
+	msg, err := CreateTransition(keys["B"], keys["B2"], "B")
+	if err != nil {
+		t.Fatalf("could not create transition: %v", err)
+	}
+	if err = ring.AddTransition(msg); err != nil {
+		t.Fatalf("could not add transition: %v", err)
+	}
+
+	after, ok := ring.GetRecord("B")
+	if !ok || !pubKeyEquals(after.KeyPub, keys["B2"].PublicKey) {
+		t.Fatalf("key of B should be the new key after the transition")
+	}
+	if after.Confidence != before.Confidence {
+		t.Fatalf("confidence of B should be kept, was %v, got %v", before.Confidence, after.Confidence)
+	}
+
+	// a late signature of the old key is a signature of the new one, not a collision
+	ring.Add(KeyRecord{Owner: "B", KeyPub: keys["B"].PublicKey}, "A", 1.0)
+	key, _ := ring.GetKey("B")
+	if !pubKeyEquals(key, keys["B2"].PublicKey) {
+		t.Fatalf("signature of the old key should be moved to the new key")
+	}
+
+	// a revoked key cannot be rotated
+	revocation, err := CreateRevocation(keys["B2"].PublicKey, "B", keys["B2"], "B")
+	if err != nil {
+		t.Fatalf("could not create revocation: %v", err)
+	}
+	if err = ring.AddRevocation(revocation); err != nil {
+		t.Fatalf("could not add revocation: %v", err)
+	}
+	msg, err = CreateTransition(keys["B2"], keys["B3"], "B")
+	if err != nil {
+		t.Fatalf("could not create transition: %v", err)
+	}
+	if err = ring.AddTransition(msg); err == nil {
+		t.Fatalf("transition from a revoked key should be rejected")
+	}
+	if _, ok := ring.GetKey("B"); ok {
+		t.Fatalf("key of B should not be returned after a rejected transition from a revoked key")
+	}
+}
@@ -0,0 +1,54 @@
+package awot
+
+import (
+	"errors"
+	"strings"
+)
+
+// A KeyVerification holds what a user compares out of band with the owner of a key before trusting it fully
+type KeyVerification struct {
+	Owner       string // owner of the key
+	Fingerprint string // fingerprint of the key, as hex groups
+	Words       string // fingerprint of the key, as words
+	SAS         string // short authentication string of the key and the key of the ring owner
+}
+
+// Verification returns what the user needs to verify the current key of the peer with given name with its owner.
+// Both users compare the short authentication strings their rings give for each other, and then confirm it with ConfirmVerification.
+func (ring KeyRing) Verification(name string) (KeyVerification, error) {
+	if name == ring.source {
+		return KeyVerification{}, errors.New("cannot verify own key")
+	}
+	key, ok := ring.keyTable.getKey(name)
+	if !ok {
+		return KeyVerification{}, errors.New("no key to verify for " + name)
+	}
+	sourceKey, _ := ring.keyTable.getKey(ring.source)
+
+	return KeyVerification{
+		Owner:       name,
+		Fingerprint: FingerprintGroups(key),
+		Words:       FingerprintWordList(key),
+		SAS:         ShortAuthString(sourceKey, key),
+	}, nil
+}
+
+// ConfirmVerification promotes the current key of the peer with given name to full trust,
+// if the given short authentication string, compared out of band with the owner, is the one of this key.
+// The key is then pinned, so that no other key is accepted for this peer.
+func (ring *KeyRing) ConfirmVerification(name, sas string) error {
+	verification, err := ring.Verification(name)
+	if err != nil {
+		return err
+	}
+	if strings.Join(strings.Fields(strings.ToLower(sas)), " ") != verification.SAS {
+		return errors.New("short authentication string does not match the key of " + name)
+	}
+
+	rec, _ := ring.keyTable.get(name)
+	err = ring.ImportKey(rec.KeyRecord, 1.0)
+	if err != nil {
+		return err
+	}
+	return ring.Pin(name, Fingerprint(rec.KeyPub))
+}
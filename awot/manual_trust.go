@@ -0,0 +1,255 @@
+package awot
+
+import (
+	"crypto"
+	"errors"
+	"sync"
+)
+
+// TrustDecisions are the manual trust decisions taken by the user on a KeyRing.
+// They override the confidence levels computed by the KeyRing, and are meant to be persisted.
+type TrustDecisions struct {
+	Imported   map[string]TrustedKeyRecord // owner -> key imported by the user, with its confidence
+	Distrusted map[string]string           // owner -> fingerprint of the distrusted key
+	Pinned     map[string]string           // owner -> fingerprint of the only acceptable key
+}
+
+// manualTrust is the thread safe store of the TrustDecisions of a KeyRing
+type manualTrust struct {
+	decisions TrustDecisions
+	mutex     *sync.Mutex
+}
+
+// newManualTrust creates an empty manualTrust
+func newManualTrust() *manualTrust {
+	return &manualTrust{
+		decisions: emptyTrustDecisions(),
+		mutex:     &sync.Mutex{},
+	}
+}
+
+// emptyTrustDecisions creates TrustDecisions without any decision
+func emptyTrustDecisions() TrustDecisions {
+	return TrustDecisions{
+		Imported:   make(map[string]TrustedKeyRecord),
+		Distrusted: make(map[string]string),
+		Pinned:     make(map[string]string),
+	}
+}
+
+// copy returns a deep copy of the decisions
+func (d TrustDecisions) copy() TrustDecisions {
+	c := emptyTrustDecisions()
+	for owner, rec := range d.Imported {
+		c.Imported[owner] = TrustedKeyRecord{
+			KeyRecord: KeyRecord{
+				Owner:  rec.Owner,
+				KeyPub: normalizeKey(rec.KeyPub),
+			},
+			Confidence: rec.Confidence,
+		}
+	}
+	for owner, fp := range d.Distrusted {
+		c.Distrusted[owner] = fp
+	}
+	for owner, fp := range d.Pinned {
+		c.Pinned[owner] = fp
+	}
+	return c
+}
+
+// imported returns the record imported for owner and true if it exists, otherwise returns false
+func (m *manualTrust) imported(owner string) (TrustedKeyRecord, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	rec, ok := m.decisions.Imported[owner]
+	return rec, ok
+}
+
+// accepts checks that the given key of owner is neither distrusted nor in conflict with a pinned fingerprint
+func (m *manualTrust) accepts(owner string, key crypto.PublicKey) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.migrate(owner, key)
+	fp := Fingerprint(key)
+	if distrusted, ok := m.decisions.Distrusted[owner]; ok && distrusted == fp {
+		return false
+	}
+	if pinned, ok := m.decisions.Pinned[owner]; ok && pinned != fp {
+		return false
+	}
+	return true
+}
+
+// migrate replaces the fingerprints of the older MD5 format of owner by the current fingerprint of key, if they match it
+// The caller must hold the mutex.
+func (m *manualTrust) migrate(owner string, key crypto.PublicKey) {
+	for _, fingerprints := range []map[string]string{m.decisions.Distrusted, m.decisions.Pinned} {
+		if fp, ok := fingerprints[owner]; ok && isLegacyFingerprint(fp) && FingerprintMatches(fp, key) {
+			fingerprints[owner] = Fingerprint(key)
+		}
+	}
+}
+
+////////// Key Ring API
+
+// ImportKey adds the given key record as if it was signed by the owner of the ring, with given confidence.
+// The confidence of the record is then never recomputed, until another manual decision is taken for its owner.
+// Returns an error if the confidence is not between 0 and 1.
+func (ring *KeyRing) ImportKey(rec KeyRecord, confidence float32) error {
+	if rec.Owner == ring.source {
+		return errors.New("cannot import own key")
+	}
+	if !(confidence >= 0 && confidence <= 1) {
+		return errors.New("confidence of an imported key must be between 0 and 1")
+	}
+	rec.KeyPub = normalizeKey(rec.KeyPub)
+
+	ring.manual.mutex.Lock()
+	ring.manual.decisions.Imported[rec.Owner] = TrustedKeyRecord{
+		KeyRecord:  rec,
+		Confidence: confidence,
+	}
+	if FingerprintMatches(ring.manual.decisions.Distrusted[rec.Owner], rec.KeyPub) {
+		delete(ring.manual.decisions.Distrusted, rec.Owner)
+	}
+	ring.manual.mutex.Unlock()
+
+	ring.applyImport(rec, confidence)
+	ring.updateAllConfidence()
+	return nil
+}
+
+// Distrust marks the current key of the peer with given name as distrusted.
+// A distrusted key is never returned by GetKey, and its owner is not trusted anymore for signing other keys.
+// Returns an error if the ring has no key for this peer.
+func (ring *KeyRing) Distrust(name string) error {
+	if name == ring.source {
+		return errors.New("cannot distrust own key")
+	}
+	rec, ok := ring.keyTable.get(name)
+	if !ok {
+		return errors.New("no key to distrust for " + name)
+	}
+
+	ring.manual.mutex.Lock()
+	ring.manual.decisions.Distrusted[name] = Fingerprint(rec.KeyPub)
+	delete(ring.manual.decisions.Imported, name)
+	ring.manual.mutex.Unlock()
+
+	ring.applyDistrust(name)
+	ring.updateAllConfidence()
+	return nil
+}
+
+// Pin restricts the keys accepted for the peer with given name to the one with given fingerprint.
+// The fingerprint can be given in any form accepted by ParseFingerprint.
+func (ring *KeyRing) Pin(name, fingerprint string) error {
+	fp, err := ParseFingerprint(fingerprint)
+	if err != nil {
+		return err
+	}
+
+	ring.manual.mutex.Lock()
+	ring.manual.decisions.Pinned[name] = fp
+	ring.manual.mutex.Unlock()
+
+	ring.updateAllConfidence()
+	return nil
+}
+
+// ResetTrust removes every manual decision taken for the peer with given name.
+// The confidence of its key is computed again by the ring, and the bootstrap record of the peer, if any, is trusted again.
+func (ring *KeyRing) ResetTrust(name string) {
+	ring.manual.mutex.Lock()
+	_, wasImported := ring.manual.decisions.Imported[name]
+	delete(ring.manual.decisions.Imported, name)
+	delete(ring.manual.decisions.Distrusted, name)
+	delete(ring.manual.decisions.Pinned, name)
+	ring.manual.mutex.Unlock()
+
+	if wasImported {
+		ring.removeEdge(ring.source, name)
+	}
+	ring.restoreBootstrap(name)
+	ring.updateAllConfidence()
+}
+
+// TrustDecisions returns a copy of the manual trust decisions taken on the ring, e.g. for persisting them.
+func (ring KeyRing) TrustDecisions() TrustDecisions {
+	ring.manual.mutex.Lock()
+	defer ring.manual.mutex.Unlock()
+	return ring.manual.decisions.copy()
+}
+
+// RestoreTrustDecisions applies previously saved manual trust decisions to the ring.
+func (ring *KeyRing) RestoreTrustDecisions(decisions TrustDecisions) {
+	decisions = decisions.copy()
+	delete(decisions.Imported, ring.source)
+	delete(decisions.Distrusted, ring.source)
+
+	ring.manual.mutex.Lock()
+	for owner, rec := range decisions.Imported {
+		ring.manual.decisions.Imported[owner] = rec
+	}
+	for owner, fp := range decisions.Distrusted {
+		ring.manual.decisions.Distrusted[owner] = fp
+	}
+	for owner, fp := range decisions.Pinned {
+		ring.manual.decisions.Pinned[owner] = fp
+	}
+	ring.manual.mutex.Unlock()
+
+	for _, rec := range decisions.Imported {
+		ring.applyImport(rec.KeyRecord, rec.Confidence)
+	}
+	for owner := range decisions.Distrusted {
+		ring.applyDistrust(owner)
+	}
+	ring.updateAllConfidence()
+}
+
+////////// Implementation
+
+// applyImport adds the imported record in the graph, as an edge from the source
+func (ring *KeyRing) applyImport(rec KeyRecord, confidence float32) {
+	ring.addNode(rec.Owner, confidence)
+	ring.addEdge(ring.source, rec.Owner, rec.KeyPub)
+	ring.keyTable.add(TrustedKeyRecord{
+		KeyRecord:  rec,
+		Confidence: confidence,
+	})
+}
+
+// applyDistrust removes the trust put in a distrusted peer by the graph
+func (ring *KeyRing) applyDistrust(name string) {
+	rec, ok := ring.keyTable.get(name)
+	if ok && !ring.manual.accepts(name, rec.KeyPub) {
+		ring.removeEdge(ring.source, name)
+		ring.addNode(name, 0.0)
+	}
+}
+
+// restoreBootstrap puts back the edge from the source to the peer with given name given by its bootstrap record, if any,
+// as removed by a distrust or replaced by an import
+func (ring *KeyRing) restoreBootstrap(name string) {
+	rec, ok := ring.bootstrap[name]
+	if !ok || !ring.manual.accepts(name, rec.KeyPub) {
+		return
+	}
+	ring.addNode(name, 1.0)
+	ring.addEdge(ring.source, name, normalizeKey(rec.KeyPub))
+	ring.keyTable.add(rec)
+}
+
+// manualProbability returns the probability of the node with given name imposed by the manual decisions, and true if there is one
+func (ring KeyRing) manualProbability(name string) (float32, bool) {
+	if rec, ok := ring.manual.imported(name); ok {
+		return rec.Confidence, true
+	}
+	rec, ok := ring.keyTable.get(name)
+	if ok && !ring.manual.accepts(name, rec.KeyPub) {
+		return 0.0, true
+	}
+	return 0.0, false
+}
@@ -0,0 +1,187 @@
+// Tests for the manual trust decisions
+package awot
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"math"
+	"testing"
+)
+
+// TestManualTrust tests importing, distrusting and pinning keys on a KeyRing
+func TestManualTrust(t *testing.T) {
+	keys := make(map[string]*rsa.PrivateKey)
+	for _, name := range []string{"source", "A", "B", "C"} {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			t.Fatalf("could not generate rsa key: %v", err)
+		}
+		keys[name] = key
+	}
+
+	// source fully trusts A, and A signed the key of B
+	trusted := []TrustedKeyRecord{
+		{
+			KeyRecord:  KeyRecord{Owner: "A", KeyPub: keys["A"].PublicKey},
+			Confidence: 1.0,
+		},
+	}
+	ring := NewKeyRing("source", keys["source"].PublicKey, trusted, 0.0)
+	ring.Add(KeyRecord{Owner: "B", KeyPub: keys["B"].PublicKey}, "A", 1.0)
+
+	if _, ok := ring.GetKey("B"); !ok {
+		t.Fatalf("key of B signed by a fully trusted peer should be returned")
+	}
+
+	t.Run("distrust", func(t *testing.T) {
+		if err := ring.Distrust("B"); err != nil {
+			t.Fatalf("could not distrust B: %v", err)
+		}
+		if _, ok := ring.GetKey("B"); ok {
+			t.Fatalf("distrusted key of B should not be returned")
+		}
+		if rec, _ := ring.GetRecord("B"); rec.Confidence != 0 {
+			t.Fatalf("confidence of distrusted key should be 0, got %v", rec.Confidence)
+		}
+		if err := ring.Distrust("unknown"); err == nil {
+			t.Fatalf("distrusting an unknown peer should not be possible")
+		}
+		if err := ring.Distrust("source"); err == nil {
+			t.Fatalf("distrusting own key should not be possible")
+		}
+
+		ring.ResetTrust("B")
+		if _, ok := ring.GetKey("B"); !ok {
+			t.Fatalf("key of B should be returned after reset")
+		}
+	})
+
+	t.Run("pin", func(t *testing.T) {
+		ring.Pin("B", Fingerprint(keys["C"].PublicKey))
+		if _, ok := ring.GetKey("B"); ok {
+			t.Fatalf("key of B not matching the pinned fingerprint should not be returned")
+		}
+
+		ring.Pin("B", Fingerprint(keys["B"].PublicKey))
+		key, ok := ring.GetKey("B")
+		if !ok || !pubKeyEquals(key, keys["B"].PublicKey) {
+			t.Fatalf("key of B matching the pinned fingerprint should be returned")
+		}
+		ring.ResetTrust("B")
+	})
+
+	t.Run("import", func(t *testing.T) {
+		err := ring.ImportKey(KeyRecord{Owner: "C", KeyPub: keys["C"].PublicKey}, 0.7)
+		if err != nil {
+			t.Fatalf("could not import key of C: %v", err)
+		}
+		rec, ok := ring.GetRecord("C")
+		if !ok || rec.Confidence != 0.7 {
+			t.Fatalf("imported key of C should have confidence 0.7, got %v", rec.Confidence)
+		}
+		if !pubKeyEquals(rec.KeyPub, keys["C"].PublicKey) {
+			t.Fatalf("imported key of C is not the one returned")
+		}
+		ring.updateTrust(nil)
+		if p := *ring.ids["C"].probability; p != 0.7 {
+			t.Fatalf("probability of imported node should be its confidence, got %v", p)
+		}
+		err = ring.ImportKey(KeyRecord{Owner: "source", KeyPub: keys["C"].PublicKey}, 1.0)
+		if err == nil {
+			t.Fatalf("importing own key should not be possible")
+		}
+		for _, confidence := range []float32{-0.1, 1.5, float32(math.NaN())} {
+			if err := ring.ImportKey(KeyRecord{Owner: "B", KeyPub: keys["B"].PublicKey}, confidence); err == nil {
+				t.Fatalf("importing a key with confidence %v should not be possible", confidence)
+			}
+		}
+		if rec, ok := ring.GetRecord("C"); !ok || rec.Confidence != 0.7 {
+			t.Fatalf("rejected imports should not change the ring, got confidence %v for C", rec.Confidence)
+		}
+		if _, ok := ring.TrustDecisions().Imported["B"]; ok {
+			t.Fatalf("a rejected import should not be recorded")
+		}
+	})
+
+	t.Run("distrust signer", func(t *testing.T) {
+		if err := ring.Distrust("A"); err != nil {
+			t.Fatalf("could not distrust A: %v", err)
+		}
+		if rec, _ := ring.GetRecord("B"); rec.Confidence != 0 {
+			t.Fatalf("key only signed by a distrusted peer should have confidence 0, got %v", rec.Confidence)
+		}
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		decisions := ring.TrustDecisions()
+		other := NewKeyRing("source", keys["source"].PublicKey, trusted, 0.0)
+		other.RestoreTrustDecisions(decisions)
+
+		if _, ok := other.GetKey("A"); ok {
+			t.Fatalf("restored ring should distrust A")
+		}
+		if rec, ok := other.GetRecord("C"); !ok || rec.Confidence != 0.7 {
+			t.Fatalf("restored ring should contain the imported key of C")
+		}
+	})
+}
+
+// TestResetBootstrap tests that resetting the trust in a bootstrap peer trusts its bootstrap key again
+func TestResetBootstrap(t *testing.T) {
+	keys := make(map[string]*rsa.PrivateKey)
+	for _, name := range []string{"source", "A", "B", "other"} {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			t.Fatalf("could not generate rsa key: %v", err)
+		}
+		keys[name] = key
+	}
+
+	trusted := []TrustedKeyRecord{
+		{
+			KeyRecord:  KeyRecord{Owner: "A", KeyPub: keys["A"].PublicKey},
+			Confidence: 1.0,
+		},
+	}
+	ring := NewKeyRing("source", keys["source"].PublicKey, trusted, 0.0)
+	ring.Add(KeyRecord{Owner: "B", KeyPub: keys["B"].PublicKey}, "A", 1.0)
+
+	// checks that A is trusted with its bootstrap key, and is trusted for signing again
+	bootstrapped := func(t *testing.T) {
+		key, ok := ring.GetKey("A")
+		if !ok || !pubKeyEquals(key, keys["A"].PublicKey) {
+			t.Fatalf("bootstrap key of A should be returned after the reset")
+		}
+		if rec, _ := ring.GetRecord("A"); rec.Confidence != 1.0 {
+			t.Fatalf("bootstrap key of A should be fully trusted after the reset, got %v", rec.Confidence)
+		}
+		if rec, _ := ring.GetRecord("B"); rec.Confidence == 0 {
+			t.Fatalf("key signed by A should be trusted again after the reset")
+		}
+		if _, ok := ring.TrustDecisions().Distrusted["A"]; ok {
+			t.Fatalf("reset should remove the decisions on A")
+		}
+	}
+
+	t.Run("distrust", func(t *testing.T) {
+		if err := ring.Distrust("A"); err != nil {
+			t.Fatalf("could not distrust A: %v", err)
+		}
+		if _, ok := ring.GetKey("A"); ok {
+			t.Fatalf("distrusted key of A should not be returned")
+		}
+		ring.ResetTrust("A")
+		bootstrapped(t)
+	})
+
+	t.Run("import", func(t *testing.T) {
+		if err := ring.ImportKey(KeyRecord{Owner: "A", KeyPub: keys["other"].PublicKey}, 0.3); err != nil {
+			t.Fatalf("could not import key of A: %v", err)
+		}
+		if key, _ := ring.GetKey("A"); !pubKeyEquals(key, keys["other"].PublicKey) {
+			t.Fatalf("imported key of A should replace its bootstrap key")
+		}
+		ring.ResetTrust("A")
+		bootstrapped(t)
+	})
+}
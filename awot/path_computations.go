@@ -68,6 +68,36 @@ func probabilityOfMinPaths(minpaths []Path) float32 {
 	return p
 }
 
+// Compute the terms of the inclusion exclusion formula for the given shortest paths, as probabilityOfMinPaths
+// The probability of the paths is the sum of the terms.
+func inclusionExclusionTerms(minpaths []Path) []InclusionExclusionTerm {
+	minPaths := make([]Path, len(minpaths))
+	for i, v := range minpaths {
+		vp := v
+		// remove last element (target)
+		if len(v) > 0 {
+			vp = v[:len(v)-1]
+		}
+		minPaths[i] = Path(vp)
+	}
+
+	terms := make([]InclusionExclusionTerm, 0, len(minPaths))
+	s := 1
+	for i := 1; i <= len(minPaths); i++ {
+		term := InclusionExclusionTerm{
+			Size: i,
+			Sign: s,
+		}
+		for _, path := range comb(minPaths, i) {
+			term.Sets++
+			term.Value += float32(s) * probabilityOfPath(path)
+		}
+		terms = append(terms, term)
+		s = -s
+	}
+	return terms
+}
+
 // Compute the probability of the given path
 func probabilityOfPath(path Path) float32 {
 	p := float32(1.0)
@@ -0,0 +1,267 @@
+package awot
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A PendingConfig bounds the KeyExchangeMessages kept while the key of their signer is missing
+type PendingConfig struct {
+	MaxMessages  int           // maximum number of pending messages, the oldest ones being dropped first
+	MaxPerSigner int           // maximum number of pending messages of a single signer, so that one signer cannot fill the store
+	MaxAge       time.Duration // time after which a pending message is dropped, even if its signature is still valid
+}
+
+// DefaultPendingConfig returns the default bounds of the pending messages
+func DefaultPendingConfig() PendingConfig {
+	return PendingConfig{
+		MaxMessages:  1024,
+		MaxPerSigner: 64,
+		MaxAge:       time.Hour,
+	}
+}
+
+// PendingStats are statistics on the KeyExchangeMessages waiting for the key of their signer
+type PendingStats struct {
+	Messages   int            // messages currently pending
+	Signers    map[string]int // missing signer -> number of its pending messages
+	Added      int            // messages added since the creation of the ring
+	Duplicates int            // messages ignored or replaced, for having the same owner, signer and key as a pending one
+	Dropped    int            // messages dropped because of the size limits
+	Expired    int            // messages dropped because expired or pending for too long
+	Verified   int            // messages added to the ring once the key of their signer was known
+	Rejected   int            // messages whose signature did not match the key of their signer
+}
+
+// pendingID identifies the pending messages that are duplicates of each other
+type pendingID struct {
+	owner  string
+	origin string
+	key    string
+}
+
+// A pendingMessage is a KeyExchangeMessage waiting for the key of its signer
+type pendingMessage struct {
+	msg      KeyExchangeMessage
+	received time.Time
+	element  *list.Element // position in the arrival order
+}
+
+// pendingStore is the thread safe store of the pending messages, indexed by missing signer
+type pendingStore struct {
+	config   PendingConfig
+	bySigner map[string]map[pendingID]*pendingMessage // signer -> pending messages signed by it
+	order    *list.List                               // pendingID of the messages, oldest first
+	stats    PendingStats
+	mutex    *sync.Mutex
+}
+
+// newPendingStore creates an empty pendingStore with given bounds
+func newPendingStore(config PendingConfig) *pendingStore {
+	return &pendingStore{
+		config:   config,
+		bySigner: make(map[string]map[pendingID]*pendingMessage),
+		order:    list.New(),
+		mutex:    &sync.Mutex{},
+	}
+}
+
+// add adds a message received at given time.
+// A message with the same owner, signer and key as a pending one replaces it only if it was issued later.
+func (store *pendingStore) add(msg KeyExchangeMessage, now time.Time) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	id := pendingID{owner: msg.Owner, origin: msg.Origin, key: string(msg.KeyBytes)}
+	store.stats.Added++
+
+	if old, ok := store.bySigner[msg.Origin][id]; ok {
+		store.stats.Duplicates++
+		if old.msg.IssuedAt >= msg.IssuedAt {
+			return
+		}
+		store.remove(id)
+	}
+
+	// make room
+	if store.config.MaxPerSigner > 0 && len(store.bySigner[msg.Origin]) >= store.config.MaxPerSigner {
+		store.remove(store.oldest(msg.Origin))
+		store.stats.Dropped++
+	}
+	if store.config.MaxMessages > 0 && store.order.Len() >= store.config.MaxMessages {
+		store.remove(store.order.Front().Value.(pendingID))
+		store.stats.Dropped++
+	}
+
+	if store.bySigner[msg.Origin] == nil {
+		store.bySigner[msg.Origin] = make(map[pendingID]*pendingMessage)
+	}
+	store.bySigner[msg.Origin][id] = &pendingMessage{
+		msg:      msg,
+		received: now,
+		element:  store.order.PushBack(id),
+	}
+}
+
+// signers returns the missing signers of the pending messages, sorted
+func (store *pendingStore) signers() []string {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	signers := make([]string, 0, len(store.bySigner))
+	for signer := range store.bySigner {
+		signers = append(signers, signer)
+	}
+	sort.Strings(signers)
+	return signers
+}
+
+// take removes and returns the pending messages signed by given signer, oldest first
+func (store *pendingStore) take(signer string) []KeyExchangeMessage {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	pending := make([]*pendingMessage, 0, len(store.bySigner[signer]))
+	for _, p := range store.bySigner[signer] {
+		pending = append(pending, p)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].received.Before(pending[j].received)
+	})
+
+	msgs := make([]KeyExchangeMessage, len(pending))
+	for i, p := range pending {
+		msgs[i] = p.msg
+		store.order.Remove(p.element)
+	}
+	delete(store.bySigner, signer)
+	return msgs
+}
+
+// expire drops the messages that are expired or pending for too long at given time
+func (store *pendingStore) expire(now time.Time) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for _, msgs := range store.bySigner {
+		for id, p := range msgs {
+			tooOld := store.config.MaxAge > 0 && now.Sub(p.received) > store.config.MaxAge
+			if p.msg.Expired(now) || tooOld {
+				store.remove(id)
+				store.stats.Expired++
+			}
+		}
+	}
+}
+
+// count records the outcome of the verification of messages taken from the store
+func (store *pendingStore) count(verified, rejected int) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.stats.Verified += verified
+	store.stats.Rejected += rejected
+}
+
+// statistics returns a copy of the current statistics
+func (store *pendingStore) statistics() PendingStats {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	stats := store.stats
+	stats.Messages = store.order.Len()
+	stats.Signers = make(map[string]int)
+	for signer, msgs := range store.bySigner {
+		stats.Signers[signer] = len(msgs)
+	}
+	return stats
+}
+
+// oldest returns the pendingID of the oldest pending message of given signer
+// The caller must hold the mutex.
+func (store *pendingStore) oldest(signer string) pendingID {
+	var oldest *pendingMessage
+	var oldestID pendingID
+	for id, p := range store.bySigner[signer] {
+		if oldest == nil || p.received.Before(oldest.received) {
+			oldest, oldestID = p, id
+		}
+	}
+	return oldestID
+}
+
+// remove removes the pending message with given pendingID
+// The caller must hold the mutex.
+func (store *pendingStore) remove(id pendingID) {
+	p, ok := store.bySigner[id.origin][id]
+	if !ok {
+		return
+	}
+	store.order.Remove(p.element)
+	delete(store.bySigner[id.origin], id)
+	if len(store.bySigner[id.origin]) == 0 {
+		delete(store.bySigner, id.origin)
+	}
+}
+
+////////// Key Ring API
+
+// SetPendingConfig sets the bounds of the messages waiting for the key of their signer.
+// The bounds apply to the messages added afterwards.
+func (ring *KeyRing) SetPendingConfig(config PendingConfig) {
+	ring.pending.mutex.Lock()
+	ring.pending.config = config
+	ring.pending.mutex.Unlock()
+}
+
+// PendingStats returns statistics on the messages waiting for the key of their signer
+func (ring KeyRing) PendingStats() PendingStats {
+	return ring.pending.statistics()
+}
+
+////////// Implementation
+
+// updatePending drops the outdated pending messages, and verifies the ones whose signer's key is now known
+// Only the messages depending on a newly known key are verified again.
+func (ring *KeyRing) updatePending(reptable ReputationTable) {
+	now := time.Now()
+	ring.pending.expire(now)
+
+	for _, signer := range ring.pending.signers() {
+		kpub, present := ring.GetKey(signer)
+		if !present {
+			// still do not have a public key
+			continue
+		}
+
+		verified, rejected := 0, 0
+		for _, msg := range ring.pending.take(signer) {
+			msg := msg
+			receivedKey, err := DeserializeKey(msg.KeyBytes)
+			if err == nil {
+				err = Verify(msg, kpub)
+			}
+			if err != nil {
+				rejected++
+				continue
+			}
+
+			ok := false
+			reputationOwner := float32(0.5)
+			if reptable != nil {
+				reputationOwner, ok = reptable.Reputation(msg.Owner)
+			}
+			if !ok {
+				reputationOwner = 0.5
+			}
+			record := KeyRecord{
+				Owner:  msg.Owner,
+				KeyPub: receivedKey,
+			}
+			ring.add(record, msg.Origin, 2*reputationOwner, &msg)
+			verified++
+		}
+		ring.pending.count(verified, rejected)
+	}
+}
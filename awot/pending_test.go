@@ -0,0 +1,109 @@
+// Tests for the messages waiting for the key of their signer
+package awot
+
+import (
+	"crypto"
+	"testing"
+	"time"
+)
+
+// pendingMessageBy creates a message of signer for the key of owner, issued at given time
+func pendingMessageBy(t *testing.T, signer string, signerKey crypto.Signer, owner string, ownerKey crypto.PublicKey, issued time.Time) KeyExchangeMessage {
+	keybytes, err := SerializeKey(ownerKey)
+	if err != nil {
+		t.Fatalf("could not serialize key: %v", err)
+	}
+	return create(keybytes, owner, signerKey, signer, issued, time.Hour)
+}
+
+// TestPendingStore tests the deduplication, size limits and expiry of the pending messages
+func TestPendingStore(t *testing.T) {
+	keys := make(map[string]crypto.Signer)
+	for _, name := range []string{"A", "B", "C", "D"} {
+		keys[name], _ = GenerateKey(KeyAlgorithmEd25519, 0)
+	}
+	now := time.Now()
+
+	store := newPendingStore(PendingConfig{MaxMessages: 3, MaxPerSigner: 2, MaxAge: time.Minute})
+
+	// duplicates : only the latest signature is kept
+	first := pendingMessageBy(t, "A", keys["A"], "B", keys["B"].Public(), now.Add(-time.Minute))
+	second := pendingMessageBy(t, "A", keys["A"], "B", keys["B"].Public(), now)
+	store.add(second, now)
+	store.add(first, now)
+	stats := store.statistics()
+	if stats.Messages != 1 || stats.Duplicates != 1 {
+		t.Fatalf("duplicate should be ignored, got %+v", stats)
+	}
+	if msgs := store.take("A"); len(msgs) != 1 || msgs[0].IssuedAt != second.IssuedAt {
+		t.Fatalf("latest signature should be kept")
+	}
+
+	// limit per signer : the oldest message of A is dropped
+	store.add(pendingMessageBy(t, "A", keys["A"], "B", keys["B"].Public(), now), now)
+	store.add(pendingMessageBy(t, "A", keys["A"], "C", keys["C"].Public(), now), now.Add(time.Second))
+	store.add(pendingMessageBy(t, "A", keys["A"], "D", keys["D"].Public(), now), now.Add(2*time.Second))
+	stats = store.statistics()
+	if stats.Signers["A"] != 2 || stats.Dropped != 1 {
+		t.Fatalf("messages of A should be limited to 2, got %+v", stats)
+	}
+	for _, msg := range store.take("A") {
+		if msg.Owner == "B" {
+			t.Fatalf("oldest message of A should be dropped")
+		}
+	}
+
+	// global limit : the oldest message is dropped
+	store.add(pendingMessageBy(t, "A", keys["A"], "B", keys["B"].Public(), now), now)
+	store.add(pendingMessageBy(t, "B", keys["B"], "C", keys["C"].Public(), now), now)
+	store.add(pendingMessageBy(t, "C", keys["C"], "D", keys["D"].Public(), now), now)
+	store.add(pendingMessageBy(t, "D", keys["D"], "A", keys["A"].Public(), now), now)
+	stats = store.statistics()
+	if stats.Messages != 3 || stats.Signers["A"] != 0 {
+		t.Fatalf("oldest message should be dropped for the global limit, got %+v", stats)
+	}
+
+	// expiry
+	store.expire(now.Add(2 * time.Minute))
+	stats = store.statistics()
+	if stats.Messages != 0 || stats.Expired != 3 || len(stats.Signers) != 0 {
+		t.Fatalf("messages pending for too long should be dropped, got %+v", stats)
+	}
+}
+
+// TestUpdatePending tests that only the messages of the signers whose key is known are verified
+func TestUpdatePending(t *testing.T) {
+	keys := make(map[string]crypto.Signer)
+	for _, name := range []string{"source", "A", "B", "C", "D"} {
+		keys[name], _ = GenerateKey(KeyAlgorithmEd25519, 0)
+	}
+	now := time.Now()
+
+	trusted := []TrustedKeyRecord{
+		{KeyRecord: KeyRecord{Owner: "A", KeyPub: keys["A"].Public()}, Confidence: 1.0},
+	}
+	ring := NewKeyRing("source", keys["source"].Public(), trusted, 0.0)
+
+	// B signs C, B's key is not known yet ; D is never known
+	ring.AddUnverified(pendingMessageBy(t, "B", keys["B"], "C", keys["C"].Public(), now))
+	forged := pendingMessageBy(t, "B", keys["D"], "A", keys["D"].Public(), now)
+	ring.AddUnverified(forged)
+	ring.AddUnverified(pendingMessageBy(t, "D", keys["D"], "C", keys["C"].Public(), now))
+
+	ring.updatePending(nil)
+	if stats := ring.PendingStats(); stats.Messages != 3 {
+		t.Fatalf("messages of unknown signers should stay pending, got %+v", stats)
+	}
+
+	// A signs B
+	ring.AddMessage(pendingMessageBy(t, "A", keys["A"], "B", keys["B"].Public(), now), 1.0)
+	ring.updatePending(nil)
+
+	stats := ring.PendingStats()
+	if stats.Messages != 1 || stats.Signers["D"] != 1 || stats.Verified != 1 || stats.Rejected != 1 {
+		t.Fatalf("only the messages of B should be verified, got %+v", stats)
+	}
+	if key, ok := ring.GetKey("C"); !ok || !pubKeyEquals(key, keys["C"].Public()) {
+		t.Fatalf("key of C signed by B should be added")
+	}
+}
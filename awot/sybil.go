@@ -0,0 +1,266 @@
+package awot
+
+import (
+	"sort"
+	"sync"
+
+	"gonum.org/v1/gonum/graph"
+)
+
+// A SybilConfig holds the parameters of the Sybil analysis of a KeyRing
+type SybilConfig struct {
+	MinClusterSize int     // minimum number of peers of a suspicious cluster
+	Threshold      float32 // suspicion from which the probability of a peer is capped
+	Cap            float32 // maximum probability of the suspicious peers, 1 for no cap
+}
+
+// DefaultSybilConfig returns the default parameters of the Sybil analysis, without cap
+func DefaultSybilConfig() SybilConfig {
+	return SybilConfig{
+		MinClusterSize: 3,
+		Threshold:      0.5,
+		Cap:            1.0,
+	}
+}
+
+// A SybilCluster is a set of peers signing each other, outside of the trusted core of the ring
+type SybilCluster struct {
+	Members         []string // peers of the cluster, sorted
+	Density         float32  // ratio of the possible signatures between the members that exist
+	ExternalSigners []string // peers outside of the cluster that signed a member, sorted
+	Support         float32  // trust put in the external signers, a peer of the trusted core counting for 1
+	Suspicion       float32  // between 0 and 1, the higher the more likely the cluster is made of Sybil identities
+}
+
+// A SybilReport is the result of the Sybil analysis of a KeyRing
+type SybilReport struct {
+	Clusters  []SybilCluster     // clusters of peers, by decreasing suspicion
+	Suspicion map[string]float32 // peer name -> suspicion of its cluster
+}
+
+// A sybilAnalysis holds the parameters and the last report of the Sybil analysis of a KeyRing, thread safe
+type sybilAnalysis struct {
+	config SybilConfig
+	report SybilReport
+	mutex  *sync.Mutex
+}
+
+// newSybilAnalysis creates a sybilAnalysis with given parameters and an empty report
+func newSybilAnalysis(config SybilConfig) *sybilAnalysis {
+	return &sybilAnalysis{
+		config: config,
+		report: SybilReport{Clusters: []SybilCluster{}, Suspicion: make(map[string]float32)},
+		mutex:  &sync.Mutex{},
+	}
+}
+
+// suspicion returns the suspicion of the peer with given name in the last report
+func (analysis *sybilAnalysis) suspicion(name string) float32 {
+	analysis.mutex.Lock()
+	defer analysis.mutex.Unlock()
+	return analysis.report.Suspicion[name]
+}
+
+// suspected returns true if the peer with given name is considered as a Sybil identity in the last report
+func (analysis *sybilAnalysis) suspected(name string) bool {
+	analysis.mutex.Lock()
+	defer analysis.mutex.Unlock()
+	return analysis.report.Suspicion[name] >= analysis.config.Threshold
+}
+
+// capProbability returns the given probability of the peer with given name, capped if the peer is suspicious
+func (analysis *sybilAnalysis) capProbability(name string, probability float32) float32 {
+	analysis.mutex.Lock()
+	defer analysis.mutex.Unlock()
+	if analysis.report.Suspicion[name] >= analysis.config.Threshold && probability > analysis.config.Cap {
+		return analysis.config.Cap
+	}
+	return probability
+}
+
+////////// Key Ring API
+
+// SetSybilConfig sets the parameters of the Sybil analysis
+func (ring *KeyRing) SetSybilConfig(config SybilConfig) {
+	ring.sybils.mutex.Lock()
+	ring.sybils.config = config
+	ring.sybils.mutex.Unlock()
+}
+
+// SybilReport returns the result of the last Sybil analysis of the ring
+func (ring KeyRing) SybilReport() SybilReport {
+	ring.sybils.mutex.Lock()
+	defer ring.sybils.mutex.Unlock()
+	return ring.sybils.report
+}
+
+// AnalyzeSybils looks for clusters of peers signing each other but with little support from the trusted core of the ring,
+// the trusted core being the owner of the ring and the peers it signed directly.
+// The clusters are the strongly connected components of the ring without the core. The suspicion of a cluster of
+// at least MinClusterSize peers is its density, reduced by the trust put in its external signers relatively to its size.
+// The report is kept by the ring, and used for capping the probability of the suspicious peers.
+func (ring *KeyRing) AnalyzeSybils() SybilReport {
+	ring.sybils.mutex.Lock()
+	config := ring.sybils.config
+	ring.sybils.mutex.Unlock()
+
+	ring.mutex.Lock()
+	report := ring.analyzeSybils(config)
+	ring.mutex.Unlock()
+
+	ring.sybils.mutex.Lock()
+	ring.sybils.report = report
+	ring.sybils.mutex.Unlock()
+	return report
+}
+
+////////// Implementation
+
+// analyzeSybils computes the Sybil report of the ring with given parameters
+// thread unsafe
+func (ring *KeyRing) analyzeSybils(config SybilConfig) SybilReport {
+	source := *ring.ids[ring.source]
+	core := map[int64]bool{source.ID(): true}
+	for _, n := range ring.graph.From(source) {
+		core[n.ID()] = true
+	}
+
+	report := SybilReport{
+		Clusters:  make([]SybilCluster, 0),
+		Suspicion: make(map[string]float32),
+	}
+
+	for _, component := range ring.components(core) {
+		if len(component) < config.MinClusterSize {
+			continue
+		}
+		cluster := ring.cluster(component, core)
+		report.Clusters = append(report.Clusters, cluster)
+		for _, name := range cluster.Members {
+			report.Suspicion[name] = cluster.Suspicion
+		}
+	}
+
+	sort.Slice(report.Clusters, func(i, j int) bool {
+		if report.Clusters[i].Suspicion != report.Clusters[j].Suspicion {
+			return report.Clusters[i].Suspicion > report.Clusters[j].Suspicion
+		}
+		return report.Clusters[i].Members[0] < report.Clusters[j].Members[0]
+	})
+	return report
+}
+
+// cluster computes the density, support and suspicion of the given component
+// thread unsafe
+func (ring *KeyRing) cluster(component []graph.Node, core map[int64]bool) SybilCluster {
+	members := make(map[int64]bool, len(component))
+	for _, n := range component {
+		members[n.ID()] = true
+	}
+
+	internal := 0
+	external := make(map[string]float32)
+	for _, n := range component {
+		for _, from := range ring.graph.To(n) {
+			if members[from.ID()] {
+				internal++
+				continue
+			}
+			weight := *(from.(Node).probability)
+			if core[from.ID()] {
+				weight = 1.0
+			}
+			external[from.(Node).name] = weight
+		}
+	}
+
+	size := float32(len(component))
+	density := float32(internal) / (size * (size - 1))
+
+	support := float32(0.0)
+	signers := make([]string, 0, len(external))
+	for name, weight := range external {
+		support += weight
+		signers = append(signers, name)
+	}
+	sort.Strings(signers)
+
+	names := make([]string, 0, len(component))
+	for _, n := range component {
+		names = append(names, n.(Node).name)
+	}
+	sort.Strings(names)
+
+	suspicion := density * (1 - support/size)
+	if suspicion < 0 {
+		suspicion = 0
+	}
+
+	return SybilCluster{
+		Members:         names,
+		Density:         density,
+		ExternalSigners: signers,
+		Support:         support,
+		Suspicion:       suspicion,
+	}
+}
+
+// components returns the strongly connected components of the ring without the given excluded nodes (Tarjan)
+// thread unsafe
+func (ring *KeyRing) components(excluded map[int64]bool) [][]graph.Node {
+	index := make(map[int64]int)
+	lowlink := make(map[int64]int)
+	onStack := make(map[int64]bool)
+	stack := make([]graph.Node, 0)
+	components := make([][]graph.Node, 0)
+	next := 0
+
+	var connect func(v graph.Node)
+	connect = func(v graph.Node) {
+		index[v.ID()] = next
+		lowlink[v.ID()] = next
+		next++
+		stack = append(stack, v)
+		onStack[v.ID()] = true
+
+		for _, w := range ring.graph.From(v) {
+			if excluded[w.ID()] {
+				continue
+			}
+			if _, visited := index[w.ID()]; !visited {
+				connect(w)
+				if lowlink[w.ID()] < lowlink[v.ID()] {
+					lowlink[v.ID()] = lowlink[w.ID()]
+				}
+			} else if onStack[w.ID()] && index[w.ID()] < lowlink[v.ID()] {
+				lowlink[v.ID()] = index[w.ID()]
+			}
+		}
+
+		if lowlink[v.ID()] == index[v.ID()] {
+			component := make([]graph.Node, 0)
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w.ID()] = false
+				component = append(component, w)
+				if w.ID() == v.ID() {
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	}
+
+	nodes := ring.graph.Nodes()
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID() < nodes[j].ID() })
+	for _, v := range nodes {
+		if excluded[v.ID()] {
+			continue
+		}
+		if _, visited := index[v.ID()]; !visited {
+			connect(v)
+		}
+	}
+	return components
+}
@@ -0,0 +1,108 @@
+// Tests for the Sybil analysis
+package awot
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"testing"
+)
+
+// TestSybilAnalysis tests that a cluster of peers signing each other with a single attack edge is detected
+func TestSybilAnalysis(t *testing.T) {
+	keys := make(map[string]*rsa.PrivateKey)
+	key := func(name string) rsa.PublicKey {
+		if keys[name] == nil {
+			k, err := rsa.GenerateKey(rand.Reader, 1024)
+			if err != nil {
+				t.Fatalf("could not generate rsa key: %v", err)
+			}
+			keys[name] = k
+		}
+		return keys[name].PublicKey
+	}
+
+	// source fully trusts A, B and C
+	trusted := []TrustedKeyRecord{
+		{KeyRecord: KeyRecord{Owner: "A", KeyPub: key("A")}, Confidence: 1.0},
+		{KeyRecord: KeyRecord{Owner: "B", KeyPub: key("B")}, Confidence: 1.0},
+		{KeyRecord: KeyRecord{Owner: "C", KeyPub: key("C")}, Confidence: 1.0},
+	}
+	ring := NewKeyRing("source", key("source"), trusted, 0.0)
+
+	// honest peers H0, H1, H2 sign each other and are signed by A, B and C
+	for i := 0; i < 3; i++ {
+		h := fmt.Sprintf("H%d", i)
+		for _, signer := range []string{"A", "B", "C"} {
+			ring.Add(KeyRecord{Owner: h, KeyPub: key(h)}, signer, 1.0)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			if i != j {
+				h := fmt.Sprintf("H%d", j)
+				ring.Add(KeyRecord{Owner: h, KeyPub: key(h)}, fmt.Sprintf("H%d", i), 1.0)
+			}
+		}
+	}
+
+	// A signed the attacker X, which signs 6 Sybil identities signing each other
+	ring.Add(KeyRecord{Owner: "X", KeyPub: key("X")}, "A", 1.0)
+	n := 6
+	for i := 0; i < n; i++ {
+		s := fmt.Sprintf("S%d", i)
+		ring.Add(KeyRecord{Owner: s, KeyPub: key(s)}, "X", 1.0)
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if i != j {
+				s := fmt.Sprintf("S%d", j)
+				ring.Add(KeyRecord{Owner: s, KeyPub: key(s)}, fmt.Sprintf("S%d", i), 1.0)
+			}
+		}
+	}
+	ring.updateTrust(nil)
+
+	report := ring.AnalyzeSybils()
+	if len(report.Clusters) != 2 {
+		t.Fatalf("the honest and the Sybil clusters should be found, got %v clusters", len(report.Clusters))
+	}
+	sybils := report.Clusters[0]
+	if len(sybils.Members) != n || sybils.Members[0] != "S0" {
+		t.Fatalf("the most suspicious cluster should be the Sybil identities, got %v", sybils.Members)
+	}
+	if len(sybils.ExternalSigners) != 1 || sybils.ExternalSigners[0] != "X" {
+		t.Fatalf("the only external signer of the Sybil identities should be X, got %v", sybils.ExternalSigners)
+	}
+	if report.Suspicion["S0"] < 0.5 || report.Suspicion["H0"] != 0 {
+		t.Fatalf("only the Sybil identities should be suspicious, got S0 %v and H0 %v", report.Suspicion["S0"], report.Suspicion["H0"])
+	}
+
+	// the probability of the Sybil identities is capped
+	before := *ring.ids["S0"].probability
+	ring.SetSybilConfig(SybilConfig{MinClusterSize: 3, Threshold: 0.5, Cap: 0.05})
+	ring.updateTrust(nil)
+	if p := *ring.ids["S0"].probability; p != 0.05 || before <= 0.05 {
+		t.Fatalf("probability of S0 should be capped to 0.05, was %v, got %v", before, p)
+	}
+	if p := *ring.ids["H0"].probability; p <= 0.05 {
+		t.Fatalf("probability of H0 should not be capped, got %v", p)
+	}
+
+	// the suspicion is part of the visualization, flagged against the threshold of the config
+	for _, threshold := range []float32{0.5, 1.1} {
+		ring.SetSybilConfig(SybilConfig{MinClusterSize: 3, Threshold: threshold, Cap: 0.05})
+		found := false
+		for _, v := range GraphVizRepr(ring).Nodes {
+			if v.Name == "S0" {
+				found = v.Suspicion == report.Suspicion["S0"] && v.Suspected == (threshold <= 1)
+			}
+			if v.Name == "H0" && v.Suspected {
+				t.Fatalf("visualization should not flag H0 as a Sybil identity")
+			}
+		}
+		if !found {
+			t.Fatalf("visualization should contain the suspicion of S0, flagged against threshold %v", threshold)
+		}
+	}
+}
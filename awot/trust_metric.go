@@ -0,0 +1,358 @@
+package awot
+
+import (
+	"crypto"
+	"errors"
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/graph"
+)
+
+// A TrustMetric computes the confidence level of the association between a peer and a public key, given the signatures of the ring.
+// The confidence must only depend on the part of the ring upstream of the peer (the peers from which it can be reached),
+// as the ring computes again only the confidence of the peers downstream of a change.
+type TrustMetric interface {
+	// Name returns the name of the metric
+	Name() string
+	// Confidence returns the confidence level in the association of the given key to the peer with given name,
+	// as a float32 between 0 and 1, and a bound of the uncertainty of the result (0 if exact)
+	Confidence(g *TrustGraph, name string, key crypto.PublicKey) (float32, float32)
+}
+
+// ParseTrustMetric returns the TrustMetric with given name : "path", "flow" or "beta", with default parameters
+func ParseTrustMetric(name string) (TrustMetric, error) {
+	switch name {
+	case "path":
+		return NewPathMetric(), nil
+	case "flow":
+		return NewFlowMetric(DefaultFlowCapacities, DefaultFlowRequired), nil
+	case "beta":
+		return NewBetaMetric(DefaultBetaPriorWeight), nil
+	}
+	return nil, errors.New("unknown trust metric " + name)
+}
+
+// A TrustGraph is a read only view of a KeyRing given to the trust metrics while the confidence levels are computed
+// It must not be used outside of TrustMetric.Confidence.
+type TrustGraph struct {
+	ring *KeyRing
+	sp   shortestPaths
+}
+
+// trustGraph returns the view of the ring for the metrics, given the shortest paths from the source
+// thread unsafe
+func (ring *KeyRing) trustGraph(sp shortestPaths) *TrustGraph {
+	return &TrustGraph{
+		ring: ring,
+		sp:   sp,
+	}
+}
+
+// Source returns the name of the owner of the ring
+func (g *TrustGraph) Source() string {
+	return g.ring.source
+}
+
+// Peers returns the names of the peers in the ring, sorted
+func (g *TrustGraph) Peers() []string {
+	peers := make([]string, 0, len(g.ring.ids))
+	for name := range g.ring.ids {
+		peers = append(peers, name)
+	}
+	sort.Strings(peers)
+	return peers
+}
+
+// Probability returns the trust put in the peer with given name for signing keys, between 0 and 1
+func (g *TrustGraph) Probability(name string) float32 {
+	if n, ok := g.ring.ids[name]; ok {
+		return *(n.probability)
+	}
+	return 0.0
+}
+
+// Distance returns the number of signatures from the source to the peer with given name, +Inf if it cannot be reached
+func (g *TrustGraph) Distance(name string) float64 {
+	if n, ok := g.ring.ids[name]; ok {
+		return g.sp.distance(*n)
+	}
+	return math.Inf(1)
+}
+
+// Signatures returns the peers that signed a key of the peer with given name, with the signed key
+func (g *TrustGraph) Signatures(name string) map[string]crypto.PublicKey {
+	signatures := make(map[string]crypto.PublicKey)
+	n, ok := g.ring.ids[name]
+	if !ok {
+		return signatures
+	}
+	for _, from := range g.ring.graph.To(*n) {
+		if edge := g.ring.graph.Edge(from, *n); edge != nil {
+			signatures[from.(Node).name] = edge.(Edge).Key
+		}
+	}
+	return signatures
+}
+
+// Signers returns the peers that signed the given key of the peer with given name, sorted
+func (g *TrustGraph) Signers(name string, key crypto.PublicKey) []string {
+	signers := make([]string, 0)
+	for signer, k := range g.Signatures(name) {
+		if keyID(k) == keyID(key) {
+			signers = append(signers, signer)
+		}
+	}
+	sort.Strings(signers)
+	return signers
+}
+
+// Signed returns the peers whose key was signed by the peer with given name, sorted
+func (g *TrustGraph) Signed(name string) []string {
+	signed := make([]string, 0)
+	n, ok := g.ring.ids[name]
+	if !ok {
+		return signed
+	}
+	for _, to := range g.ring.graph.From(*n) {
+		signed = append(signed, to.(Node).name)
+	}
+	sort.Strings(signed)
+	return signed
+}
+
+// paths returns the shortest paths from the source to the peer with given name whose last signature is for the given key
+func (g *TrustGraph) paths(name string, key crypto.PublicKey) [][]graph.Node {
+	n, ok := g.ring.ids[name]
+	if !ok {
+		return nil
+	}
+	return g.ring.pathsWithKey(g.sp.allTo(*n, g.ring.confidence.config.MaxPaths), key)
+}
+
+////////// Path metric
+
+// pathMetric is the original AWOT metric : the probability that at least one of the shortest paths to the peer is trustworthy,
+// the trust of each peer being phi = min(1/d, rep)
+type pathMetric struct{}
+
+// NewPathMetric returns the original AWOT metric, based on the probability of the shortest paths from the source
+func NewPathMetric() TrustMetric {
+	return pathMetric{}
+}
+
+// Name returns the name of the metric
+func (pathMetric) Name() string {
+	return "path"
+}
+
+// Confidence computes the probability of the shortest paths ending with the given key, using the ConfidenceConfig of the ring
+func (pathMetric) Confidence(g *TrustGraph, name string, key crypto.PublicKey) (float32, float32) {
+	return g.ring.confidence.probability(g.paths(name, key))
+}
+
+////////// Flow metric
+
+// DefaultFlowCapacities are the default capacities of the peers of the flow metric, by distance to the source
+var DefaultFlowCapacities = []int{16, 8, 4, 2, 1}
+
+// DefaultFlowRequired is the default flow needed by the flow metric for a full confidence
+const DefaultFlowRequired = 2
+
+// flowMetric is an Advogato style metric : the trust flows from the source through the signatures,
+// each peer letting at most a capacity depending on its distance to the source go through it.
+// The confidence is the maximum flow reaching the key, relative to the flow required.
+// A cluster of Sybil identities signed by a single peer cannot receive more than the capacity of this peer.
+type flowMetric struct {
+	capacities []int // capacity of the peers by distance to the source, the last one is used for further peers
+	required   int   // flow needed for a full confidence
+}
+
+// NewFlowMetric returns an Advogato style metric, with given capacities of the peers by distance to the source,
+// and given flow required for a full confidence
+func NewFlowMetric(capacities []int, required int) TrustMetric {
+	if len(capacities) == 0 {
+		capacities = DefaultFlowCapacities
+	}
+	if required < 1 {
+		required = 1
+	}
+	return flowMetric{
+		capacities: capacities,
+		required:   required,
+	}
+}
+
+// Name returns the name of the metric
+func (flowMetric) Name() string {
+	return "flow"
+}
+
+// capacity returns the capacity of the peer with given name
+// peers not trusted for signing (probability 0, e.g. distrusted or revoked) have no capacity
+func (m flowMetric) capacity(g *TrustGraph, name string) int {
+	if name != g.Source() && g.Probability(name) <= 0 {
+		return 0
+	}
+	d := g.Distance(name)
+	if math.IsInf(d, 1) {
+		return 0
+	}
+	if int(d) >= len(m.capacities) {
+		return m.capacities[len(m.capacities)-1]
+	}
+	return m.capacities[int(d)]
+}
+
+// Confidence computes the maximum flow from the source to the peer with given name through the signatures of the given key
+func (m flowMetric) Confidence(g *TrustGraph, name string, key crypto.PublicKey) (float32, float32) {
+	// each peer is split in an input and an output vertex, linked by an edge of the peer's capacity
+	// vertex 2*i is the input of peer i, 2*i+1 its output
+	peers := g.Peers()
+	index := make(map[string]int, len(peers))
+	for i, p := range peers {
+		index[p] = i
+	}
+	target, ok := index[name]
+	if !ok {
+		return 0.0, 0.0
+	}
+	residual := make(map[int]map[int]int)
+	addArc := func(u, v, c int) {
+		if residual[u] == nil {
+			residual[u] = make(map[int]int)
+		}
+		if residual[v] == nil {
+			residual[v] = make(map[int]int)
+		}
+		residual[u][v] += c
+	}
+
+	infinite := m.required
+	for _, p := range peers {
+		if p == name {
+			continue
+		}
+		addArc(2*index[p], 2*index[p]+1, m.capacity(g, p))
+		for _, q := range g.Signed(p) {
+			if q == name {
+				continue
+			}
+			addArc(2*index[p]+1, 2*index[q], infinite)
+		}
+	}
+	for _, signer := range g.Signers(name, key) {
+		addArc(2*index[signer]+1, 2*target, infinite)
+	}
+
+	flow := maxFlow(residual, 2*index[g.Source()], 2*target, m.required)
+	return float32(flow) / float32(m.required), 0.0
+}
+
+// maxFlow computes the maximum flow from s to t in the given residual graph, up to the given limit (Edmonds-Karp)
+func maxFlow(residual map[int]map[int]int, s, t, limit int) int {
+	flow := 0
+	for flow < limit {
+		// breadth first search of an augmenting path
+		parent := map[int]int{s: s}
+		queue := []int{s}
+		for len(queue) > 0 && !containsKey(parent, t) {
+			u := queue[0]
+			queue = queue[1:]
+			next := make([]int, 0, len(residual[u]))
+			for v := range residual[u] {
+				next = append(next, v)
+			}
+			sort.Ints(next)
+			for _, v := range next {
+				if residual[u][v] > 0 && !containsKey(parent, v) {
+					parent[v] = u
+					queue = append(queue, v)
+				}
+			}
+		}
+		if !containsKey(parent, t) {
+			break
+		}
+
+		// bottleneck of the path
+		bottleneck := limit - flow
+		for v := t; v != s; v = parent[v] {
+			if c := residual[parent[v]][v]; c < bottleneck {
+				bottleneck = c
+			}
+		}
+		for v := t; v != s; v = parent[v] {
+			residual[parent[v]][v] -= bottleneck
+			residual[v][parent[v]] += bottleneck
+		}
+		flow += bottleneck
+	}
+	return flow
+}
+
+// containsKey checks if the given key is in the map
+func containsKey(m map[int]int, key int) bool {
+	_, ok := m[key]
+	return ok
+}
+
+////////// Beta metric
+
+// DefaultBetaPriorWeight is the default weight of the prior of the beta metric
+const DefaultBetaPriorWeight = 2.0
+
+// betaMetric is a beta reputation metric : each signature of the key is a positive evidence and each signature of another key
+// of the same peer is a negative evidence, weighted by the trust put in its signer.
+// The confidence is the belief r / (r + s + W), and the uncertainty W / (r + s + W) is returned as the bound.
+type betaMetric struct {
+	priorWeight float32 // weight W of the prior
+}
+
+// NewBetaMetric returns a beta reputation metric with given weight of the prior
+func NewBetaMetric(priorWeight float32) TrustMetric {
+	if priorWeight <= 0 {
+		priorWeight = DefaultBetaPriorWeight
+	}
+	return betaMetric{
+		priorWeight: priorWeight,
+	}
+}
+
+// Name returns the name of the metric
+func (betaMetric) Name() string {
+	return "beta"
+}
+
+// Confidence computes the belief in the association of the key to the peer with given name
+func (m betaMetric) Confidence(g *TrustGraph, name string, key crypto.PublicKey) (float32, float32) {
+	positive := float32(0.0)
+	negative := float32(0.0)
+	for signer, k := range g.Signatures(name) {
+		weight := g.Probability(signer)
+		if keyID(k) == keyID(key) {
+			positive += weight
+		} else {
+			negative += weight
+		}
+	}
+	total := positive + negative + m.priorWeight
+	return positive / total, m.priorWeight / total
+}
+
+////////// Key Ring API
+
+// SetTrustMetric sets the metric used for computing the confidence levels, and computes them again
+func (ring *KeyRing) SetTrustMetric(metric TrustMetric) {
+	ring.mutex.Lock()
+	ring.confidence.metric = metric
+	ring.mutex.Unlock()
+	ring.updateAllConfidence()
+}
+
+// TrustMetric returns the metric used for computing the confidence levels
+func (ring KeyRing) TrustMetric() TrustMetric {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+	return ring.confidence.metric
+}
@@ -0,0 +1,195 @@
+// Tests and comparison of the trust metrics
+package awot
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"testing"
+)
+
+// signature is the signature of a key of owner by signer, the key being designated by its name
+type signature struct {
+	signer string
+	owner  string
+	key    string
+}
+
+// scenario is a synthetic key ring : the peers fully trusted by the source and the signatures, in order
+type scenario struct {
+	name      string
+	trusted   []string
+	sigs      []signature
+	evaluated []signature // keys whose confidence is compared, signer is ignored
+}
+
+// sybilScenario creates a scenario where the source trusts A and B, which both sign the key "T" of T.
+// A also signs the key of the attacker X, which signs n Sybil identities, each signing the fake key "fake" of T.
+func sybilScenario(n int) scenario {
+	sigs := []signature{
+		{"A", "T", "T"},
+		{"B", "T", "T"},
+		{"A", "X", "X"},
+	}
+	for i := 0; i < n; i++ {
+		sybil := fmt.Sprintf("S%d", i)
+		sigs = append(sigs, signature{"X", sybil, "sybil"})
+		sigs = append(sigs, signature{sybil, "T", "fake"})
+	}
+	return scenario{
+		name:      fmt.Sprintf("sybil cluster of %d", n),
+		trusted:   []string{"A", "B"},
+		sigs:      sigs,
+		evaluated: []signature{{"", "T", "T"}, {"", "T", "fake"}},
+	}
+}
+
+// scenarios returns the synthetic graphs on which the metrics are compared
+func scenarios() []scenario {
+	return []scenario{
+		{
+			name:      "chain",
+			trusted:   []string{"A"},
+			sigs:      []signature{{"A", "B", "B"}, {"B", "C", "C"}, {"C", "D", "D"}},
+			evaluated: []signature{{"", "B", "B"}, {"", "C", "C"}, {"", "D", "D"}},
+		},
+		{
+			name:      "multiple signers",
+			trusted:   []string{"A", "B", "C"},
+			sigs:      []signature{{"A", "T", "T"}, {"B", "T", "T"}, {"C", "T", "T"}},
+			evaluated: []signature{{"", "T", "T"}},
+		},
+		{
+			name:    "collision",
+			trusted: []string{"A", "B", "C"},
+			sigs:    []signature{{"A", "T", "T"}, {"B", "T", "T"}, {"C", "T", "fake"}},
+			evaluated: []signature{
+				{"", "T", "T"},
+				{"", "T", "fake"},
+			},
+		},
+		sybilScenario(3),
+		sybilScenario(20),
+	}
+}
+
+// build creates the key ring of the scenario, the keys being generated from the given pool
+func (s scenario) build(t *testing.T, keys map[string]*rsa.PrivateKey) *KeyRing {
+	key := func(name string) rsa.PublicKey {
+		if keys[name] == nil {
+			k, err := rsa.GenerateKey(rand.Reader, 1024)
+			if err != nil {
+				t.Fatalf("could not generate rsa key: %v", err)
+			}
+			keys[name] = k
+		}
+		return keys[name].PublicKey
+	}
+
+	trusted := make([]TrustedKeyRecord, 0)
+	for _, name := range s.trusted {
+		trusted = append(trusted, TrustedKeyRecord{KeyRecord: KeyRecord{Owner: name, KeyPub: key(name)}, Confidence: 1.0})
+	}
+	ring := NewKeyRing("source", key("source"), trusted, 0.0)
+	for _, sig := range s.sigs {
+		ring.Add(KeyRecord{Owner: sig.owner, KeyPub: key(sig.key)}, sig.signer, 1.0)
+	}
+	ring.updateTrust(nil)
+	return &ring
+}
+
+// metricConfidence returns the confidence given by the metric to the key of owner in the ring
+func metricConfidence(ring *KeyRing, metric TrustMetric, owner string, key rsa.PublicKey) (float32, float32) {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+	return metric.Confidence(ring.trustGraph(ring.searchFromSource()), owner, key)
+}
+
+// TestTrustMetricsComparison runs every metric on the same synthetic graphs and reports the confidences they give
+func TestTrustMetricsComparison(t *testing.T) {
+	metrics := []TrustMetric{
+		NewPathMetric(),
+		NewFlowMetric(DefaultFlowCapacities, DefaultFlowRequired),
+		NewBetaMetric(DefaultBetaPriorWeight),
+	}
+	keys := make(map[string]*rsa.PrivateKey)
+
+	// results[scenario][metric][owner/key]
+	results := make(map[string]map[string]map[string]float32)
+
+	for _, s := range scenarios() {
+		ring := s.build(t, keys)
+		results[s.name] = make(map[string]map[string]float32)
+		for _, metric := range metrics {
+			results[s.name][metric.Name()] = make(map[string]float32)
+			for _, e := range s.evaluated {
+				c, bound := metricConfidence(ring, metric, e.owner, keys[e.key].PublicKey)
+				if c < 0 || c > 1 || bound < 0 || bound > 1 {
+					t.Fatalf("%s metric gives invalid confidence %v (uncertainty %v) on %s", metric.Name(), c, bound, s.name)
+				}
+				results[s.name][metric.Name()][e.owner+"/"+e.key] = c
+				t.Logf("%-20s %-6s %-8s confidence %.3f uncertainty %.3f", s.name, metric.Name(), e.owner+"/"+e.key, c, bound)
+			}
+		}
+	}
+
+	chain := results["chain"]
+	for _, metric := range []string{"path", "flow"} {
+		if !(chain[metric]["B/B"] >= chain[metric]["C/C"] && chain[metric]["C/C"] >= chain[metric]["D/D"]) {
+			t.Fatalf("%s metric should not increase the confidence with the distance", metric)
+		}
+	}
+
+	// with capacities lower than the flow required at the distance of the attacker,
+	// the flow through the attacker bounds the confidence in the fake key
+	tight := NewFlowMetric([]int{4, 2, 1}, 2)
+	bound := float32(1) / float32(2)
+
+	for _, n := range []int{3, 20} {
+		sybil := results[fmt.Sprintf("sybil cluster of %d", n)]
+		// the fake key has no shortest path
+		if sybil["path"]["T/fake"] != 0 {
+			t.Fatalf("path metric should not trust the key signed by the Sybil cluster only")
+		}
+
+		ring := sybilScenario(n).build(t, keys)
+		fake, _ := metricConfidence(ring, tight, "T", keys["fake"].PublicKey)
+		legit, _ := metricConfidence(ring, tight, "T", keys["T"].PublicKey)
+		if fake > bound {
+			t.Fatalf("flow metric should bound the confidence given by a Sybil cluster of %d to %v, got %v", n, bound, fake)
+		}
+		if fake >= legit {
+			t.Fatalf("flow metric should trust the fake key (%v) less than the legitimate one (%v)", fake, legit)
+		}
+	}
+
+	// the number of Sybil identities does not change the flow, but increases the evidence of the beta metric
+	small := results["sybil cluster of 3"]
+	large := results["sybil cluster of 20"]
+	if small["flow"]["T/fake"] != large["flow"]["T/fake"] {
+		t.Fatalf("flow metric should not depend on the size of the Sybil cluster")
+	}
+	if large["beta"]["T/fake"] <= small["beta"]["T/fake"] {
+		t.Fatalf("beta metric should count the signatures of the Sybil identities")
+	}
+}
+
+// TestSetTrustMetric tests that the metric of the ring is used for the confidence levels
+func TestSetTrustMetric(t *testing.T) {
+	keys := make(map[string]*rsa.PrivateKey)
+	ring := scenarios()[1].build(t, keys)
+
+	ring.SetTrustMetric(NewBetaMetric(DefaultBetaPriorWeight))
+	if ring.TrustMetric().Name() != "beta" {
+		t.Fatalf("metric of the ring should be the beta metric")
+	}
+	rec, _ := ring.GetRecord("T")
+	expected, bound := metricConfidence(ring, NewBetaMetric(DefaultBetaPriorWeight), "T", keys["T"].PublicKey)
+	if rec.Confidence != expected || rec.ConfidenceError != bound {
+		t.Fatalf("confidence of T should be given by the beta metric : expected %v, got %v", expected, rec.Confidence)
+	}
+
+	if _, err := ParseTrustMetric("unknown"); err == nil {
+		t.Fatalf("unknown metric should not be parsed")
+	}
+}
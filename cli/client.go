@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"github.com/No-Trust/peerster/common"
 	"github.com/dedis/protobuf"
+	"io/ioutil"
 	"net"
+	"path/filepath"
 )
 
 func main() {
@@ -18,6 +20,21 @@ func main() {
 	filename := flag.String("file", "", "file to be indexed")
 	request := flag.String("request", "", "metahash of the file to download")
 	origin := flag.String("origin", "", "origin of the file to download")
+	owner := flag.String("owner", "", "peer concerned by a trust decision")
+	importKey := flag.String("import", "", "public key file (pem) to import as the key of owner")
+	confidence := flag.Float64("confidence", 1.0, "confidence given to an imported key")
+	distrust := flag.Bool("distrust", false, "distrust the current key of owner")
+	pin := flag.String("pin", "", "fingerprint (hex or words) of the only key accepted for owner")
+	reset := flag.Bool("reset", false, "forget every trust decision on owner")
+	verify := flag.Bool("verify", false, "show the fingerprint and short authentication string of the key of owner")
+	confirm := flag.String("confirm", "", "short authentication string compared with owner, to fully trust its key")
+	explain := flag.Bool("explain", false, "explain the confidence level of the key of owner")
+	history := flag.String("history", "", "name or address of the peer whose reputation history is shown")
+	revoke := flag.Bool("revoke", false, "withdraw the signature on the key of owner, or revoke own key if owner is this gossiper")
+	rotate := flag.Bool("rotate", false, "replace the key of the gossiper by a new one")
+	exportRing := flag.String("exportring", "", "file to which the gossiper exports its key ring")
+	format := flag.String("format", "jwks", "format of the key ring export : jwks or pem")
+	importRing := flag.String("importring", "", "key ring export (jwks or pem) whose signatures are added to the key ring of the gossiper")
 	flag.Parse()
 
 	pkt := common.ClientPacket{}
@@ -83,6 +100,95 @@ func main() {
 		pkt.NewPrivateMessage = &newPrivateMessage
 	}
 
+	if *history != "" {
+		// reputation history of a peer
+
+		fmt.Println("Sending reputation history request")
+
+		pkt.RepHistory = history
+	}
+
+	if *owner != "" && *revoke {
+		// key revocation
+
+		fmt.Println("Sending key revocation")
+
+		pkt.RevokeKey = owner
+
+	} else if *owner != "" && (*verify || *confirm != "") {
+		// out of band key verification
+
+		fmt.Println("Sending key verification")
+
+		pkt.VerifyKey = &common.KeyVerification{
+			Owner: *owner,
+			SAS:   *confirm,
+		}
+
+	} else if *owner != "" && *explain {
+		// explanation of a key confidence
+
+		fmt.Println("Sending key explanation request")
+
+		pkt.ExplainKey = owner
+
+	} else if *owner != "" {
+		// manual trust decision
+
+		trustUpdate := common.TrustUpdate{
+			Owner:       *owner,
+			Confidence:  float32(*confidence),
+			Distrust:    *distrust,
+			Fingerprint: *pin,
+			Reset:       *reset,
+		}
+
+		if *importKey != "" {
+			keyBytes, err := ioutil.ReadFile(*importKey)
+			common.CheckError(err)
+			trustUpdate.KeyBytes = keyBytes
+		}
+
+		fmt.Println("Sending trust update")
+
+		pkt.TrustUpdate = &trustUpdate
+	}
+
+	if *rotate {
+		// key rotation
+
+		fmt.Println("Sending key rotation request")
+
+		pkt.RotateKey = rotate
+	}
+
+	if *exportRing != "" {
+		// key ring export
+
+		path, err := filepath.Abs(*exportRing)
+		common.CheckError(err)
+
+		fmt.Println("Sending key ring export request")
+
+		pkt.ExportKeyRing = &common.KeyRingFile{
+			Path:   path,
+			Format: *format,
+		}
+	}
+
+	if *importRing != "" {
+		// key ring import
+
+		path, err := filepath.Abs(*importRing)
+		common.CheckError(err)
+
+		fmt.Println("Sending key ring import request")
+
+		pkt.ImportKeyRing = &common.KeyRingFile{
+			Path: path,
+		}
+	}
+
 	// send message to peer at port peerPort
 	ServerAddr, err := net.ResolveUDPAddr("udp4", "127.0.0.1:"+fmt.Sprint(*UIPort))
 	common.CheckError(err)
@@ -24,6 +24,17 @@ type ClientPacket struct {
 	Notification      *string            // notification from gossiper to the client
 	KeyRingJSON       *[]byte            // JSON format of the key ring
 	Reputations       *RepUpdate
+	TrustUpdate       *TrustUpdate     // manual trust decision from client
+	RevokeKey         *string          // name of the peer whose key signature is withdrawn, or own name to revoke own key
+	RotateKey         *bool            // key rotation request from client
+	ExportKeyRing     *KeyRingFile     // key ring export request from client
+	ImportKeyRing     *KeyRingFile     // key ring import request from client
+	VerifyKey         *KeyVerification // out of band verification of the key of a peer from client
+	PendingKeys       *PendingKeyStats // statistics on the key signatures waiting for the key of their signer
+	ExplainKey        *string          // name of the peer whose key confidence is explained
+	KeyExplanation    *[]byte          // JSON format of the explanation of the confidence of a key
+	RepHistory        *string          // name or address of the peer whose reputation history is requested
+	RepHistoryJSON    *[]byte          // JSON format of the reputation history of a peer
 }
 
 type NewMessage struct {
@@ -45,6 +56,43 @@ type NewFile struct {
 	Path string
 }
 
+// A manual trust decision on the key of a peer
+// Exactly one of KeyBytes, Distrust, Fingerprint or Reset is expected to be set
+type TrustUpdate struct {
+	Owner       string  // name of the peer
+	KeyBytes    []byte  // pem encoded public key to import for Owner
+	Confidence  float32 // confidence given to the imported key
+	Distrust    bool    // distrust the current key of Owner
+	Fingerprint string  // fingerprint of the only key accepted for Owner
+	Reset       bool    // forget every manual decision on Owner
+}
+
+// An out of band verification of the key of a peer
+// Without SAS, the gossiper gives the fingerprint and short authentication string of the key of Owner.
+// With SAS, the gossiper fully trusts the key of Owner if SAS is its short authentication string.
+type KeyVerification struct {
+	Owner string // name of the peer
+	SAS   string // short authentication string compared with Owner
+}
+
+// Statistics on the key signatures received from peers whose key is not known yet
+type PendingKeyStats struct {
+	Messages   int            // signatures currently pending
+	Signers    map[string]int // missing signer -> number of its pending signatures
+	Added      int            // signatures received without the key of their signer
+	Duplicates int            // signatures already pending
+	Dropped    int            // signatures dropped because of the size limits
+	Expired    int            // signatures dropped because expired or pending for too long
+	Verified   int            // signatures added to the key ring once the key of their signer was known
+	Rejected   int            // signatures that did not match the key of their signer
+}
+
+// A file holding an export of the key ring
+type KeyRingFile struct {
+	Path   string // path of the file on the gossiper's host
+	Format string // "jwks" or "pem" for an export, ignored for an import
+}
+
 type FileRequest struct {
 	MetaHash    []byte
 	Destination string
@@ -3,6 +3,7 @@ package common
 import (
 	"encoding/hex"
 	"fmt"
+	"strings"
 )
 
 func (msg *NewMessage) ClientNewMessageString() *string {
@@ -53,3 +54,123 @@ func AlreadyHaveFileNotification(filename string) *string {
 	str := fmt.Sprintf("FILE ALREADY PRESENT %s", filename)
 	return &str
 }
+
+func TrustUpdateNotification(owner string, err error) *string {
+	var str string
+	if err != nil {
+		str = fmt.Sprintf("TRUST UPDATE for %s FAILED : %v", owner, err)
+	} else {
+		str = fmt.Sprintf("TRUST UPDATE for %s DONE", owner)
+	}
+	return &str
+}
+
+func RevocationNotification(owner string, err error) *string {
+	var str string
+	if err != nil {
+		str = fmt.Sprintf("REVOCATION of key of %s FAILED : %v", owner, err)
+	} else {
+		str = fmt.Sprintf("REVOCATION of key of %s SENT", owner)
+	}
+	return &str
+}
+
+func RotationNotification(err error) *string {
+	var str string
+	if err != nil {
+		str = fmt.Sprintf("KEY ROTATION FAILED : %v", err)
+	} else {
+		str = fmt.Sprintf("KEY ROTATION DONE")
+	}
+	return &str
+}
+
+func KeyCollisionNotification(owner string, fingerprints []string, selected string) *string {
+	str := fmt.Sprintf("KEY COLLISION for %s : %d keys %s", owner, len(fingerprints), strings.Join(fingerprints, ","))
+	if selected != "" {
+		str += fmt.Sprintf(" SELECTED %s", selected)
+	} else {
+		str += " NONE SELECTED"
+	}
+	return &str
+}
+
+func KeyRingExportNotification(path string, err error) *string {
+	var str string
+	if err != nil {
+		str = fmt.Sprintf("KEY RING EXPORT to %s FAILED : %v", path, err)
+	} else {
+		str = fmt.Sprintf("KEY RING EXPORT to %s DONE", path)
+	}
+	return &str
+}
+
+func KeyRingImportNotification(path string, added, rejected, unknown int, err error) *string {
+	var str string
+	if err != nil {
+		str = fmt.Sprintf("KEY RING IMPORT from %s FAILED : %v", path, err)
+	} else {
+		str = fmt.Sprintf("KEY RING IMPORT from %s DONE : %d added %d rejected %d unknown signers", path, added, rejected, unknown)
+	}
+	return &str
+}
+
+func KeyVerificationNotification(owner, fingerprint, words, sas string, err error) *string {
+	var str string
+	if err != nil {
+		str = fmt.Sprintf("KEY VERIFICATION of %s FAILED : %v", owner, err)
+	} else {
+		str = fmt.Sprintf("KEY VERIFICATION of %s : fingerprint %s words %s SAS %s", owner, fingerprint, words, sas)
+	}
+	return &str
+}
+
+func KeyConfirmationNotification(owner string, err error) *string {
+	var str string
+	if err != nil {
+		str = fmt.Sprintf("KEY CONFIRMATION of %s FAILED : %v", owner, err)
+	} else {
+		str = fmt.Sprintf("KEY CONFIRMATION of %s DONE", owner)
+	}
+	return &str
+}
+
+func KeyLookupNotification(owner string, err error) *string {
+	var str string
+	if err != nil {
+		str = fmt.Sprintf("KEY LOOKUP for %s FAILED : %v", owner, err)
+	} else {
+		str = fmt.Sprintf("KEY LOOKUP for %s DONE", owner)
+	}
+	return &str
+}
+
+func KeyExplanationNotification(owner, explanation string, err error) *string {
+	var str string
+	if err != nil {
+		str = fmt.Sprintf("KEY EXPLANATION of %s FAILED : %v", owner, err)
+	} else {
+		str = fmt.Sprintf("KEY EXPLANATION of %s :\n%s", owner, explanation)
+	}
+	return &str
+}
+
+func PolicyNotification(peer, action string, lifted bool) *string {
+	var str string
+	if lifted {
+		str = fmt.Sprintf("POLICY %s on %s LIFTED", action, peer)
+	} else {
+		str = fmt.Sprintf("POLICY %s on %s ENFORCED", action, peer)
+	}
+	return &str
+}
+
+func RepHistoryNotification(peer, history string, events int) *string {
+	var str string
+	if events == 0 {
+		str = fmt.Sprintf("REPUTATION HISTORY of %s EMPTY", peer)
+	} else {
+		str = fmt.Sprintf("REPUTATION HISTORY of %s :\n%s", peer, history)
+	}
+	return &str
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/No-Trust/peerster/common"
+	"github.com/No-Trust/peerster/rep"
 )
 
 // Implementation of the anti entropy algorithm.
@@ -16,7 +17,7 @@ func antiEntropy(g *Gossiper, etimer uint) {
 
 	for range ticker.C {
 
-		randPeer := g.reputationTable.ContribRandomPeer()
+		randPeer := g.reputationTable.RandomPeer(rep.PURPOSE_RELAY, g.policy.quarantined)
 
 		if randPeer != "" {
 			// send status packet
@@ -3,7 +3,6 @@ package main
 import (
 	"bytes"
 	"crypto"
-	"crypto/rsa"
 	"crypto/sha256"
 	"fmt"
 	"os"
@@ -11,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/No-Trust/peerster/awot"
 	"github.com/No-Trust/peerster/common"
 )
 
@@ -96,10 +96,14 @@ func startDownload(g *Gossiper, filereq *common.FileRequest) {
 	knownOrigin := true          // true if the origin is known (this peer has its public key)
 	validOriginSignature := true // true if the received file is verified to be originated from supposed origin
 
-	uploaderKey, present := g.keyRing.GetKey(filereq.Destination)
-	if !present {
-		verifiedUploader = false
-	}
+	// keys of the uploader and of the origin, looked up in the network once a signature of theirs is to be verified,
+	// until the end of the download
+	var uploaderKey crypto.PublicKey
+	var uploaderLookup, originLookup *keyLookup
+	defer func() {
+		uploaderLookup.stop()
+		originLookup.stop()
+	}()
 
 	// assuming we have the metahash
 	metadata := g.metadataSet.Get(filereq.MetaHash)
@@ -209,6 +213,16 @@ func startDownload(g *Gossiper, filereq *common.FileRequest) {
 					metadata.Metafile = make([]byte, len(metafile))
 					copy(metadata.Metafile, metafile)
 
+					if metareply.SigOrigin != nil && metareply.SigUploader != nil && metareply.SigMetaUploader != nil {
+						// the signatures of the uploader can only be verified with its key
+						var err error
+						uploaderLookup = g.startKeyLookup(filereq.Destination)
+						uploaderKey, err = uploaderLookup.wait()
+						if err != nil {
+							verifiedUploader = false
+						}
+					}
+
 					if verifiedUploader && metareply.SigOrigin != nil && metareply.SigUploader != nil && metareply.SigMetaUploader != nil {
 
 						sigoriginI := *metareply.SigOrigin
@@ -232,7 +246,7 @@ func startDownload(g *Gossiper, filereq *common.FileRequest) {
 						metachashed := newhash.Sum(nil)
 
 						// verify against SigMetaUploader
-						err := rsa.VerifyPSS(&uploaderKey, crypto.SHA256, metachashed, SigMetaUploader, nil)
+						err := awot.VerifySignature(uploaderKey, metachashed, SigMetaUploader)
 						if err != nil {
 							common.Log(FileWrongSigMetaUploader(filereq.Destination), common.LOG_MODE_REACTIVE)
 							verifiedUploader = false
@@ -264,6 +278,11 @@ func startDownload(g *Gossiper, filereq *common.FileRequest) {
 		}
 	}
 
+	if metadata.SigOrigin != nil && validOriginSignature && filereq.Origin != nil {
+		// the signature of the origin is verified at the end of the download, its key is looked up meanwhile
+		originLookup = g.startKeyLookup(*filereq.Origin)
+	}
+
 	chunkNumber := GetNumberOfChunks(metadata.Metafile, g.Parameters.HashLength)
 
 	download := FileDownload{
@@ -414,8 +433,12 @@ func startDownload(g *Gossiper, filereq *common.FileRequest) {
 	metahash := download.FileMetadata.Metahash
 
 	// check sigUploader
+	if sigUploader == nil {
+		// no signature of the uploader received
+		verifiedUploader = false
+	}
 	if verifiedUploader {
-		err := rsa.VerifyPSS(&uploaderKey, crypto.SHA256, metahash, *sigUploader, nil)
+		err := awot.VerifySignature(uploaderKey, metahash, *sigUploader)
 		if err != nil {
 			common.Log(FileWrongSigUploader(filereq.Destination), common.LOG_MODE_REACTIVE)
 			verifiedUploader = false
@@ -425,14 +448,14 @@ func startDownload(g *Gossiper, filereq *common.FileRequest) {
 	}
 	// check sigOrigin
 	if sigOrigin != nil && validOriginSignature && filereq.Origin != nil {
-		originKey, present := g.keyRing.GetKey(*filereq.Origin)
+		originKey, err := originLookup.wait()
 
-		if !present {
+		if err != nil {
 			// do not have the public key : impossible to verify
 			validOriginSignature = false
 			knownOrigin = false
 		} else {
-			err := rsa.VerifyPSS(&originKey, crypto.SHA256, metahash, *sigOrigin, nil)
+			err = awot.VerifySignature(originKey, metahash, *sigOrigin)
 			if err != nil {
 				common.Log(FileWrongSigOrigin(*filereq.Origin), common.LOG_MODE_REACTIVE)
 				validOriginSignature = false
@@ -3,11 +3,10 @@
 package main
 
 import (
-	"crypto/rand"
+	"crypto"
+	"crypto/ed25519"
 	"crypto/rsa"
-	"crypto/x509"
 	"encoding/gob"
-	"encoding/pem"
 	"errors"
 	"io/ioutil"
 	"os"
@@ -20,36 +19,51 @@ import (
 
 var KEY_SIZE = 4096
 
-// Return the private key, either stored in disk with given filename, or a new one and write it to the disk under the given filename
-func getKey(pubKeyFilename, filename string) rsa.PrivateKey {
+// Return the private key, either stored in disk with given filename, or a new one of given algorithm and write it to the disk under the given filename
+// The key is encrypted on disk with the given passphrase, if any.
+func getKey(pubKeyFilename, filename string, algorithm awot.KeyAlgorithm, passphrase string) crypto.Signer {
 
 	// check if file exists
 	if _, err := os.Stat(filename); err == nil {
 		// key exists in disk
 
 		// decode
-		var key = new(rsa.PrivateKey)
-		err = loadGob(filename, key)
+		key, err := loadPrivateKey(filename, passphrase)
 		common.CheckError(err)
 
-		err = savePublicKey(key.PublicKey, pubKeyFilename)
+		err = savePublicKey(key.Public(), pubKeyFilename)
 		common.CheckError(err)
 
-		return *key
+		return key
 	}
 
 	// create the key
-	key, err := rsa.GenerateKey(rand.Reader, KEY_SIZE)
+	key, err := awot.GenerateKey(algorithm, KEY_SIZE)
 	common.CheckError(err)
 
 	// save to disk
-	err = saveGob(filename, key)
+	err = savePrivateKey(key, filename, passphrase)
 	common.CheckError(err)
 
-	err = savePublicKey(key.PublicKey, pubKeyFilename)
+	err = savePublicKey(key.Public(), pubKeyFilename)
 	common.CheckError(err)
 
-	return *key
+	return key
+}
+
+// Decode a private key stored via Gob by older versions, either RSA or Ed25519
+func loadGobPrivateKey(filename string) (crypto.Signer, error) {
+	var rsaKey = new(rsa.PrivateKey)
+	err := loadGob(filename, rsaKey)
+	if err == nil {
+		return rsaKey, nil
+	}
+
+	var ed25519Key ed25519.PrivateKey
+	if loadGob(filename, &ed25519Key) == nil && len(ed25519Key) == ed25519.PrivateKeySize {
+		return ed25519Key, nil
+	}
+	return nil, err
 }
 
 // Construct a list of keyrecords from a directory where public keys are stored
@@ -77,31 +91,24 @@ func getPublicKeysFromDirectory(dir string, except string) []awot.TrustedKeyReco
 			pubBytes, err := ioutil.ReadFile(dir + f.Name())
 			common.CheckError(err)
 
-			pemBlock, _ := pem.Decode(pubBytes)
-
-			if pemBlock != nil {
-				keypub, err := x509.ParsePKIXPublicKey(pemBlock.Bytes)
-				common.CheckError(err)
-
-				original, ok := keypub.(*rsa.PublicKey)
+			keypub, err := awot.DeserializeKey(pubBytes)
 
-				if ok {
-					// good format
+			if err == nil {
+				// good format : rsa or ed25519
 
-					// construct record using name of file as owner
-					record := awot.KeyRecord{
-						Owner:  name,
-						KeyPub: *original,
-					}
-
-					trec := awot.TrustedKeyRecord{
-						KeyRecord:  record,
-						Confidence: float32(1.0),
-					}
+				// construct record using name of file as owner
+				record := awot.KeyRecord{
+					Owner:  name,
+					KeyPub: keypub,
+				}
 
-					// add record
-					records = append(records, trec)
+				trec := awot.TrustedKeyRecord{
+					KeyRecord:  record,
+					Confidence: float32(1.0),
 				}
+
+				// add record
+				records = append(records, trec)
 			}
 		}
 	}
@@ -110,21 +117,16 @@ func getPublicKeysFromDirectory(dir string, except string) []awot.TrustedKeyReco
 }
 
 // Encode public key to pem and save it to file
-func savePublicKey(keypub rsa.PublicKey, filename string) error {
+func savePublicKey(keypub crypto.PublicKey, filename string) error {
 
 	// check if public key exists
 	if _, err := os.Stat(filename); err != nil {
 
-		PubASN1, err := x509.MarshalPKIXPublicKey(&keypub)
+		pubBytes, err := awot.SerializeKey(keypub)
 		if err != nil {
 			return errors.New("could not marshal public key")
 		}
 
-		pubBytes := pem.EncodeToMemory(&pem.Block{
-			Type:  "RSA PUBLIC KEY",
-			Bytes: PubASN1,
-		})
-
 		ioutil.WriteFile(filename, pubBytes, 0644)
 	}
 
@@ -152,3 +154,60 @@ func loadGob(path string, object interface{}) error {
 	file.Close()
 	return err
 }
+
+// Rotate the key of the gossiper : generate a new key, save it to disk in place of the old one (kept with extension .old),
+// and advertise the transition signed by both keys to the other peers
+func (g *Gossiper) RotateKey() error {
+	oldKey := g.privateKey()
+
+	newKey, err := awot.GenerateKey(g.Parameters.KeyAlgorithm, KEY_SIZE)
+	if err != nil {
+		return err
+	}
+
+	msg, err := awot.CreateTransition(oldKey, newKey, g.Parameters.Identifier)
+	if err != nil {
+		return err
+	}
+
+	// save to disk
+	err = savePrivateKey(oldKey, g.Parameters.KeyFileName+".old", g.Parameters.KeyPassphrase)
+	if err != nil {
+		return err
+	}
+	err = savePrivateKey(newKey, g.Parameters.KeyFileName, g.Parameters.KeyPassphrase)
+	if err != nil {
+		return err
+	}
+	os.Remove(g.Parameters.PubKeyFileName)
+	err = savePublicKey(newKey.Public(), g.Parameters.PubKeyFileName)
+	if err != nil {
+		return err
+	}
+
+	g.setPrivateKey(newKey)
+	err = g.keyRing.AddTransition(msg)
+	if err != nil {
+		return err
+	}
+
+	sendTransition(g, msg)
+
+	// sign again the fully trusted keys with the new key
+	g.SendSignatures()
+	return nil
+}
+
+// Returns the current private key of the gossiper, which may be replaced by a rotation at any time
+func (g *Gossiper) privateKey() crypto.Signer {
+	g.keyMutex.Lock()
+	defer g.keyMutex.Unlock()
+	return g.key
+}
+
+// Replaces the private key of the gossiper
+func (g *Gossiper) setPrivateKey(key crypto.Signer) {
+	g.keyMutex.Lock()
+	g.key = key
+	g.keyMutex.Unlock()
+}
@@ -2,29 +2,45 @@ package main
 
 import (
 	"net"
+
+	"github.com/No-Trust/peerster/awot"
+	"github.com/No-Trust/peerster/rep"
 )
 
 // Parameters of a Gossiper
 type Parameters struct {
-	Identifier             string      // identifier of this node
-	Name                   string      // name of this node
-	Etimer                 uint        // rate of anti entropy
-	Rtimer                 uint        // rate of route rumors
-	Reptimer               uint        // rate of reputation update requests
-	Hoplimit               uint32      // TTL for the sending of private messages
-	NoForward              bool        // for testing : if set, does not forward any packet except route rumors
-	NatTraversal           bool        // if set, activates the nat traversal option
-	GossipAddr             net.UDPAddr // ip:port of the gossip connection
-	GossipConn             net.UDPConn // gossip connection
-	UIAddr                 net.UDPAddr // ip:port of the client connection
-	UIConn                 net.UDPConn // client connection
-	ChannelSize            int         // buffered channel size (higher => better performance, less memory efficient)
-	ChunkSize              uint        // size of a chunk, in byte
-	FilesDirectory         string      // path to store the files
-	ChunksDirectory        string      // path to store the chunks
-	HashLength             uint        // length of the hashes in bits
-	KeyFileName            string      // filename of stored key
-	PubKeyFileName         string      // filename of stored public key
-	TrustedKeysDirectory   string      // directory for the fully trusted public keys
-	KeyConfidenceThreshold float32     // threshold for trusted keys
+	Identifier             string               // identifier of this node
+	Name                   string               // name of this node
+	Etimer                 uint                 // rate of anti entropy
+	Rtimer                 uint                 // rate of route rumors
+	Reptimer               uint                 // rate of reputation update requests
+	RepConfig              rep.Config           // parameters of the reputation system
+	UploadSlots            uint                 // number of peers served with data at the same time, 0 for no limit
+	Policy                 PolicyConfig         // reputation thresholds of the enforcement actions
+	Hoplimit               uint32               // TTL for the sending of private messages
+	NoForward              bool                 // for testing : if set, does not forward any packet except route rumors
+	NatTraversal           bool                 // if set, activates the nat traversal option
+	GossipAddr             net.UDPAddr          // ip:port of the gossip connection
+	GossipConn             net.UDPConn          // gossip connection
+	UIAddr                 net.UDPAddr          // ip:port of the client connection
+	UIConn                 net.UDPConn          // client connection
+	ChannelSize            int                  // buffered channel size (higher => better performance, less memory efficient)
+	ChunkSize              uint                 // size of a chunk, in byte
+	FilesDirectory         string               // path to store the files
+	ChunksDirectory        string               // path to store the chunks
+	HashLength             uint                 // length of the hashes in bits
+	KeyFileName            string               // filename of stored key
+	KeyAlgorithm           awot.KeyAlgorithm    // algorithm of the generated keys
+	KeyPassphrase          string               // passphrase encrypting the stored key, empty for no encryption
+	PubKeyFileName         string               // filename of stored public key
+	TrustedKeysDirectory   string               // directory for the fully trusted public keys
+	TrustFileName          string               // filename of stored manual trust decisions
+	KeyConfidenceThreshold float32              // threshold for trusted keys
+	KeyValidity            uint                 // validity of the key signatures, in seconds
+	Ktimer                 uint                 // rate of re-advertisement of the key signatures
+	KeyLookupTimeout       uint                 // time waited for the key of a peer looked up in the network, in seconds
+	KeyCollisionPolicy     awot.CollisionPolicy // policy for competing keys of a peer
+	KeyTrustMetric         awot.TrustMetric     // metric for the confidence of the keys
+	SybilThreshold         float32              // suspicion from which a peer is considered as a Sybil identity
+	SybilCap               float32              // maximum trust put in suspected Sybil identities
 }
@@ -4,7 +4,7 @@
 package main
 
 import (
-	"crypto/rsa"
+	"crypto"
 	"fmt"
 	"net"
 	"sync"
@@ -30,13 +30,16 @@ type Gossiper struct {
 	fileWaiters       map[string]chan *DataReply // goroutines waiting for a data reply
 	fileWaitersMutex  *sync.Mutex
 	// 	standardOutputQueue chan *string            // output queue for the standard output
-	routingTable    RoutingTable            // routing table
-	metadataSet     MetadataSet             // file metadatas
-	FileDownloads   FileDownloads           // file downloads : file that are being downloaded
-	key             rsa.PrivateKey          // private key / public key of this gossiper
-	reputationTable rep.ReputationTable     // Reputation table
-	trustedKeys     []awot.TrustedKeyRecord // fully trusted keys, bootstrap of awot
-	keyRing         awot.KeyRing            // key ring of awot
+	routingTable    RoutingTable         // routing table
+	metadataSet     MetadataSet          // file metadatas
+	FileDownloads   FileDownloads        // file downloads : file that are being downloaded
+	key             crypto.Signer        // private key / public key of this gossiper, see privateKey
+	keyMutex        *sync.Mutex          // the key is replaced by a rotation
+	reputationTable *rep.ReputationTable // Reputation table
+	keyRing         awot.KeyRing         // key ring of awot
+	keyLookups      *KeyLookups          // key requests sent and processed
+	uploadSlots     *UploadSlots         // peers currently served with data
+	policy          *ReputationPolicy    // enforcement actions against the peers with a low reputation
 }
 
 // Create a new Gossiper
@@ -45,9 +48,9 @@ func NewGossiper(parameters Parameters, peerAddrs []net.UDPAddr) *Gossiper {
 	peerSet := common.NewSetFromAddrs(peerAddrs, parameters.GossipAddr)
 	channelSize := parameters.ChannelSize
 	metadataSet := NewMetadataSet()
-	key := getKey(parameters.PubKeyFileName, parameters.KeyFileName)
+	key := getKey(parameters.PubKeyFileName, parameters.KeyFileName, parameters.KeyAlgorithm, parameters.KeyPassphrase)
 	trustedKeys := getPublicKeysFromDirectory(parameters.TrustedKeysDirectory, parameters.Identifier)
-	reptable := *rep.NewReputationTable(&peerSet)
+	reptable := rep.NewReputationTable(&peerSet, parameters.RepConfig)
 	gossiper := Gossiper{
 		Parameters:        parameters,
 		gossipOutputQueue: make(chan *Packet, channelSize),
@@ -65,11 +68,24 @@ func NewGossiper(parameters Parameters, peerAddrs []net.UDPAddr) *Gossiper {
 		metadataSet:     metadataSet,
 		FileDownloads:   *NewFileDownloads(),
 		key:             key,
+		keyMutex:        &sync.Mutex{},
 		reputationTable: reptable,
-		trustedKeys:     trustedKeys,
-		keyRing:         awot.NewKeyRing(parameters.Identifier, key.PublicKey, trustedKeys, parameters.KeyConfidenceThreshold),
+		keyRing:         awot.NewKeyRing(parameters.Identifier, key.Public(), trustedKeys, parameters.KeyConfidenceThreshold),
+		keyLookups:      NewKeyLookups(),
+		uploadSlots:     NewUploadSlots(parameters.UploadSlots),
+		policy:          NewReputationPolicy(parameters.Policy),
 	}
-	gossiper.keyRing.StartWithReputation(time.Duration(5)*time.Second, &reptable)
+	gossiper.loadTrustDecisions()
+	gossiper.keyRing.SetCollisionPolicy(parameters.KeyCollisionPolicy)
+	sybilConfig := awot.DefaultSybilConfig()
+	sybilConfig.Threshold = parameters.SybilThreshold
+	sybilConfig.Cap = parameters.SybilCap
+	gossiper.keyRing.SetSybilConfig(sybilConfig)
+	if parameters.KeyTrustMetric != nil {
+		gossiper.keyRing.SetTrustMetric(parameters.KeyTrustMetric)
+	}
+	gossiper.keyRing.SetCollisionHandler(gossiper.handleKeyCollision)
+	gossiper.keyRing.StartWithReputation(time.Duration(5)*time.Second, reptable)
 	return &gossiper
 }
 
@@ -77,7 +93,7 @@ func NewGossiper(parameters Parameters, peerAddrs []net.UDPAddr) *Gossiper {
 func (g *Gossiper) Start() {
 
 	var wg sync.WaitGroup
-	wg.Add(8)
+	wg.Add(9)
 
 	// Client Listener Thread
 	go func() {
@@ -118,6 +134,12 @@ func (g *Gossiper) Start() {
 		repUpdateRequests(g, g.Parameters.Reptimer)
 	}()
 
+	// Key Signatures Re-advertisement Thread
+	go func() {
+		defer wg.Done()
+		keyReadvertisement(g, g.Parameters.Ktimer)
+	}()
+
 	// Reputation Logs Thread
 	/*go func() {
 		defer wg.Done()
@@ -153,10 +175,17 @@ func handleGossiperMessage(buf []byte, remoteaddr *net.UDPAddr, g *Gossiper) {
 		Identifier: "",
 	}
 
+	// drop the packets of the quarantined peers, and the excess packets of the throttled ones
+	if !g.allowPacket(remoteaddr) {
+		return
+	}
+
 	g.peerSet.Add(A) // adding A to the known peers
 
-	// Initialize A's contrib-based reputation if necessary
+	// Initialize A's contrib-based reputation if necessary,
+	// and account the bytes received from A
 	g.reputationTable.InitContribRepForPeer(addrToString(A.Address))
+	g.reputationTable.RecordDownload(addrToString(A.Address), pkt.trafficClass(), len(buf))
 
 	// demultiplex packets
 	if pkt.Rumor != nil {
@@ -187,6 +216,22 @@ func handleGossiperMessage(buf []byte, remoteaddr *net.UDPAddr, g *Gossiper) {
 		// process contrib-based reputation update
 		go g.processContribRepUpdate(pkt.RepUpdate, &A)
 	}
+	if pkt.KeyRequest != nil {
+		// process key request
+		go g.processKeyRequest(pkt.KeyRequest, remoteaddr)
+	}
+	if pkt.KeyReply != nil {
+		// process key reply
+		go g.processKeyReply(pkt.KeyReply, remoteaddr)
+	}
+	if pkt.IdentityChallenge != nil {
+		// process identity challenge
+		go g.processIdentityChallenge(pkt.IdentityChallenge, remoteaddr)
+	}
+	if pkt.IdentityProof != nil {
+		// process identity proof
+		go g.processIdentityProof(pkt.IdentityProof, remoteaddr)
+	}
 
 	return
 }
@@ -228,4 +273,36 @@ func handleClientMessage(buf []byte, remoteaddr *net.UDPAddr, g *Gossiper) {
 		// process file request
 		processFileRequest(pkt.FileRequest, g)
 	}
+	if pkt.TrustUpdate != nil {
+		// process manual trust decision
+		processTrustUpdate(pkt.TrustUpdate, g)
+	}
+	if pkt.RevokeKey != nil {
+		// process key revocation
+		processRevokeKey(pkt.RevokeKey, g)
+	}
+	if pkt.RotateKey != nil {
+		// process key rotation, generating a key takes time
+		go processRotateKey(pkt.RotateKey, g)
+	}
+	if pkt.ExportKeyRing != nil {
+		// process key ring export, signing the bootstrap keys takes time
+		go processExportKeyRing(pkt.ExportKeyRing, g)
+	}
+	if pkt.ImportKeyRing != nil {
+		// process key ring import
+		processImportKeyRing(pkt.ImportKeyRing, g)
+	}
+	if pkt.VerifyKey != nil {
+		// process out of band key verification
+		processVerifyKey(pkt.VerifyKey, g)
+	}
+	if pkt.ExplainKey != nil {
+		// process explanation of a key confidence
+		processExplainKey(pkt.ExplainKey, g)
+	}
+	if pkt.RepHistory != nil {
+		// process reputation history request
+		processRepHistory(pkt.RepHistory, g)
+	}
 }
@@ -0,0 +1,45 @@
+// Export and import of the key ring as key bundles
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"time"
+
+	"github.com/No-Trust/peerster/awot"
+)
+
+// Write the key ring to the file at given path, in given format : "jwks" or "pem"
+func (g *Gossiper) ExportKeyRing(path, format string) error {
+	validity := time.Second * time.Duration(g.Parameters.KeyValidity)
+	bundle := g.keyRing.Export(g.privateKey(), validity)
+
+	var data []byte
+	var err error
+	switch format {
+	case "jwks", "":
+		data, err = bundle.MarshalJWKS()
+	case "pem":
+		data, err = bundle.MarshalPEM()
+	default:
+		return errors.New("unknown key ring format " + format)
+	}
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Add the verified signatures of the key bundle stored at given path to the key ring
+func (g *Gossiper) ImportKeyRing(path string) (awot.ImportResult, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return awot.ImportResult{}, err
+	}
+	bundle, err := awot.ParseKeyBundle(data)
+	if err != nil {
+		return awot.ImportResult{}, err
+	}
+	// the reputation of the owners is not known yet, it is accounted for at the next update of the ring
+	return g.keyRing.Import(bundle, 1.0), nil
+}
@@ -2,7 +2,9 @@
 package main
 
 import (
+	"errors"
 	"net"
+	"time"
 
 	"github.com/No-Trust/peerster/awot"
 	"github.com/No-Trust/peerster/common"
@@ -10,6 +12,11 @@ import (
 
 // Procedure for inbound KeyExchangeMessage
 func (g *Gossiper) processKeyExchangeMessage(msg *awot.KeyExchangeMessage, repOwner float32, remoteaddr *net.UDPAddr) {
+	// ignore the signatures of the peers with a too low reputation
+	if !g.allowKeyExchange(msg.Origin) {
+		return
+	}
+
 	nsig := make([]byte, len(msg.Signature))
 	copy(nsig, msg.Signature)
 	msg.Signature = nsig
@@ -63,15 +70,16 @@ func (g *Gossiper) processKeyExchangeMessage(msg *awot.KeyExchangeMessage, repOw
 	// Increase sender's reputation
 	// g.reputationTable.IncreaseSigRep(/* OOPS! WE NEED SENDER'S IDENTIFIER */, record.Confidence)
 
-	// update key ring
-	g.keyRing.Add(record, msg.Origin, repOwner)
+	// update key ring, the signature is kept until the message expires
+	g.keyRing.AddMessage(*msg, repOwner)
 
 	return
 }
 
 // Send a fresh key record to a random neighbor as a rumor message
 func sendCertificate(g *Gossiper, rec awot.TrustedKeyRecord) {
-	msg := rec.ConstructMessage(g.key, g.Parameters.Identifier)
+	validity := time.Second * time.Duration(g.Parameters.KeyValidity)
+	msg := rec.ConstructMessage(g.privateKey(), g.Parameters.Identifier, validity)
 	common.Log(KeyExchangeSignString(msg.Owner, msg.Signature), common.LOG_MODE_REACTIVE)
 
 	nextSeq := g.vectorClock.Get(g.Parameters.Identifier)
@@ -91,17 +99,191 @@ func sendCertificate(g *Gossiper, rec awot.TrustedKeyRecord) {
 	g.messages.Add(&rumor)
 
 	// and send the rumor
-	destPeer := g.peerSet.RandomPeer()
+	destPeer := g.randomPeer(g.peerSet)
 	if destPeer != nil {
 		common.Log(KeyExchangeSendString(msg.Owner, destPeer.Address), common.LOG_MODE_FULL)
 		go g.rumormonger(&rumor, destPeer)
 	}
 }
 
-// Sends the self signed signature to other peers
+// Sends the signatures of the fully trusted keys to other peers : the bootstrap keys,
+// and the keys fully trusted at runtime (imported or confirmed by the user)
 func (g *Gossiper) SendSignatures() {
-	for _, rec := range g.trustedKeys {
+	for _, rec := range g.keyRing.FullyTrustedKeys() {
+		if rec.Owner == g.Parameters.Identifier {
+			continue
+		}
 		// send to a random neighbor
 		sendCertificate(g, rec)
 	}
 }
+
+// Signs again and re-advertises the fully trusted keys every ktimer seconds,
+// so that the other peers receive fresh signatures before the previous ones expire
+func keyReadvertisement(g *Gossiper, ktimer uint) {
+	ticker := time.NewTicker(time.Second * time.Duration(ktimer))
+	defer ticker.Stop()
+
+	for range ticker.C {
+		g.SendSignatures()
+	}
+}
+
+// Procedure for inbound KeyRevocationMessage
+func (g *Gossiper) processKeyRevocationMessage(msg *awot.KeyRevocationMessage, remoteaddr *net.UDPAddr) {
+	nsig := make([]byte, len(msg.Signature))
+	copy(nsig, msg.Signature)
+	msg.Signature = nsig
+	nkeybytes := make([]byte, len(msg.KeyBytes))
+	copy(nkeybytes, msg.KeyBytes)
+	msg.KeyBytes = nkeybytes
+
+	// the key ring verifies the signature of the revocation
+	err := g.keyRing.AddRevocation(*msg)
+	common.Log(KeyRevocationReceiveString(msg.Owner, msg.Origin, *remoteaddr, err),
+		common.LOG_MODE_REACTIVE)
+}
+
+// Send a key revocation to a random neighbor as a rumor message
+func sendRevocation(g *Gossiper, msg awot.KeyRevocationMessage) {
+	common.Log(KeyRevocationSignString(msg.Owner, msg.Signature), common.LOG_MODE_REACTIVE)
+
+	nextSeq := g.vectorClock.Get(g.Parameters.Identifier)
+
+	// create rumor from message
+	rumor := RumorMessage{
+		Origin:        g.Parameters.Identifier,
+		ID:            nextSeq,
+		Text:          "",
+		KeyRevocation: &msg,
+	}
+
+	// update status vector
+	g.vectorClock.Update(g.Parameters.Identifier)
+
+	// update messages
+	g.messages.Add(&rumor)
+
+	// and send the rumor
+	destPeer := g.randomPeer(g.peerSet)
+	if destPeer != nil {
+		go g.rumormonger(&rumor, destPeer)
+	}
+}
+
+// Revokes the key of the peer with given name : withdraws the signature of this gossiper on its key,
+// or revokes the own key of this gossiper if the name is its own
+func (g *Gossiper) RevokeKey(owner string) error {
+	var msg awot.KeyRevocationMessage
+	var err error
+
+	if owner == g.Parameters.Identifier {
+		// self-signed revocation of own key
+		key := g.privateKey()
+		msg, err = awot.CreateRevocation(key.Public(), owner, key, g.Parameters.Identifier)
+		if err != nil {
+			return err
+		}
+	} else {
+		// withdrawal of own signature
+		rec, present := g.keyRing.GetRecord(owner)
+		if !present {
+			return errors.New("no key to revoke for " + owner)
+		}
+		msg, err = awot.CreateRevocation(rec.KeyPub, owner, g.privateKey(), g.Parameters.Identifier)
+		if err != nil {
+			return err
+		}
+		err = g.keyRing.AddRevocation(msg)
+		if err != nil {
+			return err
+		}
+	}
+
+	sendRevocation(g, msg)
+	return nil
+}
+
+// Procedure for inbound KeyTransitionMessage
+func (g *Gossiper) processKeyTransitionMessage(msg *awot.KeyTransitionMessage, remoteaddr *net.UDPAddr) {
+	noldkeybytes := make([]byte, len(msg.OldKeyBytes))
+	copy(noldkeybytes, msg.OldKeyBytes)
+	msg.OldKeyBytes = noldkeybytes
+	nnewkeybytes := make([]byte, len(msg.NewKeyBytes))
+	copy(nnewkeybytes, msg.NewKeyBytes)
+	msg.NewKeyBytes = nnewkeybytes
+	noldsig := make([]byte, len(msg.OldSignature))
+	copy(noldsig, msg.OldSignature)
+	msg.OldSignature = noldsig
+	nnewsig := make([]byte, len(msg.NewSignature))
+	copy(nnewsig, msg.NewSignature)
+	msg.NewSignature = nnewsig
+
+	// the key ring verifies both signatures of the transition
+	err := g.keyRing.AddTransition(*msg)
+	common.Log(KeyTransitionReceiveString(msg.Owner, *remoteaddr, err), common.LOG_MODE_REACTIVE)
+}
+
+// Send a key transition to a random neighbor as a rumor message
+func sendTransition(g *Gossiper, msg awot.KeyTransitionMessage) {
+	nextSeq := g.vectorClock.Get(g.Parameters.Identifier)
+
+	// create rumor from message
+	rumor := RumorMessage{
+		Origin:        g.Parameters.Identifier,
+		ID:            nextSeq,
+		Text:          "",
+		KeyTransition: &msg,
+	}
+
+	// update status vector
+	g.vectorClock.Update(g.Parameters.Identifier)
+
+	// update messages
+	g.messages.Add(&rumor)
+
+	// and send the rumor
+	destPeer := g.randomPeer(g.peerSet)
+	if destPeer != nil {
+		go g.rumormonger(&rumor, destPeer)
+	}
+}
+
+// Handler for the collisions of keys detected by the key ring : notifies the client, and decreases the reputation of the signers of the losing keys,
+// once per losing key and only if the selected key is trusted by this gossiper or more confident, not only signed on more paths
+func (g *Gossiper) handleKeyCollision(collision awot.KeyCollision) {
+	fingerprints := make([]string, len(collision.Candidates))
+	for i, candidate := range collision.Candidates {
+		fingerprints[i] = candidate.Fingerprint
+	}
+	selected := ""
+	if collision.Selected >= 0 {
+		selected = fingerprints[collision.Selected]
+	}
+
+	// send notification to client
+	notification := common.KeyCollisionNotification(collision.Owner, fingerprints, selected)
+	if g.ClientAddress != nil {
+		g.clientOutputQueue <- &common.Packet{
+			ClientPacket: common.ClientPacket{
+				Notification: notification,
+			},
+			Destination: *g.ClientAddress,
+		}
+	}
+	// print same notification
+	common.Log(*notification, common.LOG_MODE_REACTIVE)
+
+	if len(collision.Suspicious) == 0 {
+		// no decisive selection, or losers already reported : the honest signers are unknown
+		return
+	}
+
+	// the signers of the losing keys are suspicious, the more confident the selected key the more suspicious
+	confidence := collision.Candidates[collision.Selected].Confidence
+	for _, signer := range collision.SuspiciousSigners() {
+		if signer != g.Parameters.Identifier {
+			g.reputationTable.DecreaseSigRep(signer, confidence)
+		}
+	}
+}
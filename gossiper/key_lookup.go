@@ -0,0 +1,318 @@
+// Key lookup : asking the network for the signatures of the key of a peer this gossiper has no trusted key for
+package main
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/No-Trust/peerster/awot"
+	"github.com/No-Trust/peerster/common"
+	"github.com/No-Trust/peerster/rep"
+)
+
+// Number of hops a key request travels from its origin
+const KEY_REQUEST_HOP_LIMIT = 4
+
+// Maximum number of signatures in a key reply
+const KEY_REPLY_MAX_SIGNATURES = 16
+
+// Time during which a processed key request is remembered, so that it is processed only once
+const KEY_REQUEST_MEMORY = time.Minute
+
+// Time between two requests for the same key, while it is still unknown
+const KEY_REQUEST_RETRY = 5 * time.Second
+
+// KeyLookups holds the state of the key requests : the next ID of the requests of this gossiper, and the requests already processed
+type KeyLookups struct {
+	nextID uint32
+	seen   map[string]time.Time // origin:id -> time of reception
+	mutex  *sync.Mutex
+}
+
+// A keyLookup is the lookup of the key of a peer running in the background
+type keyLookup struct {
+	owner  string
+	done   chan struct{}
+	cancel chan struct{}
+	once   *sync.Once
+	key    crypto.PublicKey
+	err    error
+}
+
+// Create an empty KeyLookups
+func NewKeyLookups() *KeyLookups {
+	return &KeyLookups{
+		nextID: 1,
+		seen:   make(map[string]time.Time),
+		mutex:  &sync.Mutex{},
+	}
+}
+
+// Returns the ID of a new request of this gossiper
+func (kl *KeyLookups) newID() uint32 {
+	kl.mutex.Lock()
+	defer kl.mutex.Unlock()
+	id := kl.nextID
+	kl.nextID++
+	return id
+}
+
+// Records the request with given origin and ID, returns false if it was already seen
+func (kl *KeyLookups) see(origin string, id uint32) bool {
+	kl.mutex.Lock()
+	defer kl.mutex.Unlock()
+
+	now := time.Now()
+	for key, received := range kl.seen {
+		if now.Sub(received) > KEY_REQUEST_MEMORY {
+			delete(kl.seen, key)
+		}
+	}
+
+	key := origin + ":" + strconv.FormatUint(uint64(id), 10)
+	if _, present := kl.seen[key]; present {
+		return false
+	}
+	kl.seen[key] = now
+	return true
+}
+
+// Send a request for the signatures of the key of given owner to every neighbor
+func (g *Gossiper) requestKey(owner string) {
+	req := KeyRequest{
+		Origin:   g.Parameters.Identifier,
+		ID:       g.keyLookups.newID(),
+		Owner:    owner,
+		HopLimit: KEY_REQUEST_HOP_LIMIT,
+	}
+	// do not process the own request again if a neighbor sends it back
+	g.keyLookups.see(req.Origin, req.ID)
+
+	for _, peer := range g.peerSet.ToPeerArray() {
+		common.Log(KeyRequestSendString(owner, peer.Address), common.LOG_MODE_FULL)
+		g.gossipOutputQueue <- &Packet{
+			GossipPacket: GossipPacket{
+				KeyRequest: &req,
+			},
+			Destination: peer.Address,
+		}
+	}
+}
+
+// Handler for inbound key request : replies with the known signatures of the key, and forwards the request
+func (g *Gossiper) processKeyRequest(req *KeyRequest, remoteaddr *net.UDPAddr) {
+	if !g.keyLookups.see(req.Origin, req.ID) {
+		// already processed
+		return
+	}
+	common.Log(KeyRequestReceiveString(req.Owner, req.Origin, *remoteaddr), common.LOG_MODE_FULL)
+
+	signatures := g.keySignatures(req.Owner)
+	if len(signatures) > 0 {
+		// reply through the neighbor the request came from, which has a route to its origin
+		reply := KeyReply{
+			Origin:      g.Parameters.Identifier,
+			Destination: req.Origin,
+			HopLimit:    g.Parameters.Hoplimit,
+			Owner:       req.Owner,
+			Signatures:  signatures,
+		}
+		g.gossipOutputQueue <- &Packet{
+			GossipPacket: GossipPacket{
+				KeyReply: &reply,
+			},
+			Destination: *remoteaddr,
+		}
+	}
+
+	// forward the request to the other neighbors
+	if g.Parameters.NoForward {
+		return
+	}
+
+	// decrement TTL, drop if less than 0
+	req.HopLimit -= 1
+	if req.HopLimit <= 0 {
+		return
+	}
+
+	for _, peer := range g.peerSet.ToPeerArray() {
+		if addrToString(peer.Address) == addrToString(*remoteaddr) {
+			continue
+		}
+		g.gossipOutputQueue <- &Packet{
+			GossipPacket: GossipPacket{
+				KeyRequest: req,
+			},
+			Destination: peer.Address,
+		}
+	}
+}
+
+// Handler for inbound key reply : the signatures go through the normal verification of the key exchange messages
+func (g *Gossiper) processKeyReply(reply *KeyReply, remoteaddr *net.UDPAddr) {
+	// check if this peer is the destination
+
+	if reply.Destination == g.Parameters.Identifier {
+		common.Log(KeyReplyReceiveString(reply.Owner, reply.Origin, len(reply.Signatures)), common.LOG_MODE_REACTIVE)
+
+		for i := range reply.Signatures {
+			msg := reply.Signatures[i]
+			repOwner, present := g.reputationTable.Score(rep.PURPOSE_KEY_SIGNING, msg.Owner)
+			if !present {
+				repOwner = 0.5
+			}
+			g.processKeyExchangeMessage(&msg, repOwner, remoteaddr)
+		}
+		return
+	}
+
+	// this is not the destination
+	// forward the packet
+	if g.Parameters.NoForward {
+		return
+	}
+
+	// decrement TTL, drop if less than 0
+	reply.HopLimit -= 1
+	if reply.HopLimit <= 0 {
+		return
+	}
+
+	// get nexthop
+	nexthop := g.routingTable.Get(reply.Destination)
+	if nexthop != "" {
+		// only forward if we have a route
+		nextHopAddress := stringToUDPAddr(nexthop)
+
+		g.gossipOutputQueue <- &Packet{
+			GossipPacket: GossipPacket{
+				KeyReply: reply,
+			},
+			Destination: nextHopAddress,
+		}
+	}
+}
+
+// Returns the signatures this gossiper knows of the key of given owner, preceded by the signatures of their signers
+// so that a requester that does not know the signers yet can still verify them
+func (g *Gossiper) keySignatures(owner string) []awot.KeyExchangeMessage {
+	validity := time.Second * time.Duration(g.Parameters.KeyValidity)
+	key := g.privateKey()
+	ownerSignatures := g.keyRing.Signatures(owner, key, validity)
+
+	signatures := make([]awot.KeyExchangeMessage, 0)
+	added := make(map[string]bool)
+	add := func(msg awot.KeyExchangeMessage) {
+		id := msg.Owner + ":" + msg.Origin
+		if added[id] || len(signatures) >= KEY_REPLY_MAX_SIGNATURES {
+			return
+		}
+		added[id] = true
+		signatures = append(signatures, msg)
+	}
+
+	for _, msg := range ownerSignatures {
+		if msg.Origin != g.Parameters.Identifier {
+			for _, signerMsg := range g.keyRing.Signatures(msg.Origin, key, validity) {
+				if signerMsg.Origin != owner {
+					add(signerMsg)
+				}
+			}
+		}
+	}
+	for _, msg := range ownerSignatures {
+		add(msg)
+	}
+	return signatures
+}
+
+// Returns the key of the peer with given name, asking the network for the signatures of its key if it is not trusted yet.
+// Waits until the confidence in the key passes the threshold, or returns an error after the lookup timeout or once
+// given channel is closed (nil for no cancellation).
+func (g *Gossiper) lookupKey(owner string, cancel <-chan struct{}) (crypto.PublicKey, error) {
+	if key, present := g.keyRing.GetKey(owner); present {
+		return key, nil
+	}
+
+	common.Log(KeyLookupStartString(owner), common.LOG_MODE_REACTIVE)
+	g.requestKey(owner)
+
+	timeout := time.NewTimer(time.Second * time.Duration(g.Parameters.KeyLookupTimeout))
+	defer timeout.Stop()
+	retry := time.NewTicker(KEY_REQUEST_RETRY)
+	defer retry.Stop()
+	poll := time.NewTicker(200 * time.Millisecond)
+	defer poll.Stop()
+
+	for {
+		select {
+		case <-poll.C:
+			if key, present := g.keyRing.GetKey(owner); present {
+				g.notifyKeyLookup(owner, nil)
+				return key, nil
+			}
+		case <-retry.C:
+			// new signatures may be known by then, or neighbors may have changed
+			g.requestKey(owner)
+		case <-timeout.C:
+			err := fmt.Errorf("timed out after %ds without a trusted key", g.Parameters.KeyLookupTimeout)
+			g.notifyKeyLookup(owner, err)
+			return nil, err
+		case <-cancel:
+			// the key is no longer needed
+			return nil, errors.New("key lookup cancelled")
+		}
+	}
+}
+
+// Starts the lookup of the key of the peer with given name in the background, until stopped
+func (g *Gossiper) startKeyLookup(owner string) *keyLookup {
+	lookup := &keyLookup{
+		owner:  owner,
+		done:   make(chan struct{}),
+		cancel: make(chan struct{}),
+		once:   &sync.Once{},
+	}
+	go func() {
+		lookup.key, lookup.err = g.lookupKey(owner, lookup.cancel)
+		close(lookup.done)
+	}()
+	return lookup
+}
+
+// Stops the lookup if it is still running, its result then being an error. Does nothing on a nil lookup.
+func (lookup *keyLookup) stop() {
+	if lookup == nil {
+		return
+	}
+	lookup.once.Do(func() {
+		close(lookup.cancel)
+	})
+}
+
+// Waits for the end of the lookup and returns its result
+func (lookup *keyLookup) wait() (crypto.PublicKey, error) {
+	<-lookup.done
+	return lookup.key, lookup.err
+}
+
+// Notifies the client of the end of a key lookup
+func (g *Gossiper) notifyKeyLookup(owner string, err error) {
+	notification := common.KeyLookupNotification(owner, err)
+	if g.ClientAddress != nil {
+		g.clientOutputQueue <- &common.Packet{
+			ClientPacket: common.ClientPacket{
+				Notification: notification,
+			},
+			Destination: *g.ClientAddress,
+		}
+	}
+	// print same notification
+	common.Log(*notification, common.LOG_MODE_REACTIVE)
+}
@@ -8,7 +8,9 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/No-Trust/peerster/awot"
 	"github.com/No-Trust/peerster/common"
 	"github.com/No-Trust/peerster/rep"
 )
@@ -33,10 +35,34 @@ func main() {
 	etimer := flag.Uint("etimer", 2, "timer duration for the sending of anti entropy status")
 	reptimer := flag.Uint("reptimer", rep.DEFAULT_REP_REQ_TIMER,
 		"timer duration for reputation update requests")
+	globalTrust := flag.Bool("globaltrust", false, "compute EigenTrust-like global reputations from the reputation updates")
+	repHalfLife := flag.Uint("rephalflife", uint(rep.REP_HALF_LIFE.Seconds()), "half-life of the reputations decaying toward the initial reputation, 0 for no decay")
+	throttleThreshold := flag.Float64("throttlethresh", 0, "contribution-based reputation under which the packets of a peer are rate limited, 0 to disable")
+	throttleRate := flag.Uint("throttlerate", 20, "number of packets per second accepted from a throttled peer")
+	refuseThreshold := flag.Float64("refusethresh", 0, "contribution-based reputation under which the data requests of a peer are refused, 0 to disable")
+	ignoreKeysThreshold := flag.Float64("ignorekeysthresh", 0, "signature-based reputation under which the key signatures of a peer are ignored, 0 to disable")
+	quarantineThreshold := flag.Float64("quarantinethresh", 0, "signature-based reputation under which every packet of a peer is dropped, 0 to disable")
+	quarantine := flag.Uint("quarantine", 300, "duration of the quarantine of a peer")
+	uploadSlots := flag.Uint("uploadslots", 4, "number of peers served with data at the same time, 0 for no limit")
+	sigIncrease := flag.Float64("sigincrease", float64(rep.SIG_INCREASE_LIMIT), "increase of the signature-based reputations for a correct signature")
+	sigDecrease := flag.Float64("sigdecrease", float64(rep.SIG_DECREASE_LIMIT), "decrease factor of the signature-based reputations for a wrong signature")
+	repWeights := flag.String("repweights", "", "weights of the reputation scores, as purpose=signal:weight,...;... with the purposes keysigning, upload, relay and update and the signals sig, contrib and ledger")
+	repHistory := flag.Int("rephistory", rep.HISTORY_MAX_EVENTS, "number of events kept in the reputation history of each peer, 0 for no history")
+	repHistoryAge := flag.Uint("rephistoryage", uint(rep.HISTORY_MAX_AGE/time.Second), "age in seconds from which the events of the reputation histories are dropped, 0 for no limit")
+	updateWeight := flag.Float64("repweight", float64(rep.UPDATE_WEIGHT_LIMIT), "weight of the reputation updates of the most reputable peers")
 	noforward := flag.Bool("noforward", false, "for testing : forwarding of route rumors only")
 	natTraversal := flag.Bool("traversal", false, "nat travarsal option")
 	keysdir := flag.String("keys", ".", "directory for boostrap public keys")
+	keyalg := flag.String("keyalg", "rsa", "algorithm of the generated keys : rsa or ed25519")
+	passphrase := flag.String("passphrase", "", "passphrase encrypting the private key, also read from "+KEY_PASSPHRASE_ENV+" or asked if needed")
 	confidenceThreshold := flag.Float64("cthresh", 0.20, "confidence threshold for collected public keys")
+	kvalidity := flag.Uint("kvalidity", 86400, "validity duration of the key signatures")
+	ktimer := flag.Uint("ktimer", 3600, "timer duration for the re-advertisement of the key signatures")
+	klookup := flag.Uint("klookup", 30, "time waited for the key of an unknown peer looked up in the network")
+	collision := flag.String("collision", "paths", "policy for competing keys of a peer : paths, confidence or reject")
+	metric := flag.String("metric", "path", "trust metric for the confidence of the keys : path, flow or beta")
+	sybilThreshold := flag.Float64("sybilthresh", 0.5, "suspicion from which a peer is considered as a Sybil identity")
+	sybilCap := flag.Float64("sybilcap", 1.0, "maximum trust put in suspected Sybil identities, 1 for no cap")
 
 	// Program execution log mode
 	logMode := flag.String("logs", common.LOG_MODE_REACTIVE, "execution log mode")
@@ -52,6 +78,38 @@ func main() {
 		common.CheckRead(errors.New("gossipPort must be of the form ip:port"))
 	}
 
+	if *ktimer == 0 || *ktimer >= *kvalidity {
+		common.CheckRead(errors.New("ktimer must be positive and smaller than kvalidity"))
+	}
+
+	repConfig := rep.DefaultConfig()
+	repConfig.GlobalTrust = *globalTrust
+	repConfig.SigHalfLife = time.Duration(*repHalfLife) * time.Second
+	repConfig.ContribHalfLife = time.Duration(*repHalfLife) * time.Second
+	repConfig.SigIncreaseLimit = float32(*sigIncrease)
+	repConfig.SigDecreaseLimit = float32(*sigDecrease)
+	repConfig.UpdateWeightLimit = float32(*updateWeight)
+	repConfig.HistoryMaxEvents = *repHistory
+	repConfig.HistoryMaxAge = time.Duration(*repHistoryAge) * time.Second
+	common.CheckRead(rep.ParseWeights(*repWeights, repConfig.Weights))
+	common.CheckRead(repConfig.Validate())
+
+	policy := PolicyConfig{
+		ThrottleThreshold:   float32(*throttleThreshold),
+		ThrottleRate:        *throttleRate,
+		RefuseDataThreshold: float32(*refuseThreshold),
+		IgnoreKeysThreshold: float32(*ignoreKeysThreshold),
+		QuarantineThreshold: float32(*quarantineThreshold),
+		QuarantineTimeout:   time.Duration(*quarantine) * time.Second,
+	}
+
+	keyAlgorithm, err := awot.ParseKeyAlgorithm(*keyalg)
+	common.CheckRead(err)
+	collisionPolicy, err := awot.ParseCollisionPolicy(*collision)
+	common.CheckRead(err)
+	trustMetric, err := awot.ParseTrustMetric(*metric)
+	common.CheckRead(err)
+
 	gossipIP := sipport[0]
 	gossipPort := sipport[1]
 
@@ -96,6 +154,9 @@ func main() {
 		Etimer:                 *etimer,
 		Rtimer:                 *rtimer,
 		Reptimer:               *reptimer,
+		RepConfig:              repConfig,
+		UploadSlots:            *uploadSlots,
+		Policy:                 policy,
 		Hoplimit:               HOP_LIMIT,
 		NoForward:              *noforward,
 		NatTraversal:           *natTraversal,
@@ -109,9 +170,19 @@ func main() {
 		ChunksDirectory:        CHUNKS_DIR,
 		HashLength:             HASH_LENGTH,
 		KeyFileName:            KEY_DIRECTORY + "private.key",
+		KeyAlgorithm:           keyAlgorithm,
+		KeyPassphrase:          keyPassphrase(*passphrase, KEY_DIRECTORY+"private.key"),
 		PubKeyFileName:         KEY_DIRECTORY + identifier + ".pub",
 		TrustedKeysDirectory:   *keysdir,
+		TrustFileName:          KEY_DIRECTORY + "trust.gob",
 		KeyConfidenceThreshold: float32(*confidenceThreshold),
+		KeyValidity:            *kvalidity,
+		Ktimer:                 *ktimer,
+		KeyLookupTimeout:       *klookup,
+		KeyCollisionPolicy:     collisionPolicy,
+		KeyTrustMetric:         trustMetric,
+		SybilThreshold:         float32(*sybilThreshold),
+		SybilCap:               float32(*sybilCap),
 	}
 
 	var g = NewGossiper(parameters, peerAddrs)
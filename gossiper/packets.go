@@ -29,12 +29,14 @@ type PeerMessage struct {
 }
 
 type RumorMessage struct {
-	Origin      string
-	ID          uint32
-	Text        string
-	LastIP      *net.IP
-	LastPort    *int
-	KeyExchange *awot.KeyExchangeMessage
+	Origin        string
+	ID            uint32
+	Text          string
+	LastIP        *net.IP
+	LastPort      *int
+	KeyExchange   *awot.KeyExchangeMessage
+	KeyRevocation *awot.KeyRevocationMessage
+	KeyTransition *awot.KeyTransitionMessage
 }
 
 type PeerStatus struct {
@@ -84,6 +86,23 @@ type DataReply struct {
 	SigMetaUploader *[]byte
 }
 
+/***** Key Request & Reply *****/
+
+type KeyRequest struct {
+	Origin   string
+	ID       uint32
+	Owner    string
+	HopLimit uint32
+}
+
+type KeyReply struct {
+	Origin      string
+	Destination string
+	HopLimit    uint32
+	Owner       string
+	Signatures  []awot.KeyExchangeMessage
+}
+
 type GossipPacket struct {
 	Rumor               *RumorMessage
 	Status              *StatusPacket
@@ -92,6 +111,24 @@ type GossipPacket struct {
 	DataReply           *DataReply
 	RepContribUpdateReq bool
 	RepUpdate           *rep.RepUpdate
+	KeyRequest          *KeyRequest
+	KeyReply            *KeyReply
+	IdentityChallenge   *IdentityChallenge
+	IdentityProof       *IdentityProof
+}
+
+/***** Identity Challenge & Proof *****/
+
+// Sent to the address of a direct rumor, to bind it to the identity of its origin
+type IdentityChallenge struct {
+	Address string // address the challenged peer was seen at
+	Nonce   []byte
+}
+
+// Answer to an identity challenge, signed with the key of the peer
+type IdentityProof struct {
+	Name      string
+	Signature []byte // signature of the name, the address and the nonce, see rep.BindingHash
 }
 
 type Packet struct {
@@ -99,16 +136,40 @@ type Packet struct {
 	Destination  net.UDPAddr
 }
 
+/***** Gossip Packet *****/
+
+// Class of the traffic of the packet, for the contribution ledger
+func (pkt *GossipPacket) trafficClass() rep.TrafficClass {
+	switch {
+	case pkt.DataRequest != nil || pkt.DataReply != nil:
+		return rep.TRAFFIC_DATA
+	case pkt.Rumor != nil && !pkt.Rumor.isRoute():
+		return rep.TRAFFIC_RUMOR
+	case pkt.Private != nil && pkt.Private.RepUpdate == nil && !pkt.Private.RepSigUpdateReq:
+		return rep.TRAFFIC_RUMOR
+	}
+	return rep.TRAFFIC_CONTROL
+}
+
 /***** Rumor Message *****/
 
 func (rumor *RumorMessage) isRoute() bool {
-	return rumor.Text == "" && rumor.KeyExchange == nil
+	return rumor.Text == "" && rumor.KeyExchange == nil && rumor.KeyRevocation == nil &&
+		rumor.KeyTransition == nil
 }
 
 func (rumor *RumorMessage) isKeyExchange() bool {
 	return rumor.KeyExchange != nil
 }
 
+func (rumor *RumorMessage) isKeyRevocation() bool {
+	return rumor.KeyRevocation != nil
+}
+
+func (rumor *RumorMessage) isKeyTransition() bool {
+	return rumor.KeyTransition != nil
+}
+
 func (rumor *RumorMessage) isChat() bool {
 	return !rumor.isRoute()
 }
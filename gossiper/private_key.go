@@ -0,0 +1,278 @@
+// Storage of the private key of the gossiper as a PKCS#8 PEM, optionally encrypted with a passphrase (PBES2)
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Environment variable from which the passphrase of the private key is read when not given as flag
+const KEY_PASSPHRASE_ENV = "PEERSTER_KEY_PASSPHRASE"
+
+// Number of iterations of PBKDF2 deriving the encryption key from the passphrase
+const KEY_PBKDF2_ITERATIONS = 600000
+
+// Maximum number of iterations of PBKDF2 accepted from a stored key, for a crafted file not to hang the gossiper
+const KEY_PBKDF2_MAX_ITERATIONS = 10 * KEY_PBKDF2_ITERATIONS
+
+const pemPrivateKeyType = "PRIVATE KEY"
+const pemEncryptedPrivateKeyType = "ENCRYPTED PRIVATE KEY"
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// EncryptedPrivateKeyInfo of PKCS#8 (RFC 5208)
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// PBES2-params of PKCS#5 (RFC 8018)
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+// PBKDF2-params of PKCS#5 (RFC 8018)
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int `asn1:"optional"`
+	PRF        pkix.AlgorithmIdentifier
+}
+
+// Return the passphrase of the private key stored in given file : the given flag value, else the environment variable,
+// else asked interactively if the stored key is encrypted. Empty if the key is not to be encrypted.
+func keyPassphrase(flagValue, filename string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if env := os.Getenv(KEY_PASSPHRASE_ENV); env != "" {
+		return env
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		// no stored key
+		return ""
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != pemEncryptedPrivateKeyType {
+		// stored key is not encrypted
+		return ""
+	}
+	return promptPassphrase(filename)
+}
+
+// Ask the passphrase of the given key file on the terminal, without echo if possible
+func promptPassphrase(filename string) string {
+	fmt.Fprintf(os.Stderr, "Passphrase of %s : ", filename)
+
+	echo := exec.Command("stty", "-echo")
+	echo.Stdin = os.Stdin
+	if echo.Run() == nil {
+		defer func() {
+			restore := exec.Command("stty", "echo")
+			restore.Stdin = os.Stdin
+			restore.Run()
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimRight(line, "\r\n")
+}
+
+// Save the given private key to file as a PKCS#8 PEM, encrypted if a passphrase is given
+func savePrivateKey(key crypto.Signer, filename, passphrase string) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	block := &pem.Block{
+		Type:  pemPrivateKeyType,
+		Bytes: der,
+	}
+	if passphrase != "" {
+		block, err = encryptPrivateKey(der, passphrase)
+		if err != nil {
+			return err
+		}
+	}
+	return ioutil.WriteFile(filename, pem.EncodeToMemory(block), 0600)
+}
+
+// Load the private key stored in given file, as a PKCS#8 PEM or in the Gob format used before
+// A key stored with Gob is saved again as a PKCS#8 PEM.
+func loadPrivateKey(filename, passphrase string) (crypto.Signer, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		// key saved by an older version
+		key, err := loadGobPrivateKey(filename)
+		if err != nil {
+			return nil, err
+		}
+		return key, savePrivateKey(key, filename, passphrase)
+	}
+
+	der := block.Bytes
+	switch block.Type {
+	case pemPrivateKeyType:
+	case pemEncryptedPrivateKeyType:
+		if passphrase == "" {
+			return nil, errors.New("private key is encrypted, a passphrase is needed")
+		}
+		der, err = decryptPrivateKey(der, passphrase)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("unknown private key type " + block.Type)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key")
+	}
+	return key, nil
+}
+
+// Encrypt the given PKCS#8 private key with PBES2 : PBKDF2 with HMAC-SHA256 and AES-256-CBC
+func encryptPrivateKey(der []byte, passphrase string) (*pem.Block, error) {
+	salt := make([]byte, 16)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:       salt,
+		Iterations: KEY_PBKDF2_ITERATIONS,
+		PRF:        pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ivParams, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParams}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	encryptionKey, err := pbkdf2.Key(sha256.New, passphrase, salt, KEY_PBKDF2_ITERATIONS, 32)
+	if err != nil {
+		return nil, err
+	}
+	blockCipher, err := aes.NewCipher(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	// PKCS#7 padding
+	padding := aes.BlockSize - len(der)%aes.BlockSize
+	plaintext := append(append([]byte{}, der...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(blockCipher, iv).CryptBlocks(ciphertext, plaintext)
+
+	encrypted, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: ciphertext,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pem.Block{
+		Type:  pemEncryptedPrivateKeyType,
+		Bytes: encrypted,
+	}, nil
+}
+
+// Decrypt a PKCS#8 private key encrypted with PBES2, as written by encryptPrivateKey (or by openssl with -v2 aes256)
+func decryptPrivateKey(der []byte, passphrase string) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, err
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, errors.New("unsupported private key encryption")
+	}
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, err
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) || !params.EncryptionScheme.Algorithm.Equal(oidAES256CBC) {
+		return nil, errors.New("unsupported private key encryption")
+	}
+	var kdfParams pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+		return nil, err
+	}
+	if !kdfParams.PRF.Algorithm.Equal(oidHMACWithSHA256) {
+		return nil, errors.New("unsupported private key derivation")
+	}
+	if kdfParams.Iterations <= 0 || kdfParams.Iterations > KEY_PBKDF2_MAX_ITERATIONS {
+		return nil, fmt.Errorf("unsupported number of iterations %d of the private key derivation", kdfParams.Iterations)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, err
+	}
+	if len(iv) != aes.BlockSize || len(info.EncryptedData) == 0 || len(info.EncryptedData)%aes.BlockSize != 0 {
+		return nil, errors.New("malformed encrypted private key")
+	}
+
+	encryptionKey, err := pbkdf2.Key(sha256.New, passphrase, kdfParams.Salt, kdfParams.Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	blockCipher, err := aes.NewCipher(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(blockCipher, iv).CryptBlocks(plaintext, info.EncryptedData)
+
+	// remove PKCS#7 padding, a wrong passphrase giving an invalid one most of the time
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > aes.BlockSize || !bytes.Equal(plaintext[len(plaintext)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, errors.New("wrong passphrase")
+	}
+	return plaintext[:len(plaintext)-padding], nil
+}
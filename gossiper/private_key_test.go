@@ -0,0 +1,156 @@
+// Tests for the storage of the private key of the gossiper
+package main
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Return a path to a file named name in a new temporary directory, removed at the end of the test
+func tempKeyFile(t *testing.T, name string) string {
+	dir, err := ioutil.TempDir("", "peerster")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, name)
+}
+
+// TestPrivateKeyRoundTrip tests saving and loading a private key, with and without passphrase
+func TestPrivateKeyRoundTrip(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate ed25519 key: %v", err)
+	}
+
+	for _, passphrase := range []string{"", "correct horse"} {
+		filename := tempKeyFile(t, "private.pem")
+		if err := savePrivateKey(key, filename, passphrase); err != nil {
+			t.Fatalf("could not save private key: %v", err)
+		}
+
+		data, _ := ioutil.ReadFile(filename)
+		block, _ := pem.Decode(data)
+		if encrypted := block != nil && block.Type == pemEncryptedPrivateKeyType; encrypted != (passphrase != "") {
+			t.Fatalf("private key should be encrypted only with a passphrase, got %v", block)
+		}
+
+		loaded, err := loadPrivateKey(filename, passphrase)
+		if err != nil {
+			t.Fatalf("could not load private key: %v", err)
+		}
+		if !reflect.DeepEqual(loaded, key) {
+			t.Fatalf("loaded private key differs from the saved one")
+		}
+	}
+}
+
+// TestPrivateKeyWrongPassphrase tests that an encrypted private key is not loaded without its passphrase
+func TestPrivateKeyWrongPassphrase(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate ed25519 key: %v", err)
+	}
+	filename := tempKeyFile(t, "private.pem")
+	if err := savePrivateKey(key, filename, "correct horse"); err != nil {
+		t.Fatalf("could not save private key: %v", err)
+	}
+
+	for _, passphrase := range []string{"", "wrong horse"} {
+		if _, err := loadPrivateKey(filename, passphrase); err == nil {
+			t.Fatalf("private key should not be loaded with passphrase %q", passphrase)
+		}
+	}
+}
+
+// TestPrivateKeyIterationsCap tests that a stored key asking for too many iterations of PBKDF2 is rejected
+func TestPrivateKeyIterationsCap(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "openssl_key_encrypted.pem"))
+	if err != nil {
+		t.Fatalf("could not read fixture: %v", err)
+	}
+	block, _ := pem.Decode(data)
+
+	// rewrite the iteration count of the fixture
+	var info encryptedPrivateKeyInfo
+	var params pbes2Params
+	var kdfParams pbkdf2Params
+	asn1.Unmarshal(block.Bytes, &info)
+	asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params)
+	asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams)
+
+	for _, iterations := range []int{0, KEY_PBKDF2_MAX_ITERATIONS + 1} {
+		kdfParams.Iterations = iterations
+		raw, _ := asn1.Marshal(kdfParams)
+		params.KeyDerivationFunc.Parameters = asn1.RawValue{FullBytes: raw}
+		raw, _ = asn1.Marshal(params)
+		info.Algorithm.Parameters = asn1.RawValue{FullBytes: raw}
+		der, err := asn1.Marshal(info)
+		if err != nil {
+			t.Fatalf("could not encode private key: %v", err)
+		}
+
+		if _, err := decryptPrivateKey(der, "peerster"); err == nil {
+			t.Fatalf("private key with %d iterations should be rejected", iterations)
+		}
+	}
+}
+
+// TestPrivateKeyOpenSSL tests loading a private key encrypted by openssl pkcs8 -topk8 -v2 aes256 -v2prf hmacWithSHA256
+func TestPrivateKeyOpenSSL(t *testing.T) {
+	plain, err := loadPrivateKey(filepath.Join("testdata", "openssl_key.pem"), "")
+	if err != nil {
+		t.Fatalf("could not load plain fixture: %v", err)
+	}
+	encrypted, err := loadPrivateKey(filepath.Join("testdata", "openssl_key_encrypted.pem"), "peerster")
+	if err != nil {
+		t.Fatalf("could not load encrypted fixture: %v", err)
+	}
+	if !reflect.DeepEqual(plain, encrypted) {
+		t.Fatalf("encrypted fixture should hold the same private key as the plain one")
+	}
+}
+
+// TestPrivateKeyGobMigration tests that a private key saved with Gob by an older version is loaded and saved again as PEM
+func TestPrivateKeyGobMigration(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("could not generate rsa key: %v", err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate ed25519 key: %v", err)
+	}
+
+	for _, key := range []crypto.Signer{rsaKey, ed25519Key} {
+		filename := tempKeyFile(t, "private.key")
+		if err := saveGob(filename, key); err != nil {
+			t.Fatalf("could not save gob key: %v", err)
+		}
+
+		loaded, err := loadPrivateKey(filename, "correct horse")
+		if err != nil {
+			t.Fatalf("could not load gob key: %v", err)
+		}
+		if !reflect.DeepEqual(loaded.Public(), key.Public()) {
+			t.Fatalf("loaded gob key differs from the saved one")
+		}
+
+		data, _ := ioutil.ReadFile(filename)
+		if block, _ := pem.Decode(data); block == nil || block.Type != pemEncryptedPrivateKeyType {
+			t.Fatalf("gob key should be saved again as an encrypted PEM")
+		}
+		if _, err := loadPrivateKey(filename, "correct horse"); err != nil {
+			t.Fatalf("could not load migrated key: %v", err)
+		}
+	}
+}
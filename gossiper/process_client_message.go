@@ -3,10 +3,12 @@ package main
 
 import (
 	"crypto"
-	"crypto/rand"
-	"crypto/rsa"
 	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"github.com/No-Trust/peerster/awot"
 	"github.com/No-Trust/peerster/common"
+	"github.com/No-Trust/peerster/rep"
 	"io/ioutil"
 	"log"
 	"net"
@@ -60,7 +62,7 @@ func processNewMessage(msg *common.NewMessage, g *Gossiper, remoteaddr *net.UDPA
 	}
 
 	// and send the rumor
-	destPeer := g.peerSet.RandomPeer()
+	destPeer := g.randomPeer(g.peerSet)
 	if destPeer != nil {
 		go g.rumormonger(&rumor, destPeer)
 	}
@@ -71,14 +73,25 @@ func processNewPrivateMessage(pcm *common.NewPrivateMessage, g *Gossiper) {
 	// new private message
 	common.Log(*pcm.ClientNewPrivateMessageString(), common.LOG_MODE_REACTIVE)
 
-	// encrypt text with receiver's public key
 	rpub, pres := g.keyRing.GetKey(pcm.Dest)
 	if !pres {
-		// no public key to the destination
+		// no trusted public key for the destination yet : look it up, and send the message once found
+		go func() {
+			rpub, err := g.lookupKey(pcm.Dest, nil)
+			if err == nil {
+				sendNewPrivateMessage(pcm, rpub, g)
+			}
+		}()
 		return
 	}
+	sendNewPrivateMessage(pcm, rpub, g)
+}
+
+// Encrypt the private message of the user with the given key of its destination, and send it
+func sendNewPrivateMessage(pcm *common.NewPrivateMessage, rpub crypto.PublicKey, g *Gossiper) {
+	// encrypt text with receiver's public key
 	secret := []byte(pcm.Text)
-	ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, &rpub, secret, nil)
+	ciphertext, err := awot.Encrypt(rpub, secret)
 	if err != nil {
 		log.Println(err)
 		return
@@ -158,14 +171,18 @@ func processRequestUpdate(req *bool, g *Gossiper, remoteaddr *net.UDPAddr) {
 			graph = nil
 		}
 
-		// Get reputation update
-		repUpdate := g.reputationTable.GetUpdate()
+		// Get reputations keyed by peer name and address
+		repUpdate := g.reputationTable.GetPeerView()
 
 		update := common.RepUpdate{
 			SigReps:     common.ReputationMap(repUpdate.SigReps),
 			ContribReps: common.ReputationMap(repUpdate.ContribReps),
 		}
 
+		// Get statistics on the pending key signatures
+		pendingStats := g.keyRing.PendingStats()
+		pending := common.PendingKeyStats(pendingStats)
+
 		// sending
 		g.clientOutputQueue <- &common.Packet{
 			ClientPacket: common.ClientPacket{
@@ -173,6 +190,7 @@ func processRequestUpdate(req *bool, g *Gossiper, remoteaddr *net.UDPAddr) {
 				PeerSlice:      &cpy,
 				KeyRingJSON:    &graph,
 				Reputations:    &update,
+				PendingKeys:    &pending,
 			},
 			Destination: *remoteaddr,
 		}
@@ -215,7 +233,7 @@ func processNewFile(newfile *common.NewFile, g *Gossiper) {
 	metahash := h.Sum(nil)
 
 	// signing the metahash
-	SigOrigin, err := rsa.SignPSS(rand.Reader, &(g.key), crypto.SHA256, metahash, nil)
+	SigOrigin, err := awot.Sign(g.privateKey(), metahash)
 	common.CheckError(err)
 
 	meta := FileMetadata{
@@ -292,3 +310,207 @@ func processFileRequest(filereq *common.FileRequest, g *Gossiper) {
 	// otherwise, start the download process
 	go startDownload(g, filereq)
 }
+
+// Trust update : the user takes a manual trust decision on the key of a peer
+func processTrustUpdate(tu *common.TrustUpdate, g *Gossiper) {
+	var err error
+
+	switch {
+	case tu.Reset:
+		g.keyRing.ResetTrust(tu.Owner)
+	case tu.Distrust:
+		err = g.keyRing.Distrust(tu.Owner)
+	case tu.Fingerprint != "":
+		err = g.keyRing.Pin(tu.Owner, tu.Fingerprint)
+	case tu.KeyBytes != nil:
+		var key crypto.PublicKey
+		key, err = awot.DeserializeKey(tu.KeyBytes)
+		if err == nil {
+			record := awot.KeyRecord{
+				Owner:  tu.Owner,
+				KeyPub: key,
+			}
+			err = g.keyRing.ImportKey(record, tu.Confidence)
+		}
+	default:
+		err = errors.New("empty trust update")
+	}
+
+	if err == nil {
+		// persist the decision
+		g.saveTrustDecisions()
+	}
+
+	// send notification to client
+	notification := common.TrustUpdateNotification(tu.Owner, err)
+	if g.ClientAddress != nil {
+		g.clientOutputQueue <- &common.Packet{
+			ClientPacket: common.ClientPacket{
+				Notification: notification,
+			},
+			Destination: *g.ClientAddress,
+		}
+	}
+	// print same notification
+	common.Log(*notification, common.LOG_MODE_REACTIVE)
+}
+
+// Revoke key : the user withdraws its signature on the key of a peer, or revokes its own key
+func processRevokeKey(owner *string, g *Gossiper) {
+	err := g.RevokeKey(*owner)
+
+	// send notification to client
+	notification := common.RevocationNotification(*owner, err)
+	if g.ClientAddress != nil {
+		g.clientOutputQueue <- &common.Packet{
+			ClientPacket: common.ClientPacket{
+				Notification: notification,
+			},
+			Destination: *g.ClientAddress,
+		}
+	}
+	// print same notification
+	common.Log(*notification, common.LOG_MODE_REACTIVE)
+}
+
+// Rotate key : the user requests a new key for the gossiper
+func processRotateKey(req *bool, g *Gossiper) {
+	if !*req {
+		return
+	}
+
+	err := g.RotateKey()
+
+	// send notification to client
+	notification := common.RotationNotification(err)
+	if g.ClientAddress != nil {
+		g.clientOutputQueue <- &common.Packet{
+			ClientPacket: common.ClientPacket{
+				Notification: notification,
+			},
+			Destination: *g.ClientAddress,
+		}
+	}
+	// print same notification
+	common.Log(*notification, common.LOG_MODE_REACTIVE)
+}
+
+// Export key ring : the user requests a key bundle of the key ring
+func processExportKeyRing(req *common.KeyRingFile, g *Gossiper) {
+	err := g.ExportKeyRing(req.Path, req.Format)
+
+	// send notification to client
+	notification := common.KeyRingExportNotification(req.Path, err)
+	if g.ClientAddress != nil {
+		g.clientOutputQueue <- &common.Packet{
+			ClientPacket: common.ClientPacket{
+				Notification: notification,
+			},
+			Destination: *g.ClientAddress,
+		}
+	}
+	// print same notification
+	common.Log(*notification, common.LOG_MODE_REACTIVE)
+}
+
+// Import key ring : the user gives a key bundle whose signatures are added to the key ring
+func processImportKeyRing(req *common.KeyRingFile, g *Gossiper) {
+	result, err := g.ImportKeyRing(req.Path)
+
+	// send notification to client
+	notification := common.KeyRingImportNotification(req.Path, result.Added, len(result.Rejected), len(result.Unknown), err)
+	if g.ClientAddress != nil {
+		g.clientOutputQueue <- &common.Packet{
+			ClientPacket: common.ClientPacket{
+				Notification: notification,
+			},
+			Destination: *g.ClientAddress,
+		}
+	}
+	// print same notification
+	common.Log(*notification, common.LOG_MODE_REACTIVE)
+}
+
+// Verify key : the user compares the short authentication string of the key of a peer with its owner, then confirms it
+func processVerifyKey(kv *common.KeyVerification, g *Gossiper) {
+	var notification *string
+	if kv.SAS == "" {
+		verification, err := g.keyRing.Verification(kv.Owner)
+		notification = common.KeyVerificationNotification(kv.Owner, verification.Fingerprint, verification.Words, verification.SAS, err)
+	} else {
+		err := g.keyRing.ConfirmVerification(kv.Owner, kv.SAS)
+		if err == nil {
+			// persist the decision
+			g.saveTrustDecisions()
+		}
+		notification = common.KeyConfirmationNotification(kv.Owner, err)
+	}
+
+	// send notification to client
+	if g.ClientAddress != nil {
+		g.clientOutputQueue <- &common.Packet{
+			ClientPacket: common.ClientPacket{
+				Notification: notification,
+			},
+			Destination: *g.ClientAddress,
+		}
+	}
+	// print same notification
+	common.Log(*notification, common.LOG_MODE_REACTIVE)
+}
+
+// Explain Key : the user asks why the key of a peer has its confidence level
+func processExplainKey(name *string, g *Gossiper) {
+	explanation, err := g.keyRing.Explain(*name)
+	notification := common.KeyExplanationNotification(*name, KeyExplanationString(explanation), err)
+
+	// send notification to client, with the explanation for the gui
+	if g.ClientAddress != nil {
+		g.clientOutputQueue <- &common.Packet{
+			ClientPacket: common.ClientPacket{
+				Notification: notification,
+			},
+			Destination: *g.ClientAddress,
+		}
+		if err == nil {
+			explanationJSON, err := json.Marshal(explanation)
+			if err == nil {
+				g.clientOutputQueue <- &common.Packet{
+					ClientPacket: common.ClientPacket{
+						KeyExplanation: &explanationJSON,
+					},
+					Destination: *g.ClientAddress,
+				}
+			}
+		}
+	}
+	// print same notification
+	common.Log(*notification, common.LOG_MODE_REACTIVE)
+}
+
+// Reputation History : the user asks why the reputations of a peer are what they are
+func processRepHistory(peer *string, g *Gossiper) {
+	events := g.reputationTable.GetHistory(*peer)
+	notification := common.RepHistoryNotification(*peer, RepHistoryString(events), len(events))
+
+	// send notification to client, with the history for the gui
+	if g.ClientAddress != nil {
+		g.clientOutputQueue <- &common.Packet{
+			ClientPacket: common.ClientPacket{
+				Notification: notification,
+			},
+			Destination: *g.ClientAddress,
+		}
+		historyJSON, err := json.Marshal(rep.PeerHistory{Peer: *peer, Events: events})
+		if err == nil {
+			g.clientOutputQueue <- &common.Packet{
+				ClientPacket: common.ClientPacket{
+					RepHistoryJSON: &historyJSON,
+				},
+				Destination: *g.ClientAddress,
+			}
+		}
+	}
+	// print same notification
+	common.Log(*notification, common.LOG_MODE_REACTIVE)
+}
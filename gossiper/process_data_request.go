@@ -2,10 +2,8 @@
 package main
 
 import (
-	"crypto"
-	"crypto/rand"
-	"crypto/rsa"
 	"crypto/sha256"
+	"github.com/No-Trust/peerster/awot"
 	"github.com/No-Trust/peerster/common"
 	"io/ioutil"
 	"net"
@@ -32,6 +30,17 @@ func (g *Gossiper) processDataRequest(req *DataRequest, remoteaddr *net.UDPAddr)
 			nextHopAddress = &hop
 		}
 
+		// refuse the peers with a too low reputation
+		if !g.allowDataRequest(req.Origin) {
+			return
+		}
+
+		// serve only the peers holding an upload slot, the ones giving more than they get being preferred
+		if !g.uploadSlots.acquire(req.Origin, g.uploadPriority) {
+			common.Log(UploadChokedString(req.Origin, g.reputationTable.Balance(req.Origin)), common.LOG_MODE_FULL)
+			return
+		}
+
 		hash := req.HashValue
 
 		// check if this is a metafile request
@@ -41,7 +50,7 @@ func (g *Gossiper) processDataRequest(req *DataRequest, remoteaddr *net.UDPAddr)
 			// this is a metafile request
 
 			// signing the metahash
-			SigUploader, err := rsa.SignPSS(rand.Reader, &(g.key), crypto.SHA256, fm.Metahash, nil)
+			SigUploader, err := awot.Sign(g.privateKey(), fm.Metahash)
 			common.CheckError(err)
 
 			var SigMetaUploaderP *[]byte = nil
@@ -50,7 +59,7 @@ func (g *Gossiper) processDataRequest(req *DataRequest, remoteaddr *net.UDPAddr)
 				newhash := sha256.New()
 				newhash.Write(metac)
 				metachashed := newhash.Sum(nil)
-				SigMetaUploader, err := rsa.SignPSS(rand.Reader, &(g.key), crypto.SHA256, metachashed, nil)
+				SigMetaUploader, err := awot.Sign(g.privateKey(), metachashed)
 				common.CheckError(err)
 				SigMetaUploaderP = &SigMetaUploader
 			}
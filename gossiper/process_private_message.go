@@ -2,9 +2,7 @@
 package main
 
 import (
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/sha256"
+	"github.com/No-Trust/peerster/awot"
 	"github.com/No-Trust/peerster/common"
 	"log"
 	"net"
@@ -18,16 +16,6 @@ func (g *Gossiper) processPrivateMessage(pm *PrivateMessage, remoteaddr *net.UDP
 	if pm.Dest == g.Parameters.Identifier {
 		// this node is the destination
 
-		// decipher
-		secret := []byte(pm.Text)
-		plaintext, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, &g.key, secret, nil)
-		if err != nil {
-			log.Println(err)
-			return
-		}
-		// printing
-		common.Log(*pm.PrivateMessageString(remoteaddr), common.LOG_MODE_REACTIVE)
-
 		// If it is a request for a sig-based reputation
 		// update, create one and send it as a reply
 		if pm.RepSigUpdateReq {
@@ -41,7 +29,10 @@ func (g *Gossiper) processPrivateMessage(pm *PrivateMessage, remoteaddr *net.UDP
 				g.gossipOutputQueue <- &Packet{
 					GossipPacket: GossipPacket{
 						Private: &PrivateMessage{
-							RepUpdate: g.reputationTable.GetSigUpdate(),
+							Origin:    g.Parameters.Identifier,
+							Dest:      pm.Origin,
+							HopLimit:  g.Parameters.Hoplimit,
+							RepUpdate: g.signRepUpdate(g.reputationTable.GetSigUpdate()),
 						},
 					},
 					Destination: stringToUDPAddr(nextHop),
@@ -56,12 +47,22 @@ func (g *Gossiper) processPrivateMessage(pm *PrivateMessage, remoteaddr *net.UDP
 
 			common.Log("RECEIVED SIG-REP UPDATE FROM "+pm.Origin, common.LOG_MODE_FULL)
 
-			g.reputationTable.UpdateReputations(pm.RepUpdate, pm.Origin)
+			g.updateReputations(pm.RepUpdate, pm.Origin)
 
 			return
 
 		}
 
+		// decipher
+		secret := []byte(pm.Text)
+		plaintext, err := awot.Decrypt(g.privateKey(), secret)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		// printing
+		common.Log(*pm.PrivateMessageString(remoteaddr), common.LOG_MODE_REACTIVE)
+
 		// send the message to the client, if it exists
 		if g.ClientAddress != nil {
 			g.clientOutputQueue <- &common.Packet{
@@ -5,8 +5,10 @@ package main
 */
 
 import (
+	"net"
 	"time"
 
+	"github.com/No-Trust/peerster/awot"
 	"github.com/No-Trust/peerster/common"
 	"github.com/No-Trust/peerster/rep"
 )
@@ -22,13 +24,23 @@ func repUpdateRequests(g *Gossiper, reptimer uint) {
 
 	for range ticker.C {
 
+		// Key the reputations of the peers with a trusted key by identity
+		g.identifyPeers()
+
+		// Forget a part of the past interactions
+		g.reputationTable.Decay()
+
+		// Take the updates received since the last round into account
+		g.reputationTable.ComputeGlobalTrust()
+
 		common.Log("Sending reputation update requests to most reputable peers...",
 			common.LOG_MODE_FULL)
 
-		highestRepTable := g.reputationTable.MostReputablePeers(rep.REP_REQ_PEER_COUNT)
+		count := g.Parameters.RepConfig.RequestPeerCount
 
-		highestRepTable.ForEachSigRep(func(peer string, _ float32) {
+		for _, ranked := range g.reputationTable.TopPeers(rep.SIG_REP, count) {
 
+			peer := ranked.Peer
 			nextHop := g.routingTable.Get(peer)
 
 			if nextHop != "" {
@@ -36,6 +48,9 @@ func repUpdateRequests(g *Gossiper, reptimer uint) {
 				g.gossipOutputQueue <- &Packet{
 					GossipPacket: GossipPacket{
 						Private: &PrivateMessage{
+							Origin:          g.Parameters.Identifier,
+							Dest:            peer,
+							HopLimit:        g.Parameters.Hoplimit,
 							RepSigUpdateReq: true,
 						},
 					},
@@ -44,19 +59,90 @@ func repUpdateRequests(g *Gossiper, reptimer uint) {
 
 			}
 
-		})
+		}
 
-		highestRepTable.ForEachContribRep(func(peer string, _ float32) {
+		for _, ranked := range g.reputationTable.TopPeers(rep.CONTRIB_REP, count) {
 
 			g.gossipOutputQueue <- &Packet{
 				GossipPacket: GossipPacket{
 					RepContribUpdateReq: true,
 				},
-				Destination: stringToUDPAddr(peer),
+				Destination: stringToUDPAddr(ranked.Peer),
 			}
 
-		})
+		}
+
+	}
+
+}
+
+func (g *Gossiper) identifyPeers() {
+
+	for _, name := range g.keyRing.GetPeerList() {
+
+		if key, present := g.keyRing.GetKey(name); present {
+			g.reputationTable.Identify(name, awot.Fingerprint(key))
+		}
+
+	}
+
+}
+
+func (g *Gossiper) challengeIdentity(name string, addr *net.UDPAddr) {
+
+	// Only peers with a trusted key have an identity
+	if _, present := g.keyRing.GetKey(name); !present || g.reputationTable.IsBound(addrToString(*addr), name) {
+		return
+	}
+
+	nonce, ok := g.reputationTable.Challenge(addrToString(*addr))
+	if !ok {
+		return
+	}
+
+	g.gossipOutputQueue <- &Packet{
+		GossipPacket: GossipPacket{
+			IdentityChallenge: &IdentityChallenge{
+				Address: addrToString(*addr),
+				Nonce:   nonce,
+			},
+		},
+		Destination: *addr,
+	}
+
+}
+
+func (g *Gossiper) processIdentityChallenge(challenge *IdentityChallenge, remoteaddr *net.UDPAddr) {
+
+	signature, err := rep.SignBinding(g.Parameters.Identifier, challenge.Address, challenge.Nonce, g.privateKey())
+	if err != nil {
+		common.Log("COULD NOT ANSWER IDENTITY CHALLENGE : "+err.Error(), common.LOG_MODE_FULL)
+		return
+	}
+
+	g.gossipOutputQueue <- &Packet{
+		GossipPacket: GossipPacket{
+			IdentityProof: &IdentityProof{
+				Name:      g.Parameters.Identifier,
+				Signature: signature,
+			},
+		},
+		Destination: *remoteaddr,
+	}
+
+}
+
+func (g *Gossiper) processIdentityProof(proof *IdentityProof, remoteaddr *net.UDPAddr) {
 
+	key, present := g.keyRing.GetKey(proof.Name)
+	if !present {
+		return
+	}
+
+	err := g.reputationTable.Bind(addrToString(*remoteaddr), proof.Name, key, proof.Signature)
+
+	if err != nil {
+		common.Log("REJECTED IDENTITY PROOF : "+err.Error(), common.LOG_MODE_FULL)
 	}
 
 }
@@ -79,7 +165,7 @@ func (g *Gossiper) processContribRepUpdateReq(sender *common.Peer) {
 
 	g.gossipOutputQueue <- &Packet{
 		GossipPacket: GossipPacket{
-			RepUpdate: g.reputationTable.GetContribUpdate(),
+			RepUpdate: g.signRepUpdate(g.reputationTable.GetContribUpdate()),
 		},
 		Destination: sender.Address,
 	}
@@ -91,6 +177,38 @@ func (g *Gossiper) processContribRepUpdate(update *rep.RepUpdate, sender *common
 	common.Log("RECEIVED CONTRIB-REP UPDATE FROM "+addrToString(sender.Address),
 		common.LOG_MODE_FULL)
 
-	g.reputationTable.UpdateReputations(update, addrToString(sender.Address))
+	g.updateReputations(update, addrToString(sender.Address))
+
+}
+
+func (g *Gossiper) signRepUpdate(update *rep.RepUpdate) *rep.RepUpdate {
+
+	err := g.reputationTable.SignUpdate(update, g.Parameters.Identifier, g.privateKey())
+
+	if err != nil {
+		common.Log("COULD NOT SIGN REP UPDATE : "+err.Error(), common.LOG_MODE_FULL)
+	}
+
+	return update
+
+}
+
+func (g *Gossiper) updateReputations(update *rep.RepUpdate, sender string) {
+
+	// The update is verified with the trusted key of its issuer, and a
+	// wrong signature from the bound address of the issuer is penalized
+	// according to the confidence in that key
+	issuerKey, _ := g.keyRing.GetKey(update.Issuer)
+
+	var confidence float32 = 0
+	if record, present := g.keyRing.GetRecord(update.Issuer); present {
+		confidence = record.Confidence
+	}
+
+	err := g.reputationTable.UpdateReputations(update, sender, issuerKey, confidence)
+
+	if err != nil {
+		common.Log("REJECTED REP UPDATE : "+err.Error(), common.LOG_MODE_FULL)
+	}
 
 }
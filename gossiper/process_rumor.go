@@ -3,6 +3,7 @@ package main
 
 import (
 	"github.com/No-Trust/peerster/common"
+	"github.com/No-Trust/peerster/rep"
 	"net"
 )
 
@@ -66,21 +67,34 @@ func (g *Gossiper) processRumor(rumor *RumorMessage, remoteaddr *net.UDPAddr) {
 		// process key exchange message
 		if rumor.isKeyExchange() {
 			owner := rumor.KeyExchange.Owner
-			repOwner, present := g.reputationTable.GetSigRep(owner)
+			repOwner, present := g.reputationTable.Score(rep.PURPOSE_KEY_SIGNING, owner)
 			if !present {
 				repOwner = 0.5
 			}
 			g.processKeyExchangeMessage(rumor.KeyExchange, repOwner, remoteaddr)
 		}
 
-		// this is the 'expected' message
+		// process key revocation message
+		if rumor.isKeyRevocation() {
+			g.processKeyRevocationMessage(rumor.KeyRevocation, remoteaddr)
+		}
 
-		// Increase contribution-based reputation of sender
-		g.reputationTable.IncreaseContribRep(addrToString(*remoteaddr))
+		// process key transition message
+		if rumor.isKeyTransition() {
+			g.processKeyTransitionMessage(rumor.KeyTransition, remoteaddr)
+		}
+
+		// this is the 'expected' message
 
 		// update next hop routing table, unconditionnaly because this is a new rumor
 		g.routingTable.AddNextHop(rumor.Origin, remoteaddr)
 
+		// the origin of a direct rumor claims the address it came from,
+		// which is bound to its identity once it proves it holds its key
+		if directRoute {
+			g.challengeIdentity(rumor.Origin, remoteaddr)
+		}
+
 		// update messages
 		g.messages.Add(rumor)
 
@@ -139,7 +153,7 @@ func (g *Gossiper) processRumor(rumor *RumorMessage, remoteaddr *net.UDPAddr) {
 		// start rumormongering
 		// select a random peer
 
-		destPeer := (&otherPeers).RandomPeer()
+		destPeer := g.randomPeer(otherPeers)
 		if destPeer != nil {
 			go g.rumormonger(rumor, destPeer)
 		}
@@ -3,6 +3,7 @@ package main
 
 import (
 	"github.com/No-Trust/peerster/common"
+	"github.com/No-Trust/peerster/rep"
 	"log"
 	"net"
 	"sync"
@@ -111,7 +112,7 @@ func (g *Gossiper) compareStateAndProcess(rumor *RumorMessage, status *StatusPac
 			common.Log(*CoinFlipString(&destPeer.Address), common.LOG_MODE_FULL)
 			// continue
 
-			randPeer := g.reputationTable.ContribRandomPeer()
+			randPeer := g.reputationTable.RandomPeer(rep.PURPOSE_RELAY, g.policy.quarantined)
 
 			if randPeer != "" {
 				go g.rumormonger(rumor, &common.Peer{
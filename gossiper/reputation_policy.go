@@ -0,0 +1,189 @@
+// Reputation policy : enforcement actions against the peers with a low reputation
+package main
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/No-Trust/peerster/common"
+)
+
+// An action enforced against a peer with a low reputation
+type PolicyAction string
+
+const (
+	POLICY_THROTTLE    PolicyAction = "THROTTLE"             // rate limit the packets of the peer
+	POLICY_REFUSE_DATA PolicyAction = "REFUSE DATA"          // refuse the data requests of the peer
+	POLICY_IGNORE_KEYS PolicyAction = "IGNORE KEY EXCHANGES" // ignore the key exchange messages signed by the peer
+	POLICY_QUARANTINE  PolicyAction = "QUARANTINE"           // drop every packet of the peer, and never choose it
+)
+
+// PolicyConfig holds the reputation thresholds under which the actions are enforced, a null threshold (the default) disabling its action
+type PolicyConfig struct {
+	ThrottleThreshold   float32       // contribution-based reputation under which the packets of a peer are rate limited
+	ThrottleRate        uint          // number of packets per second accepted from a throttled peer
+	RefuseDataThreshold float32       // contribution-based reputation under which the data requests of a peer are refused
+	IgnoreKeysThreshold float32       // signature-based reputation under which the key exchange messages signed by a peer are ignored
+	QuarantineThreshold float32       // signature-based reputation under which a peer is quarantined
+	QuarantineTimeout   time.Duration // duration of a quarantine, after which the peer is given a new chance
+}
+
+// ReputationPolicy holds the state of the enforcement actions
+type ReputationPolicy struct {
+	config     PolicyConfig
+	quarantine map[string]time.Time      // address -> end of its quarantine
+	throttles  map[string]*throttleState // address -> packets accepted in the current second
+	enforced   map[string]bool           // action:peer -> true if the action is enforced against the peer
+	mutex      *sync.Mutex
+}
+
+// Packets accepted from a throttled peer in the current second
+type throttleState struct {
+	start time.Time
+	count uint
+}
+
+// Create a ReputationPolicy with given thresholds
+func NewReputationPolicy(config PolicyConfig) *ReputationPolicy {
+	return &ReputationPolicy{
+		config:     config,
+		quarantine: make(map[string]time.Time),
+		throttles:  make(map[string]*throttleState),
+		enforced:   make(map[string]bool),
+		mutex:      &sync.Mutex{},
+	}
+}
+
+// Returns true if the peer with given address is in quarantine
+func (policy *ReputationPolicy) quarantined(addr string) bool {
+	policy.mutex.Lock()
+	defer policy.mutex.Unlock()
+	end, present := policy.quarantine[addr]
+	return present && time.Now().Before(end)
+}
+
+// Returns true if a packet from the throttled peer with given address is accepted
+func (policy *ReputationPolicy) throttle(addr string, now time.Time) bool {
+	policy.mutex.Lock()
+	defer policy.mutex.Unlock()
+
+	state, present := policy.throttles[addr]
+	if !present || now.Sub(state.start) >= time.Second {
+		state = &throttleState{start: now}
+		policy.throttles[addr] = state
+	}
+	state.count++
+	return state.count <= policy.config.ThrottleRate
+}
+
+// Records whether given action is enforced against given peer, returns true if this changed
+func (policy *ReputationPolicy) setEnforced(peer string, action PolicyAction, enforced bool) bool {
+	policy.mutex.Lock()
+	defer policy.mutex.Unlock()
+
+	id := string(action) + ":" + peer
+	if policy.enforced[id] == enforced {
+		return false
+	}
+	if enforced {
+		policy.enforced[id] = true
+	} else {
+		delete(policy.enforced, id)
+		if action == POLICY_THROTTLE {
+			delete(policy.throttles, peer)
+		}
+	}
+	return true
+}
+
+// Returns true if the packet from given address is to be processed, quarantining or throttling the peer if needed
+func (g *Gossiper) allowPacket(remoteaddr *net.UDPAddr) bool {
+	config := g.policy.config
+	peer := addrToString(*remoteaddr)
+	now := time.Now()
+
+	g.policy.mutex.Lock()
+	end, inQuarantine := g.policy.quarantine[peer]
+	g.policy.mutex.Unlock()
+
+	if inQuarantine {
+		if now.Before(end) {
+			return false
+		}
+		// the quarantine is over : new chance
+		g.policy.mutex.Lock()
+		delete(g.policy.quarantine, peer)
+		g.policy.mutex.Unlock()
+		g.reputationTable.Rehabilitate(peer, config.QuarantineThreshold)
+		g.enforce(peer, POLICY_QUARANTINE, false)
+	}
+
+	// the signature-based reputation of an address is the one of the identity bound to it
+	if sigRep, present := g.reputationTable.GetSigRep(peer); present && sigRep < config.QuarantineThreshold {
+		g.policy.mutex.Lock()
+		g.policy.quarantine[peer] = now.Add(config.QuarantineTimeout)
+		g.policy.mutex.Unlock()
+		g.enforce(peer, POLICY_QUARANTINE, true)
+		return false
+	}
+
+	contribRep, present := g.reputationTable.GetContribRep(peer)
+	throttled := present && contribRep < config.ThrottleThreshold
+	g.enforce(peer, POLICY_THROTTLE, throttled)
+	if throttled {
+		return g.policy.throttle(peer, now)
+	}
+	return true
+}
+
+// Returns true if the data request of the peer with given name is to be served
+func (g *Gossiper) allowDataRequest(origin string) bool {
+	contribRep, present := g.reputationTable.GetContribRep(origin)
+	refused := present && contribRep < g.policy.config.RefuseDataThreshold
+	g.enforce(origin, POLICY_REFUSE_DATA, refused)
+	return !refused
+}
+
+// Returns true if the key exchange messages signed by the peer with given name are to be processed
+func (g *Gossiper) allowKeyExchange(signer string) bool {
+	sigRep, present := g.reputationTable.GetSigRep(signer)
+	ignored := present && sigRep < g.policy.config.IgnoreKeysThreshold
+	g.enforce(signer, POLICY_IGNORE_KEYS, ignored)
+	return !ignored
+}
+
+// Returns a random peer of given set that is not in quarantine, nil if there is none
+func (g *Gossiper) randomPeer(ps common.PeerSet) *common.Peer {
+	peers := make([]common.Peer, 0)
+	for _, peer := range ps.ToPeerArray() {
+		if !g.policy.quarantined(addrToString(peer.Address)) {
+			peers = append(peers, peer)
+		}
+	}
+	if len(peers) == 0 {
+		return nil
+	}
+	peer := peers[rand.Intn(len(peers))]
+	return &peer
+}
+
+// Records that given action is enforced or lifted against given peer, and notifies the client of the change
+func (g *Gossiper) enforce(peer string, action PolicyAction, enforced bool) {
+	if !g.policy.setEnforced(peer, action, enforced) {
+		return
+	}
+
+	notification := common.PolicyNotification(peer, string(action), !enforced)
+	if g.ClientAddress != nil {
+		g.clientOutputQueue <- &common.Packet{
+			ClientPacket: common.ClientPacket{
+				Notification: notification,
+			},
+			Destination: *g.ClientAddress,
+		}
+	}
+	// print same notification
+	common.Log(*notification, common.LOG_MODE_REACTIVE)
+}
@@ -0,0 +1,181 @@
+// Tests for the enforcement actions against the peers with a low reputation
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/No-Trust/peerster/common"
+	"github.com/No-Trust/peerster/rep"
+)
+
+// Return a Gossiper with a new reputation table and given policy, enough for the policy checks
+func newPolicyGossiper(config PolicyConfig) *Gossiper {
+	peers := common.NewSet(net.UDPAddr{})
+	return &Gossiper{
+		reputationTable: rep.NewReputationTable(&peers, rep.DefaultConfig()),
+		policy:          NewReputationPolicy(config),
+	}
+}
+
+// Lower the signature-based reputation of given peer close to the minimum
+func lowerSigRep(t *testing.T, table *rep.ReputationTable, peer string) {
+	for i := 0; i < 100; i++ {
+		if r, present := table.GetSigRep(peer); present && r < 0.01 {
+			return
+		}
+		table.DecreaseSigRep(peer, 1.0)
+	}
+	t.Fatalf("could not lower the signature-based reputation of %v", peer)
+}
+
+// Lower the contribution-based reputation of given peer close to the minimum, by uploading much data to it
+func lowerContribRep(t *testing.T, table *rep.ReputationTable, peer string) {
+	table.RecordUpload(peer, rep.TRAFFIC_DATA, 1<<24)
+	if r, _ := table.GetContribRep(peer); r >= 0.01 {
+		t.Fatalf("could not lower the contribution-based reputation of %v, got %v", peer, r)
+	}
+}
+
+// Send given number of packets from given address, returns true if all of them are allowed
+func allowPackets(g *Gossiper, peer string, n int) bool {
+	addr := stringToUDPAddr(peer)
+	allowed := true
+	for i := 0; i < n; i++ {
+		allowed = g.allowPacket(&addr) && allowed
+	}
+	return allowed
+}
+
+// TestPolicyActions tests that each action is enforced against the peers under its threshold only, and not when disabled
+func TestPolicyActions(t *testing.T) {
+	cases := []struct {
+		action    PolicyAction
+		config    PolicyConfig
+		good, bad string                                         // peers with the initial and a low reputation
+		lower     func(*testing.T, *rep.ReputationTable, string) // lowers the reputation of the bad peer
+		allow     func(g *Gossiper, peer string) bool
+	}{
+		{
+			action: POLICY_THROTTLE,
+			config: PolicyConfig{ThrottleThreshold: 0.1, ThrottleRate: 2},
+			good:   "1.1.1.1:1",
+			bad:    "6.6.6.6:6",
+			lower:  lowerContribRep,
+			allow:  func(g *Gossiper, peer string) bool { return allowPackets(g, peer, 3) },
+		},
+		{
+			action: POLICY_REFUSE_DATA,
+			config: PolicyConfig{RefuseDataThreshold: 0.05},
+			good:   "alice",
+			bad:    "mallory",
+			lower:  lowerContribRep,
+			allow:  func(g *Gossiper, peer string) bool { return g.allowDataRequest(peer) },
+		},
+		{
+			action: POLICY_IGNORE_KEYS,
+			config: PolicyConfig{IgnoreKeysThreshold: 0.1},
+			good:   "alice",
+			bad:    "mallory",
+			lower:  lowerSigRep,
+			allow:  func(g *Gossiper, peer string) bool { return g.allowKeyExchange(peer) },
+		},
+		{
+			action: POLICY_QUARANTINE,
+			config: PolicyConfig{QuarantineThreshold: 0.05, QuarantineTimeout: time.Minute},
+			good:   "1.1.1.1:1",
+			bad:    "6.6.6.6:6",
+			lower:  lowerSigRep,
+			allow:  func(g *Gossiper, peer string) bool { return allowPackets(g, peer, 1) && !g.policy.quarantined(peer) },
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(string(c.action), func(t *testing.T) {
+			for _, enabled := range []bool{true, false} {
+				config := c.config
+				if !enabled {
+					config = PolicyConfig{ThrottleRate: c.config.ThrottleRate}
+				}
+				g := newPolicyGossiper(config)
+				g.reputationTable.InitSigRepForPeer(c.good)
+				g.reputationTable.InitContribRepForPeer(c.good)
+				c.lower(t, g.reputationTable, c.bad)
+
+				if !c.allow(g, c.good) {
+					t.Fatalf("%v should not be enforced against a peer with the initial reputation", c.action)
+				}
+				if c.allow(g, c.bad) == enabled {
+					t.Fatalf("%v should be enforced against a peer with a low reputation only when enabled", c.action)
+				}
+				if g.policy.enforced[string(c.action)+":"+c.bad] != enabled {
+					t.Fatalf("%v against %v should be recorded only when enabled", c.action, c.bad)
+				}
+			}
+		})
+	}
+}
+
+// TestThrottleRate tests that a throttled peer gets the packets of the rate back every second
+func TestThrottleRate(t *testing.T) {
+	policy := NewReputationPolicy(PolicyConfig{ThrottleThreshold: 0.1, ThrottleRate: 2})
+	now := time.Now()
+
+	for i, expected := range []bool{true, true, false, false} {
+		if policy.throttle("6.6.6.6:6", now) != expected {
+			t.Fatalf("packet %d in the first second should be accepted: %v", i, expected)
+		}
+	}
+	if !policy.throttle("6.6.6.6:6", now.Add(time.Second)) {
+		t.Fatalf("packet of the next second should be accepted")
+	}
+}
+
+// TestQuarantineTimeout tests that a quarantined peer is never chosen, and is rehabilitated at the end of its quarantine
+func TestQuarantineTimeout(t *testing.T) {
+	threshold := float32(0.05)
+	g := newPolicyGossiper(PolicyConfig{QuarantineThreshold: threshold, QuarantineTimeout: time.Minute})
+	bad := "6.6.6.6:6"
+	peers := common.NewSetFromAddrs([]net.UDPAddr{stringToUDPAddr(bad)}, net.UDPAddr{})
+	lowerSigRep(t, g.reputationTable, bad)
+	lowerContribRep(t, g.reputationTable, bad)
+
+	if allowPackets(g, bad, 1) {
+		t.Fatalf("packets of a peer under the quarantine threshold should be dropped")
+	}
+	if g.randomPeer(peers) != nil {
+		t.Fatalf("a quarantined peer should not be chosen")
+	}
+
+	// before the end of the quarantine, the packets are dropped even if the reputation is raised
+	g.reputationTable.IncreaseSigRep(bad, 1.0)
+	if allowPackets(g, bad, 1) {
+		t.Fatalf("packets of a quarantined peer should be dropped until the end of its quarantine")
+	}
+
+	// end the quarantine
+	lowerSigRep(t, g.reputationTable, bad)
+	g.policy.quarantine[bad] = time.Now().Add(-time.Second)
+
+	if !allowPackets(g, bad, 1) {
+		t.Fatalf("packets of a peer should be accepted at the end of its quarantine")
+	}
+	sigRep, _ := g.reputationTable.GetSigRep(bad)
+	contribRep, _ := g.reputationTable.GetContribRep(bad)
+	if sigRep != threshold || contribRep != threshold {
+		t.Fatalf("reputations should be raised to the quarantine threshold, got %v and %v", sigRep, contribRep)
+	}
+	if g.policy.enforced[string(POLICY_QUARANTINE)+":"+bad] {
+		t.Fatalf("the quarantine should be lifted")
+	}
+	if peer := g.randomPeer(peers); peer == nil || addrToString(peer.Address) != bad {
+		t.Fatalf("a peer should be chosen again at the end of its quarantine, got %v", peer)
+	}
+
+	// a new invalid signature puts the peer back in quarantine
+	g.reputationTable.DecreaseSigRep(bad, 1.0)
+	if allowPackets(g, bad, 1) || !g.policy.quarantined(bad) {
+		t.Fatalf("a rehabilitated peer under the threshold again should be quarantined again")
+	}
+}
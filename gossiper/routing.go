@@ -97,7 +97,7 @@ func routerumor(g *Gossiper, rtimer uint) {
 	defer ticker.Stop()
 
 	for range ticker.C {
-		A := g.peerSet.RandomPeer()
+		A := g.randomPeer(g.peerSet)
 		if A != nil {
 
 			nextSeq := g.vectorClock.Get(g.Parameters.Identifier)
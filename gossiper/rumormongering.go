@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/No-Trust/peerster/common"
+	"github.com/No-Trust/peerster/rep"
 )
 
 // Implementation of the rumormongering algorithm.
@@ -20,9 +21,6 @@ func (g *Gossiper) rumormonger(rumor *RumorMessage, destPeer *common.Peer) {
 		Destination: destPeer.Address,
 	}
 
-	// Decrease contribution-based reputation of receiver
-	g.reputationTable.DecreaseContribRep(addrToString(destPeer.Address))
-
 	// and wait for status message
 	statusChannel := make(chan *PeerStatus)
 	// format : id/ip:port/nextID
@@ -62,7 +60,7 @@ func (g *Gossiper) rumormonger(rumor *RumorMessage, destPeer *common.Peer) {
 			delete(g.gossiperWaiters, ackID)
 			g.waitersMutex.Unlock()
 
-			randPeer := g.reputationTable.ContribRandomPeer()
+			randPeer := g.reputationTable.RandomPeer(rep.PURPOSE_RELAY, g.policy.quarantined)
 
 			if randPeer != "" {
 				go g.rumormonger(rumor, &common.Peer{
@@ -85,7 +83,7 @@ func (g *Gossiper) rumormonger(rumor *RumorMessage, destPeer *common.Peer) {
 			if flipCoin() {
 				common.Log(*CoinFlipString(&destPeer.Address), common.LOG_MODE_FULL)
 
-				randPeer := g.reputationTable.ContribRandomPeer()
+				randPeer := g.reputationTable.RandomPeer(rep.PURPOSE_RELAY, g.policy.quarantined)
 
 				if randPeer != "" {
 					go g.rumormonger(rumor, &common.Peer{
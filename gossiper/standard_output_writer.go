@@ -6,6 +6,10 @@ import (
 	"fmt"
 	"net"
 	"strconv"
+	"strings"
+
+	"github.com/No-Trust/peerster/awot"
+	"github.com/No-Trust/peerster/rep"
 )
 
 // Strings for messages
@@ -37,6 +41,10 @@ func (msg *RumorMessage) MongeringString(dest net.UDPAddr) *string {
 		rumorType = "ROUTE"
 	} else if msg.isKeyExchange() == true {
 		rumorType = "KEY RECORD"
+	} else if msg.isKeyRevocation() == true {
+		rumorType = "KEY REVOCATION"
+	} else if msg.isKeyTransition() == true {
+		rumorType = "KEY TRANSITION"
 	}
 	str := fmt.Sprintf("MONGERING %s with %s:%s", rumorType, dest.IP.String(), strconv.Itoa(dest.Port))
 	return &str
@@ -160,3 +168,87 @@ func KeyExchangeReceiveString(owner string, from net.UDPAddr, valid bool) string
 func KeyExchangeReceiveUnverifiedString(owner, signer string, from net.UDPAddr) string {
 	return fmt.Sprintf("KEY EXCHANGE MESSAGE RECEIVED owner %s signed by %s from %s:%s UNVERIFIED", owner, signer, from.IP.String(), strconv.Itoa(from.Port))
 }
+
+func KeyRequestSendString(owner string, dest net.UDPAddr) string {
+	return fmt.Sprintf("KEY REQUEST SENT owner %s to %s:%s", owner, dest.IP.String(), strconv.Itoa(dest.Port))
+}
+
+func KeyRequestReceiveString(owner, origin string, from net.UDPAddr) string {
+	return fmt.Sprintf("KEY REQUEST RECEIVED owner %s origin %s from %s:%s", owner, origin, from.IP.String(), strconv.Itoa(from.Port))
+}
+
+func KeyReplyReceiveString(owner, origin string, signatures int) string {
+	return fmt.Sprintf("KEY REPLY RECEIVED owner %s from %s with %d signatures", owner, origin, signatures)
+}
+
+func KeyLookupStartString(owner string) string {
+	return fmt.Sprintf("KEY LOOKUP for %s STARTED", owner)
+}
+
+func KeyExplanationString(explanation awot.KeyExplanation) string {
+	str := fmt.Sprintf("key %s confidence %.3f", explanation.Fingerprint, explanation.Confidence)
+	if explanation.ErrorBound > 0 {
+		str += fmt.Sprintf(" (+/- %.3f)", explanation.ErrorBound)
+	}
+	str += fmt.Sprintf(" by %s, metric %s", explanation.Method, explanation.Metric)
+
+	for i, path := range explanation.Paths {
+		nodes := make([]string, len(path.Nodes))
+		for j, node := range path.Nodes {
+			nodes[j] = fmt.Sprintf("%s (d=%d p=%.3f rep=%.3f : %s)", node.Name, node.Distance, node.Probability, node.Reputation, node.Reason)
+		}
+		str += fmt.Sprintf("\npath %d : %s -> %s = %.3f", i+1, strings.Join(nodes, " -> "), explanation.Owner, path.Probability)
+	}
+	for _, key := range explanation.Keys {
+		str += fmt.Sprintf("\nkey %s signed by %s : %d paths confidence %.3f", key.Fingerprint, strings.Join(key.Signers, ","), key.Paths, key.Confidence)
+		if key.Selected {
+			str += " SELECTED"
+		}
+	}
+	for _, term := range explanation.Terms {
+		str += fmt.Sprintf("\nterm %d paths : %d combinations = %+.3f", term.Size, term.Sets, term.Value)
+	}
+	return str
+}
+
+func RepHistoryString(events []rep.HistoryEvent) string {
+	lines := make([]string, len(events))
+	for i, event := range events {
+		lines[i] = fmt.Sprintf("%s %s %.3f -> %.3f : %s", event.Time.Format("2006-01-02 15:04:05"), event.Kind, event.Before, event.After, event.Cause)
+		if event.Source != "" {
+			lines[i] += " from " + event.Source
+		}
+		if event.Confidence != 0 {
+			lines[i] += fmt.Sprintf(" (%.3f)", event.Confidence)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func KeyRevocationSignString(owner string, sig []byte) string {
+	return fmt.Sprintf("SIGNING REVOCATION for %s with sig : \n%s", owner, hex.EncodeToString(sig))
+}
+
+func KeyRevocationReceiveString(owner, signer string, from net.UDPAddr, err error) string {
+	str := fmt.Sprintf("KEY REVOCATION MESSAGE RECEIVED owner %s signed by %s from %s:%s", owner, signer, from.IP.String(), strconv.Itoa(from.Port))
+	if err == nil {
+		str += " VALID"
+	} else {
+		str += fmt.Sprintf(" REJECTED : %v", err)
+	}
+	return str
+}
+
+func KeyTransitionReceiveString(owner string, from net.UDPAddr, err error) string {
+	str := fmt.Sprintf("KEY TRANSITION MESSAGE RECEIVED owner %s from %s:%s", owner, from.IP.String(), strconv.Itoa(from.Port))
+	if err == nil {
+		str += " VALID"
+	} else {
+		str += fmt.Sprintf(" REJECTED : %v", err)
+	}
+	return str
+}
+
+func UploadChokedString(requester string, balance float64) string {
+	return fmt.Sprintf("UPLOAD CHOKED for %s with balance %.0f : no free upload slot", requester, balance)
+}
@@ -0,0 +1,80 @@
+// Persistence of the manual trust decisions taken on the key ring
+package main
+
+import (
+	"crypto/rsa"
+	"os"
+
+	"github.com/No-Trust/peerster/awot"
+	"github.com/No-Trust/peerster/common"
+)
+
+// Load the manual trust decisions stored in disk, if any, and apply them to the key ring
+func (g *Gossiper) loadTrustDecisions() {
+	filename := g.Parameters.TrustFileName
+	if _, err := os.Stat(filename); err != nil {
+		// no decision taken yet
+		return
+	}
+
+	var decisions awot.TrustDecisions
+	err := loadGob(filename, &decisions)
+	if err != nil {
+		// decisions saved before keys could be of several algorithms
+		decisions, err = loadLegacyTrustDecisions(filename)
+	}
+	if common.CheckRead(err) {
+		return
+	}
+	g.keyRing.RestoreTrustDecisions(decisions)
+
+	// save back the fingerprints migrated from the older MD5 format
+	g.saveTrustDecisions()
+}
+
+// legacyTrustDecisions are the manual trust decisions as saved when only RSA keys were supported
+type legacyTrustDecisions struct {
+	Imported map[string]struct {
+		KeyRecord struct {
+			Owner  string
+			KeyPub rsa.PublicKey
+		}
+		Confidence      float32
+		ConfidenceError float32
+	}
+	Distrusted map[string]string
+	Pinned     map[string]string
+}
+
+// Load manual trust decisions saved in the legacy format and convert them
+func loadLegacyTrustDecisions(filename string) (awot.TrustDecisions, error) {
+	var legacy legacyTrustDecisions
+	err := loadGob(filename, &legacy)
+	if err != nil {
+		return awot.TrustDecisions{}, err
+	}
+
+	decisions := awot.TrustDecisions{
+		Imported:   make(map[string]awot.TrustedKeyRecord),
+		Distrusted: legacy.Distrusted,
+		Pinned:     legacy.Pinned,
+	}
+	for owner, rec := range legacy.Imported {
+		key := rec.KeyRecord.KeyPub
+		decisions.Imported[owner] = awot.TrustedKeyRecord{
+			KeyRecord: awot.KeyRecord{
+				Owner:  rec.KeyRecord.Owner,
+				KeyPub: &key,
+			},
+			Confidence:      rec.Confidence,
+			ConfidenceError: rec.ConfidenceError,
+		}
+	}
+	return decisions, nil
+}
+
+// Save the manual trust decisions of the key ring to disk
+func (g *Gossiper) saveTrustDecisions() {
+	err := saveGob(g.Parameters.TrustFileName, g.keyRing.TrustDecisions())
+	common.CheckRead(err)
+}
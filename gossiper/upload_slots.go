@@ -0,0 +1,88 @@
+// Upload slots : the peers served with data at the same time, BitTorrent style
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/No-Trust/peerster/rep"
+)
+
+// Time after which a peer that does not request data anymore releases its upload slot
+const UPLOAD_SLOT_IDLE = 5 * time.Second
+
+// UploadSlots holds the peers currently served with data
+type UploadSlots struct {
+	max     int                  // number of slots, 0 for no limit
+	holders map[string]time.Time // peer name -> time of its last request served
+	mutex   *sync.Mutex
+}
+
+// Create UploadSlots with given number of slots, 0 for no limit
+func NewUploadSlots(max uint) *UploadSlots {
+	return &UploadSlots{
+		max:     int(max),
+		holders: make(map[string]time.Time),
+		mutex:   &sync.Mutex{},
+	}
+}
+
+// Returns true if the data request of given peer can be served.
+// A peer holding a slot keeps it while it requests data. A free slot goes to any peer, so that new peers can bootstrap,
+// and when all slots are taken, a peer with a positive priority takes the slot of the holder with the lowest priority, if lower.
+func (slots *UploadSlots) acquire(peer string, priority func(string) float64) bool {
+	slots.mutex.Lock()
+	defer slots.mutex.Unlock()
+
+	if slots.max <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	for holder, last := range slots.holders {
+		if now.Sub(last) > UPLOAD_SLOT_IDLE {
+			delete(slots.holders, holder)
+		}
+	}
+
+	if _, present := slots.holders[peer]; present || len(slots.holders) < slots.max {
+		slots.holders[peer] = now
+		return true
+	}
+
+	peerPriority := priority(peer)
+	if peerPriority <= 0 {
+		return false
+	}
+
+	// choke the holder with the lowest priority
+	lowest := ""
+	var lowestPriority float64
+	for holder := range slots.holders {
+		if p := priority(holder); lowest == "" || p < lowestPriority {
+			lowest, lowestPriority = holder, p
+		}
+	}
+	if peerPriority <= lowestPriority {
+		return false
+	}
+
+	delete(slots.holders, lowest)
+	slots.holders[peer] = now
+	return true
+}
+
+// Returns the priority of given peer for the upload slots : its upload score, positive if above the initial reputation.
+// The ledger accounts the bytes to the neighbours they are exchanged with, the bytes of a peer several hops away being
+// accounted to the relays. So only the peers whose address is bound to their identity, the direct neighbours with a
+// trusted key, are preferred. The others have a neutral priority : they take the free slots but never choke a holder.
+func (g *Gossiper) uploadPriority(peer string) float64 {
+	if _, bound := g.reputationTable.BoundAddress(peer); !bound {
+		return 0
+	}
+	score, present := g.reputationTable.Score(rep.PURPOSE_UPLOAD, peer)
+	if !present {
+		return 0
+	}
+	return float64(score - rep.INIT_REP)
+}
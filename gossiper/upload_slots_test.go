@@ -0,0 +1,98 @@
+// Tests for the upload slots
+package main
+
+import (
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/No-Trust/peerster/awot"
+	"github.com/No-Trust/peerster/common"
+	"github.com/No-Trust/peerster/rep"
+)
+
+// TestUploadSlotsAcquire tests which peer gets a slot, depending on the holders and the priorities
+func TestUploadSlotsAcquire(t *testing.T) {
+	priorities := map[string]float64{"good": 0.3, "better": 0.4, "best": 0.5, "neutral": 0, "bad": -0.2}
+	priority := func(peer string) float64 { return priorities[peer] }
+
+	cases := []struct {
+		name     string
+		max      uint
+		holders  map[string]time.Duration // holder -> time since its last request
+		peer     string
+		acquired bool
+		after    []string // holders after the request
+	}{
+		{"no limit", 0, nil, "bad", true, nil},
+		{"free slot to any peer", 2, map[string]time.Duration{"good": 0}, "bad", true, []string{"bad", "good"}},
+		{"holder keeps its slot", 1, map[string]time.Duration{"bad": 0}, "bad", true, []string{"bad"}},
+		{"neutral peer never chokes", 1, map[string]time.Duration{"bad": 0}, "neutral", false, []string{"bad"}},
+		{"higher priority chokes the lowest holder", 2, map[string]time.Duration{"good": 0, "best": 0}, "better", true, []string{"best", "better"}},
+		{"lower priority than all holders", 2, map[string]time.Duration{"better": 0, "best": 0}, "good", false, []string{"best", "better"}},
+		{"idle holder releases its slot", 1, map[string]time.Duration{"best": 2 * UPLOAD_SLOT_IDLE}, "bad", true, []string{"bad"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			slots := NewUploadSlots(c.max)
+			for holder, age := range c.holders {
+				slots.holders[holder] = time.Now().Add(-age)
+			}
+
+			if acquired := slots.acquire(c.peer, priority); acquired != c.acquired {
+				t.Fatalf("%v should acquire a slot: %v, got %v", c.peer, c.acquired, acquired)
+			}
+
+			holders := make([]string, 0)
+			for holder := range slots.holders {
+				holders = append(holders, holder)
+			}
+			sort.Strings(holders)
+			if c.after == nil {
+				c.after = []string{}
+			}
+			if !reflect.DeepEqual(holders, c.after) {
+				t.Fatalf("holders should be %v, got %v", c.after, holders)
+			}
+		})
+	}
+}
+
+// TestUploadPriority tests that only the peers bound to their address have a priority from their upload score
+func TestUploadPriority(t *testing.T) {
+	peers := common.NewSet(net.UDPAddr{})
+	g := &Gossiper{reputationTable: rep.NewReputationTable(&peers, rep.DefaultConfig())}
+	key, err := awot.GenerateKey(awot.KeyAlgorithmEd25519, 0)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+
+	// a peer several hops away, whose traffic is accounted to the relays
+	g.reputationTable.RecordDownload("far", rep.TRAFFIC_DATA, 1<<20)
+	if p := g.uploadPriority("far"); p != 0 {
+		t.Fatalf("priority of a peer without binding should be neutral, got %v", p)
+	}
+
+	// a neighbour proving its key at its address
+	addr := "1.1.1.1:1"
+	nonce, _ := g.reputationTable.Challenge(addr)
+	signature, err := rep.SignBinding("alice", addr, nonce, key)
+	if err != nil {
+		t.Fatalf("could not sign binding: %v", err)
+	}
+	if err = g.reputationTable.Bind(addr, "alice", key.Public(), signature); err != nil {
+		t.Fatalf("could not bind alice: %v", err)
+	}
+	g.reputationTable.RecordDownload(addr, rep.TRAFFIC_DATA, 1<<20)
+	if p := g.uploadPriority("alice"); p <= 0 {
+		t.Fatalf("priority of a bound peer giving data should be positive, got %v", p)
+	}
+
+	g.reputationTable.RecordUpload(addr, rep.TRAFFIC_DATA, 1<<24)
+	if p := g.uploadPriority("alice"); p >= 0 {
+		t.Fatalf("priority of a bound peer taking data should be negative, got %v", p)
+	}
+}
@@ -62,7 +62,10 @@ func writer(g *Gossiper, udpConn net.UDPConn, queue chan *Packet) {
 		buf, err := protobuf.Encode(&gossipPacket)
 		common.CheckRead(err)
 		_, err = udpConn.WriteToUDP(buf, &destination)
-		common.CheckRead(err)
+		if !common.CheckRead(err) {
+			// account the bytes sent to the peer
+			g.reputationTable.RecordUpload(addrToString(destination), gossipPacket.trafficClass(), len(buf))
+		}
 	}
 }
 
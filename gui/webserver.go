@@ -28,6 +28,12 @@ var ids []string
 var reputations common.RepUpdate
 var repMutex = &sync.Mutex{}
 var KeyRingJSON []byte
+var pendingKeys common.PendingKeyStats
+var pendingMutex = &sync.Mutex{}
+var keyExplanations = make(map[string][]byte)
+var explanationsMutex = &sync.Mutex{}
+var repHistories = make(map[string][]byte)
+var historiesMutex = &sync.Mutex{}
 
 type WebMessage struct {
 	Message     string
@@ -36,6 +42,7 @@ type WebMessage struct {
 	Hexhash     string
 	Node        string
 	Origin      string
+	SAS         string
 }
 
 func parse(req *http.Request) *WebMessage {
@@ -96,6 +103,9 @@ func main() {
 	r.HandleFunc("/node", addNodeHandler).Methods("POST")          // client add node
 	r.HandleFunc("/file", newFileHandler).Methods("POST")          // client adds a file
 	r.HandleFunc("/download", downloadFileHandler).Methods("POST") // client request to download a file
+	r.HandleFunc("/verify", verifyKeyHandler).Methods("POST")      // client confirms the key of a node
+	r.HandleFunc("/explain", explainKeyHandler).Methods("POST")    // client asks why a key has its confidence
+	r.HandleFunc("/history", repHistoryHandler).Methods("POST")    // client asks for the reputation history of a node
 
 	r.HandleFunc("/message", getMessagesHandler).Methods("GET")                // request new messages
 	r.HandleFunc("/private-message", getPrivateMessagesHandler).Methods("GET") // request new private messages
@@ -104,6 +114,10 @@ func main() {
 	r.HandleFunc("/keyring", getRingHandler).Methods("GET")                    // request ring
 	r.HandleFunc("/ring.json", getRingJSONHandler).Methods("GET")              // request ring json
 	r.HandleFunc("/reputations", getReputationsHandler).Methods("GET")         // request update on reputations
+	r.HandleFunc("/pending", getPendingKeysHandler).Methods("GET")             // request statistics on pending key signatures
+	r.HandleFunc("/explain/{name}", getKeyExplanationHandler).Methods("GET")   // request the explanation of a key confidence
+	r.HandleFunc("/timeline", getTimelineHandler).Methods("GET")               // request reputation timeline
+	r.HandleFunc("/history/{peer}", getRepHistoryHandler).Methods("GET")       // request the reputation history of a node
 
 	http.Handle("/", r)
 
@@ -170,6 +184,34 @@ func handleServerMessage(buf []byte, remoteaddr *net.UDPAddr) {
 		reputations = *pkt.Reputations
 		repMutex.Unlock()
 	}
+	if pkt.PendingKeys != nil {
+		// Update statistics on pending key signatures
+		pendingMutex.Lock()
+		pendingKeys = *pkt.PendingKeys
+		pendingMutex.Unlock()
+	}
+	if pkt.KeyExplanation != nil {
+		// save the explanation of the key of its owner
+		var explanation struct {
+			Owner string
+		}
+		if err := json.Unmarshal(*pkt.KeyExplanation, &explanation); err == nil {
+			explanationsMutex.Lock()
+			keyExplanations[explanation.Owner] = *pkt.KeyExplanation
+			explanationsMutex.Unlock()
+		}
+	}
+	if pkt.RepHistoryJSON != nil {
+		// save the reputation history of its peer
+		var history struct {
+			Peer string
+		}
+		if err := json.Unmarshal(*pkt.RepHistoryJSON, &history); err == nil {
+			historiesMutex.Lock()
+			repHistories[history.Peer] = *pkt.RepHistoryJSON
+			historiesMutex.Unlock()
+		}
+	}
 
 }
 
@@ -227,6 +269,54 @@ func getReputationsHandler(w http.ResponseWriter, r *http.Request) {
 
 }
 
+func getPendingKeysHandler(w http.ResponseWriter, r *http.Request) {
+
+	pendingMutex.Lock()
+
+	buf, err := json.Marshal(pendingKeys)
+	common.CheckError(err)
+
+	pendingMutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf)
+
+}
+
+func getKeyExplanationHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	explanationsMutex.Lock()
+	buf, present := keyExplanations[name]
+	explanationsMutex.Unlock()
+
+	if !present {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf)
+}
+
+func getRepHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	peer := mux.Vars(r)["peer"]
+
+	historiesMutex.Lock()
+	buf, present := repHistories[peer]
+	historiesMutex.Unlock()
+
+	if !present {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf)
+}
+
+func getTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	http.ServeFile(w, r, "public/history.html")
+}
+
 func getRingHandler(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, "public/ring.html")
 }
@@ -314,6 +404,51 @@ func addNodeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func verifyKeyHandler(w http.ResponseWriter, r *http.Request) {
+	webm := parse(r)
+	if webm == nil {
+		return
+	}
+
+	fmt.Printf("*** KEY CONFIRMATION of %s with %s\n", webm.Node, webm.SAS)
+
+	// sending
+	outputQueue <- &common.ClientPacket{
+		VerifyKey: &common.KeyVerification{
+			Owner: webm.Node,
+			SAS:   webm.SAS,
+		},
+	}
+}
+
+func explainKeyHandler(w http.ResponseWriter, r *http.Request) {
+	webm := parse(r)
+	if webm == nil {
+		return
+	}
+
+	fmt.Printf("*** KEY EXPLANATION of %s\n", webm.Node)
+
+	// sending
+	outputQueue <- &common.ClientPacket{
+		ExplainKey: &webm.Node,
+	}
+}
+
+func repHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	webm := parse(r)
+	if webm == nil {
+		return
+	}
+
+	fmt.Printf("*** REPUTATION HISTORY of %s\n", webm.Node)
+
+	// sending
+	outputQueue <- &common.ClientPacket{
+		RepHistory: &webm.Node,
+	}
+}
+
 func sendMessageHandler(w http.ResponseWriter, r *http.Request) {
 	webm := parse(r)
 	if webm == nil {
@@ -0,0 +1,115 @@
+package rep
+
+/*
+   Imports
+*/
+
+import (
+	"errors"
+	"time"
+)
+
+/*
+   Type definitions
+*/
+
+/**
+ * The parameters of the reputation system, set when
+ * creating a reputation table.
+ */
+type Config struct {
+	SigIncreaseLimit         float32             // increase of a sig-based reputation for a correct signature, times the confidence in the key
+	SigDecreaseLimit         float32             // decrease factor of a sig-based reputation for a wrong signature, times the confidence in the key
+	RumorWeight              float32             // weight of the rumor bytes in the contribution ledger
+	DataWeight               float32             // weight of the data bytes in the contribution ledger
+	ControlWeight            float32             // weight of the control bytes in the contribution ledger
+	LedgerPrior              uint64              // weighted bytes counted as both uploaded and downloaded in the ledger ratios
+	SigHalfLife              time.Duration       // time after which a sig-based reputation is halfway back to INIT_REP, 0 for no decay
+	ContribHalfLife          time.Duration       // time after which a contrib-based reputation is halfway back to INIT_REP, 0 for no decay
+	RequestPeerCount         uint                // number of most reputable peers asked for reputation updates
+	UpdateWeightLimit        float32             // weight of an update from a peer with the maximum reputation
+	UpdaterDecreaseLimit     float32             // decrease factor of the reputation of an updater, times the distance of its update
+	UpdateMaxAge             time.Duration       // age from which a reputation update is stale
+	UpdateMaxSkew            time.Duration       // time by which a reputation update may be ahead of the local clock
+	GlobalTrust              bool                // if set, reputations are EigenTrust-like global reputations, see ComputeGlobalTrust
+	GlobalTrustPreTrust      float32             // weight of the local reputations in the global ones
+	GlobalTrustEpsilon       float32             // distance between two iterations under which the global reputations are converged
+	GlobalTrustMaxIterations int                 // maximum number of iterations of the global reputations
+	Weights                  map[Purpose]Weights // weights of the reputation scores of each purpose, see Score
+	HistoryMaxEvents         int                 // number of events kept in the reputation history of a peer, 0 for no history
+	HistoryMaxAge            time.Duration       // age from which an event of the reputation history is dropped, 0 for no limit
+}
+
+/*
+   Functions
+*/
+
+/**
+ * Returns the default parameters of the reputation system.
+ */
+func DefaultConfig() Config {
+
+	return Config{
+		SigIncreaseLimit:         SIG_INCREASE_LIMIT,
+		SigDecreaseLimit:         SIG_DECREASE_LIMIT,
+		RumorWeight:              LEDGER_RUMOR_WEIGHT,
+		DataWeight:               LEDGER_DATA_WEIGHT,
+		ControlWeight:            LEDGER_CONTROL_WEIGHT,
+		LedgerPrior:              LEDGER_PRIOR,
+		SigHalfLife:              REP_HALF_LIFE,
+		ContribHalfLife:          REP_HALF_LIFE,
+		RequestPeerCount:         REP_REQ_PEER_COUNT,
+		UpdateWeightLimit:        UPDATE_WEIGHT_LIMIT,
+		UpdaterDecreaseLimit:     UPDATER_DECREASE_LIMIT,
+		UpdateMaxAge:             REP_UPDATE_MAX_AGE,
+		UpdateMaxSkew:            REP_UPDATE_MAX_SKEW,
+		GlobalTrust:              false,
+		GlobalTrustPreTrust:      GLOBAL_TRUST_PRETRUST_WEIGHT,
+		GlobalTrustEpsilon:       GLOBAL_TRUST_EPSILON,
+		GlobalTrustMaxIterations: GLOBAL_TRUST_MAX_ITERATIONS,
+		Weights:                  DefaultWeights(),
+		HistoryMaxEvents:         HISTORY_MAX_EVENTS,
+		HistoryMaxAge:            HISTORY_MAX_AGE,
+	}
+
+}
+
+/**
+ * Returns an error if a parameter is out of its range.
+ */
+func (config Config) Validate() error {
+
+	unit := func(value float32) bool {
+		return value >= 0 && value <= 1
+	}
+
+	switch {
+	case !unit(config.SigIncreaseLimit) || !unit(config.SigDecreaseLimit):
+		return errors.New("signature-based reputation limits must be between 0 and 1")
+	case config.RumorWeight < 0 || config.DataWeight < 0 || config.ControlWeight < 0:
+		return errors.New("contribution ledger weights must not be negative")
+	case config.SigHalfLife < 0 || config.ContribHalfLife < 0:
+		return errors.New("reputation half-life must not be negative")
+	case !unit(config.UpdateWeightLimit) || !unit(config.UpdaterDecreaseLimit):
+		return errors.New("reputation update limits must be between 0 and 1")
+	case config.UpdateMaxAge <= 0:
+		return errors.New("reputation update maximum age must be positive")
+	case config.UpdateMaxSkew < 0:
+		return errors.New("reputation update maximum skew must not be negative")
+	case !unit(config.GlobalTrustPreTrust):
+		return errors.New("global reputation pre-trust weight must be between 0 and 1")
+	case config.GlobalTrustEpsilon <= 0 || config.GlobalTrustMaxIterations <= 0:
+		return errors.New("global reputation epsilon and maximum iterations must be positive")
+	case config.HistoryMaxEvents < 0 || config.HistoryMaxAge < 0:
+		return errors.New("reputation history retention must not be negative")
+	}
+
+	for purpose, weights := range config.Weights {
+		if err := weights.validate(); err != nil {
+			return errors.New(string(purpose) + " : " + err.Error())
+		}
+	}
+
+	return nil
+
+}
@@ -0,0 +1,54 @@
+package rep
+
+/*
+   Imports
+*/
+
+import (
+	"testing"
+	"time"
+)
+
+/*
+   Functions
+*/
+
+// TestConfigValidate tests that the parameters out of their range are rejected
+func TestConfigValidate(t *testing.T) {
+	cases := []struct {
+		name   string
+		change func(config *Config)
+		valid  bool
+	}{
+		{name: "default", change: func(config *Config) {}, valid: true},
+		{name: "no decay", change: func(config *Config) { config.SigHalfLife, config.ContribHalfLife = 0, 0 }, valid: true},
+		{name: "no history", change: func(config *Config) { config.HistoryMaxEvents, config.HistoryMaxAge = 0, 0 }, valid: true},
+		{name: "sig increase above 1", change: func(config *Config) { config.SigIncreaseLimit = 1.5 }},
+		{name: "negative sig decrease", change: func(config *Config) { config.SigDecreaseLimit = -0.1 }},
+		{name: "negative ledger weight", change: func(config *Config) { config.ControlWeight = -1 }},
+		{name: "negative half-life", change: func(config *Config) { config.ContribHalfLife = -time.Second }},
+		{name: "update weight above 1", change: func(config *Config) { config.UpdateWeightLimit = 2 }},
+		{name: "negative updater decrease", change: func(config *Config) { config.UpdaterDecreaseLimit = -1 }},
+		{name: "no update skew", change: func(config *Config) { config.UpdateMaxSkew = 0 }, valid: true},
+		{name: "null update age", change: func(config *Config) { config.UpdateMaxAge = 0 }},
+		{name: "negative update age", change: func(config *Config) { config.UpdateMaxAge = -time.Minute }},
+		{name: "negative update skew", change: func(config *Config) { config.UpdateMaxSkew = -time.Second }},
+		{name: "pre-trust above 1", change: func(config *Config) { config.GlobalTrustPreTrust = 1.1 }},
+		{name: "null epsilon", change: func(config *Config) { config.GlobalTrustEpsilon = 0 }},
+		{name: "negative epsilon", change: func(config *Config) { config.GlobalTrustEpsilon = -0.01 }},
+		{name: "null iterations", change: func(config *Config) { config.GlobalTrustMaxIterations = 0 }},
+		{name: "negative iterations", change: func(config *Config) { config.GlobalTrustMaxIterations = -1 }},
+		{name: "negative history events", change: func(config *Config) { config.HistoryMaxEvents = -1 }},
+		{name: "negative history age", change: func(config *Config) { config.HistoryMaxAge = -time.Hour }},
+		{name: "negative purpose weight", change: func(config *Config) { config.Weights[PURPOSE_UPLOAD] = Weights{Ledger: -1} }},
+		{name: "null purpose weights", change: func(config *Config) { config.Weights[PURPOSE_RELAY] = Weights{} }},
+	}
+
+	for _, c := range cases {
+		config := DefaultConfig()
+		c.change(&config)
+		if err := config.Validate(); (err == nil) != c.valid {
+			t.Fatalf("config %v should be valid : %v, got %v", c.name, c.valid, err)
+		}
+	}
+}
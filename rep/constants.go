@@ -1,5 +1,7 @@
 package rep
 
+import "time"
+
 /*
    Constatns
 */
@@ -11,13 +13,24 @@ const INIT_REP float32 = 0.5
 
 const REP_RANGE float32 = MAX_REP - MIN_REP
 
+// Identities
+const IDENTITY_SEPARATOR string = "#"
+const BINDING_NONCE_SIZE int = 32
+const BINDING_CHALLENGE_TIMEOUT time.Duration = 30 * time.Second
+
+// Default values of the Config
 // Signature-based reputation
 const SIG_INCREASE_LIMIT float32 = 0.1
 const SIG_DECREASE_LIMIT float32 = 0.8
 
 // Contribution-based reputation
-const CONTRIB_ALPHA float32 = 0.4
-const CONTRIB_ONE_MINUS_ALPHA float32 = 1 - CONTRIB_ALPHA
+const LEDGER_RUMOR_WEIGHT float32 = 1
+const LEDGER_DATA_WEIGHT float32 = 1
+const LEDGER_CONTROL_WEIGHT float32 = 0
+const LEDGER_PRIOR uint64 = 8000
+
+// Decay of the reputations toward INIT_REP
+const REP_HALF_LIFE time.Duration = time.Hour
 
 // Reputation update requests
 const DEFAULT_REP_REQ_TIMER uint = 8
@@ -26,3 +39,17 @@ const REP_REQ_PEER_COUNT uint = 3
 const UPDATE_WEIGHT_LIMIT float32 = 0.15
 
 const UPDATER_DECREASE_LIMIT float32 = 0.25
+
+// Global reputation
+const GLOBAL_TRUST_PRETRUST_WEIGHT float32 = 0.15
+const GLOBAL_TRUST_EPSILON float32 = 0.0001
+const GLOBAL_TRUST_MAX_ITERATIONS int = 50
+
+// Reputation history
+const HISTORY_MAX_EVENTS int = 256
+const HISTORY_MAX_AGE time.Duration = 24 * time.Hour
+const HISTORY_TRAFFIC_STEP float32 = 0.01
+
+// Signed reputation updates
+const REP_UPDATE_MAX_AGE time.Duration = time.Minute
+const REP_UPDATE_MAX_SKEW time.Duration = 10 * time.Second
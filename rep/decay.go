@@ -0,0 +1,104 @@
+package rep
+
+/*
+   Imports
+*/
+
+import (
+	"math"
+	"time"
+)
+
+/*
+   Functions
+*/
+
+/**
+ * Moves every reputation toward INIT_REP, by the fraction of
+ * its distance to INIT_REP that is forgotten since the last
+ * decay given the half-life of its kind (see Config). A peer
+ * that misbehaved, or behaved well, and then went silent thus
+ * gets back to the initial reputation.
+ */
+func (table *ReputationTable) Decay() {
+
+	now := time.Now()
+
+	table.mutex.Lock()
+
+	elapsed := now.Sub(table.lastDecay)
+	table.lastDecay = now
+
+	table.decayReps(SIG_REP, decayFactor(elapsed, table.config.SigHalfLife))
+	table.decayReps(CONTRIB_REP, decayFactor(elapsed, table.config.ContribHalfLife))
+
+	// The decay keeps the order of the reputations, but
+	// for the ties that the rounding may introduce
+	table.sigIndex.rebuild()
+	table.contribIndex.rebuild()
+
+	table.mutex.Unlock()
+
+}
+
+/**
+ * Returns the time of the last signature-based interaction
+ * and of the last contribution-based interaction with the
+ * given peer, zero if there was none.
+ */
+func (table *ReputationTable) LastInteraction(peer string) ( /*sig*/ time.Time /*contrib*/, time.Time) {
+
+	table.mutex.Lock()
+
+	key := table.key(peer)
+	sig, contrib := table.sigTimes[key], table.contribTimes[key]
+
+	table.mutex.Unlock()
+
+	return sig, contrib
+
+}
+
+/**
+ * Returns the factor by which the distance of a reputation
+ * to INIT_REP is multiplied after the given time, for the
+ * given half-life. A null half-life means no decay.
+ */
+func decayFactor(elapsed, halfLife time.Duration) float32 {
+
+	if halfLife <= 0 || elapsed <= 0 {
+		return 1
+	}
+
+	return float32(math.Pow(0.5, elapsed.Seconds()/halfLife.Seconds()))
+
+}
+
+/**
+ * Multiplies the distance of each reputation of the
+ * given kind to INIT_REP by the given factor.
+ * Thread unsafe.
+ */
+func (table *ReputationTable) decayReps(kind RepKind, factor float32) {
+
+	if factor == 1 {
+		return
+	}
+
+	reps := table.reps(kind)
+
+	for peer, rep := range reps {
+		reps[peer] = INIT_REP + (rep-INIT_REP)*factor
+		table.audit(kind, peer, CAUSE_DECAY, "", 0, rep, reps[peer])
+	}
+
+}
+
+/**
+ * Records an interaction with the peer whose reputations
+ * are stored under the given key in the given times map.
+ * Thread unsafe.
+ */
+func touch(times map[string]time.Time, key string) {
+	times[key] = time.Now()
+}
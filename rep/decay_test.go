@@ -0,0 +1,69 @@
+package rep
+
+/*
+   Imports
+*/
+
+import (
+	"testing"
+	"time"
+
+	"github.com/No-Trust/peerster/common"
+)
+
+/*
+   Functions
+*/
+
+// TestDecayFactor tests that the distance to the initial reputation is halved every half-life
+func TestDecayFactor(t *testing.T) {
+	halfLife := time.Hour
+
+	cases := []struct {
+		elapsed  time.Duration
+		halfLife time.Duration
+		factor   float32
+	}{
+		{0, halfLife, 1},
+		{-time.Minute, halfLife, 1},
+		{halfLife, halfLife, 0.5},
+		{2 * halfLife, halfLife, 0.25},
+		{halfLife / 2, halfLife, 0.70710677},
+		{halfLife, 0, 1},
+		{100 * halfLife, 0, 1},
+	}
+
+	for _, c := range cases {
+		if factor := decayFactor(c.elapsed, c.halfLife); common.AbsFloat32(factor-c.factor) > 1e-6 {
+			t.Fatalf("decay factor after %v with half-life %v should be %v, got %v", c.elapsed, c.halfLife, c.factor, factor)
+		}
+	}
+}
+
+// TestDecay tests that the reputations are halfway back to the initial reputation after a half-life
+func TestDecay(t *testing.T) {
+	table := newRankedTable(ReputationMap{"a": 0.9, "b": 0.1, "c": INIT_REP})
+	table.setRep(CONTRIB_REP, "a", 0.9)
+	table.config.SigHalfLife = time.Hour
+	table.config.ContribHalfLife = 0
+	table.lastDecay = time.Now().Add(-time.Hour)
+
+	table.Decay()
+
+	expected := ReputationMap{"a": 0.7, "b": 0.3, "c": INIT_REP}
+	for peer, rep := range expected {
+		if got, _ := table.GetSigRep(peer); common.AbsFloat32(got-rep) > 1e-3 {
+			t.Fatalf("reputation of %v should decay to %v after a half-life, got %v", peer, rep, got)
+		}
+	}
+
+	// no decay without half-life
+	if rep, _ := table.GetContribRep("a"); rep != 0.9 {
+		t.Fatalf("contrib-based reputation should not decay without half-life, got %v", rep)
+	}
+
+	// the order of the reputations is kept
+	if ranked := rankedNames(table.TopPeers(SIG_REP, 3)); ranked[0] != "a" || ranked[2] != "b" {
+		t.Fatalf("decay should keep the order of the reputations, got %v", ranked)
+	}
+}
@@ -0,0 +1,247 @@
+package rep
+
+/*
+   Imports
+*/
+
+import "github.com/No-Trust/peerster/common"
+
+/*
+   Type definitions
+*/
+
+/**
+ * The state of the EigenTrust-like global reputation : the
+ * normalized local reputations received from the issuers of
+ * reputation updates, and the global reputations computed
+ * from them. It is shared by the copies of a table.
+ * With the global reputation, the verified reputation updates
+ * are no longer blended into the table but kept as the opinions
+ * of their issuers, and the global reputations are used by the
+ * reputation scores (see Score) in place of the local ones.
+ */
+type globalTrust struct {
+	sigRows     map[string]ReputationMap // issuer key -> normalized sig-based reputations of the issuer
+	contribRows map[string]ReputationMap // issuer key -> normalized contrib-based reputations of the issuer
+	sigReps     ReputationMap            // global sig-based reputations, the highest being MAX_REP
+	contribReps ReputationMap            // global contrib-based reputations, the highest being MAX_REP
+}
+
+/*
+   Functions
+*/
+
+/**
+ * Returns a new empty global reputation state.
+ */
+func newGlobalTrust() *globalTrust {
+
+	return &globalTrust{
+		sigRows:     make(map[string]ReputationMap),
+		contribRows: make(map[string]ReputationMap),
+		sigReps:     make(ReputationMap),
+		contribReps: make(ReputationMap),
+	}
+
+}
+
+/**
+ * Computes the global reputations from the local reputations of
+ * this table and the ones received from the issuers of reputation
+ * updates, as in EigenTrust : the reputation of a peer is the sum
+ * of the opinions of the others on it, weighted by their own
+ * reputation, and the local reputations of this table act as the
+ * pre-trusted peers. The opinions are the local reputations
+ * sent by the issuers, not their global ones, so the result is
+ * only computed over the updates received by this table, and
+ * differs from one peer to another. Does nothing if the global
+ * reputation is not enabled (see Config).
+ */
+func (table *ReputationTable) ComputeGlobalTrust() {
+
+	table.mutex.Lock()
+
+	if table.config.GlobalTrust {
+		table.global.sigReps = eigenTrust(normalize(table.sigReps), table.global.sigRows, table.config)
+		table.global.contribReps = eigenTrust(normalize(table.contribReps), table.global.contribRows, table.config)
+	}
+
+	table.mutex.Unlock()
+
+}
+
+/**
+ * Returns the global reputations, keyed like the reputation
+ * updates, or nil maps if the global reputation is not enabled.
+ */
+func (table *ReputationTable) GetGlobalTrust() *RepUpdate {
+
+	repUpdate := RepUpdate{}
+
+	table.mutex.Lock()
+
+	if table.config.GlobalTrust {
+
+		repUpdate.SigReps = make(ReputationMap)
+		repUpdate.ContribReps = make(ReputationMap)
+
+		for peer, rep := range table.global.sigReps {
+			repUpdate.SigReps[peer] = rep
+		}
+		for peer, rep := range table.global.contribReps {
+			repUpdate.ContribReps[peer] = rep
+		}
+
+	}
+
+	table.mutex.Unlock()
+
+	return &repUpdate
+
+}
+
+/**
+ * Keeps the reputations of the given verified update as the
+ * opinion of its issuer, replacing the previous one.
+ * Thread unsafe.
+ */
+func (table *ReputationTable) recordOpinion(update *RepUpdate) {
+
+	issuer := table.key(update.Issuer)
+
+	rows := table.global.contribRows
+	reps := update.ContribReps
+	if update.SigReps != nil {
+		rows = table.global.sigRows
+		reps = update.SigReps
+	}
+
+	// An issuer has no say on its own reputation
+	opinion := table.resolve(reps)
+	delete(opinion, issuer)
+
+	rows[issuer] = normalize(opinion)
+
+}
+
+/**
+ * Returns the global reputation of the given key in the
+ * given map if the global reputation is enabled and was
+ * computed for it, and the given local reputation otherwise.
+ * Thread unsafe.
+ */
+func (table *ReputationTable) globalOr(global ReputationMap, key string, local float32) float32 {
+
+	if !table.config.GlobalTrust {
+		return local
+	}
+
+	if rep, ok := global[key]; ok {
+		return rep
+	}
+
+	return local
+
+}
+
+/**
+ * Returns the given reputations scaled so that they sum to 1,
+ * or an empty map if they are all null.
+ */
+func normalize(reps ReputationMap) ReputationMap {
+
+	normalized := make(ReputationMap)
+
+	var total float32 = 0
+	for _, rep := range reps {
+		total += common.ClampFloat32(rep, MIN_REP, MAX_REP) - MIN_REP
+	}
+
+	if total == 0 {
+		return normalized
+	}
+
+	for peer, rep := range reps {
+		normalized[peer] = (common.ClampFloat32(rep, MIN_REP, MAX_REP) - MIN_REP) / total
+	}
+
+	return normalized
+
+}
+
+/**
+ * Iterates t = (1 - a) * C^T * t + a * p until convergence, where
+ * p is the given normalized pre-trust vector, C the matrix of the
+ * given normalized opinions, and a the pre-trust weight of the
+ * given config. The trust of the peers without an opinion goes
+ * back to the pre-trusted peers. Returns t scaled so that its
+ * highest value is MAX_REP.
+ */
+func eigenTrust(preTrust ReputationMap, opinions map[string]ReputationMap, config Config) ReputationMap {
+
+	trust := make(ReputationMap)
+	for peer, rep := range preTrust {
+		trust[peer] = rep
+	}
+
+	for i := 0; i < config.GlobalTrustMaxIterations; i++ {
+
+		next := make(ReputationMap)
+
+		// Trust of the peers without an opinion
+		var dangling float32 = 0
+
+		for peer, t := range trust {
+
+			opinion, ok := opinions[peer]
+			if !ok || len(opinion) == 0 {
+				dangling += t
+				continue
+			}
+
+			for other, c := range opinion {
+				next[other] += (1 - config.GlobalTrustPreTrust) * t * c
+			}
+
+		}
+
+		for peer, p := range preTrust {
+			next[peer] += (config.GlobalTrustPreTrust + (1-config.GlobalTrustPreTrust)*dangling) * p
+		}
+
+		// Stop once the L1 distance between iterations is small enough
+		var delta float32 = 0
+		for peer, t := range next {
+			delta += common.AbsFloat32(t - trust[peer])
+		}
+		for peer, t := range trust {
+			if _, ok := next[peer]; !ok {
+				delta += t
+			}
+		}
+
+		trust = next
+
+		if delta < config.GlobalTrustEpsilon {
+			break
+		}
+
+	}
+
+	// Scale the trust to the reputation range
+	var max float32 = 0
+	for _, t := range trust {
+		if t > max {
+			max = t
+		}
+	}
+
+	if max > 0 {
+		for peer, t := range trust {
+			trust[peer] = MIN_REP + REP_RANGE*t/max
+		}
+	}
+
+	return trust
+
+}
@@ -0,0 +1,63 @@
+package rep
+
+/*
+   Imports
+*/
+
+import (
+	"testing"
+
+	"github.com/No-Trust/peerster/common"
+)
+
+/*
+   Functions
+*/
+
+// TestEigenTrustConvergence tests that the global reputations converge to the fixed point of a small opinion matrix
+func TestEigenTrustConvergence(t *testing.T) {
+	config := DefaultConfig()
+	config.GlobalTrustEpsilon = 1e-7
+	config.GlobalTrustMaxIterations = 1000
+
+	// a is the only pre-trusted peer, a trusts b and c equally, b trusts c, and c trusts a
+	preTrust := ReputationMap{"a": 1}
+	opinions := map[string]ReputationMap{
+		"a": {"b": 0.5, "c": 0.5},
+		"b": {"c": 1},
+		"c": {"a": 1},
+	}
+
+	// fixed point of t = (1 - w) * C^T * t + w * p
+	w := config.GlobalTrustPreTrust
+	b := 1 - w
+	ta := w / (1 - b*b*(1+b)/2)
+	tb := b * ta / 2
+	tc := b * ta * (1 + b) / 2
+	expected := ReputationMap{"a": MAX_REP, "b": MIN_REP + REP_RANGE*tb/ta, "c": MIN_REP + REP_RANGE*tc/ta}
+
+	trust := eigenTrust(preTrust, opinions, config)
+	if len(trust) != len(expected) {
+		t.Fatalf("global reputations should be computed for %v, got %v", expected, trust)
+	}
+	for peer, rep := range expected {
+		if common.AbsFloat32(trust[peer]-rep) > 1e-4 {
+			t.Fatalf("global reputation of %v should be %v, got %v", peer, rep, trust[peer])
+		}
+	}
+
+	// with the default config, the result is close to the fixed point
+	trust = eigenTrust(preTrust, opinions, DefaultConfig())
+	for peer, rep := range expected {
+		if common.AbsFloat32(trust[peer]-rep) > 0.01 {
+			t.Fatalf("global reputation of %v should be close to %v, got %v", peer, rep, trust[peer])
+		}
+	}
+
+	// the trust of a peer without an opinion goes back to the pre-trusted peers
+	opinions["c"] = ReputationMap{}
+	trust = eigenTrust(preTrust, opinions, config)
+	if trust["a"] != MAX_REP || trust["c"] <= trust["b"] {
+		t.Fatalf("a should stay the most trusted and c be trusted more than b, got %v", trust)
+	}
+}
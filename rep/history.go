@@ -0,0 +1,189 @@
+package rep
+
+/*
+   Imports
+*/
+
+import "time"
+
+/*
+   Type definitions
+*/
+
+/**
+ * The cause of a change of reputation.
+ */
+type HistoryCause string
+
+const (
+	CAUSE_VALID_SIGNATURE   HistoryCause = "valid signature"        // a signature of the peer was verified
+	CAUSE_INVALID_SIGNATURE HistoryCause = "invalid signature"      // a signature of the peer did not match its key
+	CAUSE_TRAFFIC           HistoryCause = "traffic"                // bytes were exchanged with the peer since the last traffic event, see RecordUpload
+	CAUSE_UPDATE            HistoryCause = "reputation update"      // a reputation update of another peer was applied
+	CAUSE_UPDATE_DISTANCE   HistoryCause = "distance of its update" // a reputation update of the peer differed from this table
+	CAUSE_DECAY             HistoryCause = "decay"                  // the reputation moved toward INIT_REP, see Decay
+	CAUSE_MIGRATION         HistoryCause = "identity migration"     // reputations kept under another name or address were merged
+	CAUSE_REHABILITATION    HistoryCause = "rehabilitation"         // the peer was given a new chance, see Rehabilitate
+)
+
+/**
+ * A change of a reputation of a peer. The successive decays
+ * of a reputation, happening at every decay tick, are one
+ * event, from the first decay to the last one.
+ */
+type HistoryEvent struct {
+	Time       time.Time    // time of the change, of the first decay for a decay
+	Kind       RepKind      // kind of the reputation changed
+	Cause      HistoryCause // cause of the change
+	Source     string       // peer at the origin of the change : issuer of the update applied, empty if none
+	Confidence float32      // confidence in the key for a signature, weight of the update applied, or distance of the update of the peer
+	Before     float32      // reputation before the change
+	After      float32      // reputation after the change, the last decay for a decay
+}
+
+/**
+ * The reputation history of a peer, as sent to the client.
+ */
+type PeerHistory struct {
+	Peer   string
+	Events []HistoryEvent
+}
+
+/*
+   Functions
+*/
+
+/**
+ * Returns the reputation history of the given peer, from the
+ * oldest event. Only the last HistoryMaxEvents events younger
+ * than HistoryMaxAge are kept (see Config).
+ */
+func (table *ReputationTable) GetHistory(peer string) []HistoryEvent {
+
+	table.mutex.Lock()
+
+	events := table.history[table.key(peer)]
+	history := make([]HistoryEvent, len(events))
+	copy(history, events)
+
+	table.mutex.Unlock()
+
+	return history
+
+}
+
+/**
+ * Appends the change of the reputation of the given kind stored
+ * under the given key from before to after to the history of the
+ * peer, and drops the events that are no longer retained. The
+ * events are never modified once appended, but for a decay
+ * following a decay of the same reputation, which extends it
+ * so that the decay ticks do not push the other events out of
+ * the history. Changes that leave the reputation as it was are
+ * not recorded.
+ * Thread unsafe.
+ */
+func (table *ReputationTable) audit(kind RepKind, key string, cause HistoryCause, source string,
+	confidence, before, after float32) {
+
+	if before == after || table.config.HistoryMaxEvents <= 0 {
+		return
+	}
+
+	now := time.Now()
+
+	if cause == CAUSE_DECAY {
+
+		events := table.history[key]
+
+		// Last event of the same kind, as the changes of
+		// the two kinds of reputations are interleaved
+		for i := len(events) - 1; i >= 0; i-- {
+
+			if events[i].Kind != kind {
+				continue
+			}
+
+			if events[i].Cause == CAUSE_DECAY {
+				events[i].After = after
+				return
+			}
+
+			break
+
+		}
+
+	}
+
+	events := append(table.history[key], HistoryEvent{
+		Time:       now,
+		Kind:       kind,
+		Cause:      cause,
+		Source:     source,
+		Confidence: confidence,
+		Before:     before,
+		After:      after,
+	})
+
+	table.history[key] = table.retain(events, now)
+
+}
+
+/**
+ * Returns the given events, from the oldest, without the
+ * ones that are no longer retained (see Config).
+ * Thread unsafe.
+ */
+func (table *ReputationTable) retain(events []HistoryEvent, now time.Time) []HistoryEvent {
+
+	first := 0
+
+	if table.config.HistoryMaxAge > 0 {
+		for first < len(events) && now.Sub(events[first].Time) > table.config.HistoryMaxAge {
+			first++
+		}
+	}
+
+	if len(events)-first > table.config.HistoryMaxEvents {
+		first = len(events) - table.config.HistoryMaxEvents
+	}
+
+	// Copy the retained events, for the dropped ones to be freed
+	if first > 0 {
+		events = append([]HistoryEvent(nil), events[first:]...)
+	}
+
+	return events
+
+}
+
+/**
+ * Moves the history stored under the given key to another key,
+ * merged with the history of the latter in time order.
+ * Thread unsafe.
+ */
+func (table *ReputationTable) migrateHistory(from, to string) {
+
+	old, ok := table.history[from]
+	if !ok {
+		return
+	}
+
+	delete(table.history, from)
+
+	events := table.history[to]
+	merged := make([]HistoryEvent, 0, len(old)+len(events))
+
+	for len(old) > 0 || len(events) > 0 {
+		if len(events) == 0 || (len(old) > 0 && old[0].Time.Before(events[0].Time)) {
+			merged = append(merged, old[0])
+			old = old[1:]
+		} else {
+			merged = append(merged, events[0])
+			events = events[1:]
+		}
+	}
+
+	table.history[to] = table.retain(merged, time.Now())
+
+}
@@ -0,0 +1,189 @@
+package rep
+
+/*
+   Imports
+*/
+
+import (
+	"testing"
+	"time"
+)
+
+/*
+   Functions
+*/
+
+// TestHistoryCauses tests that the changes of reputations are recorded with their cause
+func TestHistoryCauses(t *testing.T) {
+	table := newRankedTable(ReputationMap{"a": 0.5})
+
+	table.DecreaseSigRep("a", 0.8)
+	table.RecordDownload("a", TRAFFIC_DATA, 10000)
+
+	events := table.GetHistory("a")
+	if len(events) != 2 {
+		t.Fatalf("history should have 2 events, got %v", events)
+	}
+	if e := events[0]; e.Kind != SIG_REP || e.Cause != CAUSE_INVALID_SIGNATURE || e.Confidence != 0.8 ||
+		e.Before != 0.5 || e.After >= 0.5 {
+		t.Fatalf("first event should be an invalid signature lowering the reputation, got %+v", e)
+	}
+	if e := events[1]; e.Kind != CONTRIB_REP || e.Cause != CAUSE_TRAFFIC || e.After <= e.Before {
+		t.Fatalf("second event should be traffic raising the reputation, got %+v", e)
+	}
+
+	// a change that leaves the reputation as it was is not recorded
+	table.audit(SIG_REP, "a", CAUSE_DECAY, "", 0, 0.4, 0.4)
+	if events := table.GetHistory("a"); len(events) != 2 {
+		t.Fatalf("a null change should not be recorded, got %v", events)
+	}
+}
+
+// TestHistoryTraffic tests that the traffic is recorded by steps, and the control traffic not at all
+func TestHistoryTraffic(t *testing.T) {
+	table := newRankedTable(ReputationMap{})
+
+	// control packets from an unknown address leave no trace
+	table.RecordDownload("1.1.1.1:1", TRAFFIC_CONTROL, 1000)
+	if _, ok := table.ledger["1.1.1.1:1"]; ok {
+		t.Fatalf("control traffic should not create a ledger entry")
+	}
+	if events := table.GetHistory("1.1.1.1:1"); len(events) != 0 {
+		t.Fatalf("control traffic should not be recorded, got %v", events)
+	}
+
+	// small packets are recorded once their changes sum up to a step
+	table.RecordDownload("a", TRAFFIC_RUMOR, 10)
+	if events := table.GetHistory("a"); len(events) != 0 {
+		t.Fatalf("a small packet should not be recorded alone, got %v", events)
+	}
+	for i := 0; i < 100; i++ {
+		table.RecordDownload("a", TRAFFIC_RUMOR, 10)
+	}
+	events := table.GetHistory("a")
+	if len(events) == 0 || len(events) > 5 {
+		t.Fatalf("packets should be recorded by steps, got %v", events)
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i].Before != events[i-1].After {
+			t.Fatalf("traffic events should follow each other, got %v", events)
+		}
+	}
+	if events[0].Before != INIT_REP || events[0].After-events[0].Before < HISTORY_TRAFFIC_STEP {
+		t.Fatalf("first traffic event should start from the initial reputation, got %+v", events[0])
+	}
+}
+
+// TestHistoryAppendOnly tests that every change is appended, and the recorded events never change
+func TestHistoryAppendOnly(t *testing.T) {
+	table := newRankedTable(ReputationMap{})
+
+	table.audit(SIG_REP, "a", CAUSE_UPDATE, "b", 0.1, 0.5, 0.6)
+	first := table.GetHistory("a")
+
+	table.audit(CONTRIB_REP, "a", CAUSE_TRAFFIC, "", 0, 0.5, 0.7)
+	table.audit(SIG_REP, "a", CAUSE_UPDATE, "b", 0.2, 0.6, 0.65)
+	table.audit(SIG_REP, "a", CAUSE_UPDATE, "b", 0.3, 0.65, 0.7)
+
+	events := table.GetHistory("a")
+	if len(events) != 4 {
+		t.Fatalf("history should have 4 events, got %v", events)
+	}
+	if events[0] != first[0] {
+		t.Fatalf("a recorded event should not change, got %+v instead of %+v", events[0], first[0])
+	}
+	if e := events[2]; e.Before != 0.6 || e.After != 0.65 || e.Confidence != 0.2 {
+		t.Fatalf("updates of the same source should be recorded separately, got %+v", e)
+	}
+	if e := events[3]; e.Before != 0.65 || e.After != 0.7 || e.Confidence != 0.3 {
+		t.Fatalf("updates of the same source should be recorded separately, got %+v", e)
+	}
+}
+
+// TestHistoryDecay tests that the successive decays are one event, which does not push the others out
+func TestHistoryDecay(t *testing.T) {
+	table := newRankedTable(ReputationMap{"a": 0.5})
+	table.setRep(CONTRIB_REP, "a", 0.9)
+	table.config.SigHalfLife = time.Hour
+	table.config.ContribHalfLife = time.Hour
+
+	table.DecreaseSigRep("a", 1)
+	for i := 0; i < table.config.HistoryMaxEvents+50; i++ {
+		table.lastDecay = time.Now().Add(-time.Minute)
+		table.Decay()
+	}
+
+	events := table.GetHistory("a")
+	if len(events) != 3 || events[0].Cause != CAUSE_INVALID_SIGNATURE {
+		t.Fatalf("history should keep the invalid signature and one decay per kind, got %v", events)
+	}
+	for _, e := range events[1:] {
+		if e.Cause != CAUSE_DECAY || e.Before == e.After {
+			t.Fatalf("decays should be merged into one event per kind, got %+v", e)
+		}
+	}
+	if sig := events[1]; sig.Kind != SIG_REP || sig.Before != events[0].After {
+		t.Fatalf("the decay should start from the invalid signature, got %+v", sig)
+	}
+	if rep, _ := table.GetSigRep("a"); events[1].After != rep {
+		t.Fatalf("the decay should end at the current reputation %v, got %+v", rep, events[1])
+	}
+
+	// a decay after another change is a new event
+	decay := events[1]
+	table.IncreaseSigRep("a", 1)
+	table.lastDecay = time.Now().Add(-time.Minute)
+	table.Decay()
+	if events := table.GetHistory("a"); len(events) != 5 || events[4].Cause != CAUSE_DECAY || events[1] != decay {
+		t.Fatalf("a decay after a valid signature should be a new event, got %v", events)
+	}
+}
+
+// TestHistoryRetention tests the limits on the number and the age of the events
+func TestHistoryRetention(t *testing.T) {
+	table := newRankedTable(ReputationMap{})
+	table.config.HistoryMaxEvents = 3
+
+	causes := []HistoryCause{CAUSE_VALID_SIGNATURE, CAUSE_INVALID_SIGNATURE, CAUSE_DECAY, CAUSE_REHABILITATION}
+	for i, cause := range causes {
+		table.audit(SIG_REP, "a", cause, "", 0, float32(i)/10, float32(i+1)/10)
+	}
+
+	events := table.GetHistory("a")
+	if len(events) != 3 || events[0].Cause != CAUSE_INVALID_SIGNATURE {
+		t.Fatalf("only the last 3 events should be kept, got %v", events)
+	}
+
+	// the events older than the maximum age are dropped
+	table.history["a"][0].Time = time.Now().Add(-2 * table.config.HistoryMaxAge)
+	table.audit(SIG_REP, "a", CAUSE_VALID_SIGNATURE, "", 0, 0.4, 0.5)
+	if events := table.GetHistory("a"); len(events) != 3 || events[0].Cause != CAUSE_DECAY {
+		t.Fatalf("the old event should be dropped, got %v", events)
+	}
+
+	// no history is kept without events
+	table.config.HistoryMaxEvents = 0
+	table.audit(SIG_REP, "b", CAUSE_VALID_SIGNATURE, "", 0, 0.4, 0.5)
+	if events := table.GetHistory("b"); len(events) != 0 {
+		t.Fatalf("no history should be kept, got %v", events)
+	}
+}
+
+// TestHistoryMigration tests that the history follows an identity
+func TestHistoryMigration(t *testing.T) {
+	table := newRankedTable(ReputationMap{"a": 0.5})
+
+	table.DecreaseSigRep("a", 1)
+	table.Identify("a", "fp")
+	table.IncreaseSigRep("a", 1)
+
+	events := table.GetHistory("a")
+	if len(events) < 2 || events[0].Cause != CAUSE_INVALID_SIGNATURE || events[len(events)-1].Cause != CAUSE_VALID_SIGNATURE {
+		t.Fatalf("history should be kept across the identification, got %v", events)
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i].Time.Before(events[i-1].Time) {
+			t.Fatalf("history should be in time order, got %v", events)
+		}
+	}
+}
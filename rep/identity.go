@@ -0,0 +1,410 @@
+package rep
+
+/*
+   Imports
+*/
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/No-Trust/peerster/awot"
+	"github.com/No-Trust/peerster/common"
+)
+
+/*
+   Constants
+*/
+
+const bindingPrefix = "IDBINDING"
+
+/*
+   Functions
+*/
+
+/**
+ * Returns the key under which the reputations of this
+ * identity are stored, made of its name and fingerprint.
+ */
+func (id Identity) Key() string {
+	return id.Name + IDENTITY_SEPARATOR + id.Fingerprint
+}
+
+/**
+ * Returns a new nonce to send to the given address, which the
+ * peer at that address must sign to be bound to its identity
+ * (see Bind), or false if a challenge sent to the address is
+ * still pending.
+ */
+func (table *ReputationTable) Challenge(addr string) ( /*nonce*/ []byte /*ok*/, bool) {
+
+	now := time.Now()
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	// Forget the challenges that were not answered in time
+	for a, pending := range table.challenges {
+		if now.Sub(pending.sent) > BINDING_CHALLENGE_TIMEOUT {
+			delete(table.challenges, a)
+		}
+	}
+
+	if _, ok := table.challenges[addr]; ok {
+		return nil, false
+	}
+
+	nonce := make([]byte, BINDING_NONCE_SIZE)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, false
+	}
+
+	table.challenges[addr] = challenge{nonce: nonce, sent: now}
+
+	return nonce, true
+
+}
+
+/**
+ * Returns true if the given address is bound to the
+ * identity of the peer with the given name.
+ */
+func (table *ReputationTable) IsBound(addr, name string) bool {
+
+	table.mutex.Lock()
+
+	key, ok := table.names[name]
+	bound := ok && table.bindings[addr] == key
+
+	table.mutex.Unlock()
+
+	return bound
+
+}
+
+/**
+ * Returns the address bound to the identity of the peer
+ * with the given name, if any (see Bind).
+ */
+func (table *ReputationTable) BoundAddress(name string) ( /*addr*/ string /*ok*/, bool) {
+
+	table.mutex.Lock()
+
+	addr, ok := table.addresses[table.key(name)]
+
+	table.mutex.Unlock()
+
+	return addr, ok
+
+}
+
+/**
+ * Returns the SHA-256 hash of the data signed to answer a
+ * challenge : the name of the peer, the address it was
+ * challenged at and the nonce of the challenge.
+ */
+func BindingHash(name, addr string, nonce []byte) []byte {
+
+	hash := sha256.New()
+
+	hash.Write([]byte(bindingPrefix))
+
+	for _, field := range [][]byte{[]byte(name), []byte(addr), nonce} {
+		binary.Write(hash, binary.BigEndian, uint32(len(field)))
+		hash.Write(field)
+	}
+
+	return hash.Sum(nil)
+
+}
+
+/**
+ * Signs the answer of the peer with the given name to the
+ * challenge with the given nonce sent to the given address.
+ */
+func SignBinding(name, addr string, nonce []byte, key crypto.Signer) ([]byte, error) {
+
+	return awot.Sign(key, BindingHash(name, addr, nonce))
+
+}
+
+/**
+ * Records that the peer with the given name and public key
+ * uses the given address, if the given signature answers the
+ * pending challenge sent to the address (see Challenge and
+ * SignBinding), which proves that the peer at the address holds
+ * the key. The reputations kept until then for the address and
+ * for the name are migrated to the identity, so that changing
+ * address does not give a peer a new reputation.
+ */
+func (table *ReputationTable) Bind(addr, name string, key crypto.PublicKey, signature []byte) error {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	pending, ok := table.challenges[addr]
+	if !ok || time.Since(pending.sent) > BINDING_CHALLENGE_TIMEOUT {
+		return errors.New("no pending challenge for " + addr)
+	}
+
+	if err := awot.VerifySignature(key, BindingHash(name, addr, pending.nonce), signature); err != nil {
+		return errors.New("wrong binding signature of " + name + " at " + addr)
+	}
+
+	// A challenge is answered once
+	delete(table.challenges, addr)
+
+	table.bind(addr, table.identify(name, awot.Fingerprint(key)))
+
+	return nil
+
+}
+
+/**
+ * Binds the given address to the given identity.
+ * Thread unsafe.
+ */
+func (table *ReputationTable) bind(addr string, id Identity) {
+
+	// If the address was bound to another identity,
+	// that identity is no longer reachable through it
+	if previous, ok := table.bindings[addr]; ok && previous != id.Key() &&
+		table.addresses[previous] == addr {
+		delete(table.addresses, previous)
+	}
+
+	table.bindings[addr] = id.Key()
+	table.addresses[id.Key()] = addr
+
+	// Migrate the reputations kept for the address
+	table.migrate(addr, id.Key())
+
+}
+
+/**
+ * Records that the key with the given fingerprint is the
+ * one trusted for the peer with the given name, and migrates
+ * the reputations kept until then for the name to the identity.
+ */
+func (table *ReputationTable) Identify(name, fingerprint string) {
+
+	table.mutex.Lock()
+
+	table.identify(name, fingerprint)
+
+	table.mutex.Unlock()
+
+}
+
+/**
+ * Returns the identity of the peer with the given name
+ * or address, if it was identified.
+ */
+func (table *ReputationTable) GetIdentity(peer string) ( /*id*/ Identity /*ok*/, bool) {
+
+	table.mutex.Lock()
+
+	id, ok := table.identities[table.key(peer)]
+
+	table.mutex.Unlock()
+
+	return id, ok
+
+}
+
+/**
+ * Registers the identity with the given name and fingerprint.
+ * If the peer was known with another key, its reputations and
+ * addresses follow it to the new key, as the key ring only
+ * trusts a new key for a name after a valid transition.
+ * Thread unsafe.
+ */
+func (table *ReputationTable) identify(name, fingerprint string) Identity {
+
+	id := Identity{Name: name, Fingerprint: fingerprint}
+
+	if oldKey, ok := table.names[name]; ok && oldKey != id.Key() {
+
+		table.migrate(oldKey, id.Key())
+
+		for addr, key := range table.bindings {
+			if key == oldKey {
+				table.bindings[addr] = id.Key()
+			}
+		}
+
+		if addr, ok := table.addresses[oldKey]; ok {
+			table.addresses[id.Key()] = addr
+			delete(table.addresses, oldKey)
+		}
+
+		delete(table.identities, oldKey)
+
+	}
+
+	table.identities[id.Key()] = id
+	table.names[name] = id.Key()
+
+	// Migrate the reputations kept for the name
+	table.migrate(name, id.Key())
+
+	return id
+
+}
+
+/**
+ * Moves the reputations stored under the given key to
+ * another key. If the latter already has a reputation,
+ * the deviation of the former from the initial reputation
+ * is added to it. The ledger entries, the times of the
+ * last interactions and the histories are moved as well.
+ * Thread unsafe.
+ */
+func (table *ReputationTable) migrate(from, to string) {
+
+	if from == to {
+		return
+	}
+
+	table.migrateHistory(from, to)
+
+	for _, kind := range []RepKind{SIG_REP, CONTRIB_REP} {
+
+		reps := table.reps(kind)
+
+		old, ok := reps[from]
+		if !ok {
+			continue
+		}
+
+		table.deleteRep(kind, from)
+
+		if rep, ok := reps[to]; ok {
+			table.setRep(kind, to, common.ClampFloat32(rep+old-INIT_REP, MIN_REP, MAX_REP))
+			table.audit(kind, to, CAUSE_MIGRATION, table.sigHandle(from), 0, rep, reps[to])
+		} else {
+			table.setRep(kind, to, old)
+		}
+
+	}
+
+	// Sum the ledger entries
+	if old, ok := table.ledger[from]; ok {
+
+		delete(table.ledger, from)
+
+		if entry, ok := table.ledger[to]; ok {
+			entry.Uploaded = entry.Uploaded.add(old.Uploaded)
+			entry.Downloaded = entry.Downloaded.add(old.Downloaded)
+		} else {
+			table.ledger[to] = old
+		}
+
+	}
+
+	// Sum the changes by traffic not yet in the history
+	if change, ok := table.traffic[from]; ok {
+		delete(table.traffic, from)
+		table.traffic[to] += change
+	}
+
+	// Keep the latest interaction
+	for _, times := range []map[string]time.Time{table.sigTimes, table.contribTimes} {
+
+		old, ok := times[from]
+		if !ok {
+			continue
+		}
+
+		delete(times, from)
+
+		if old.After(times[to]) {
+			times[to] = old
+		}
+
+	}
+
+}
+
+/**
+ * Returns the key under which the reputations of the peer
+ * with the given address or name are stored : the key of
+ * its identity if it was identified, the address or name
+ * itself otherwise.
+ * Thread unsafe.
+ */
+func (table *ReputationTable) key(peer string) string {
+
+	if key, ok := table.bindings[peer]; ok {
+		return key
+	}
+
+	if key, ok := table.names[peer]; ok {
+		return key
+	}
+
+	return peer
+
+}
+
+/**
+ * Returns a copy of the given peer->rep map keyed by
+ * the keys of this table. If both an identity and a
+ * name or address of it are present, the reputation
+ * of the identity is kept.
+ * Thread unsafe.
+ */
+func (table *ReputationTable) resolve(reps ReputationMap) ReputationMap {
+
+	resolved := make(ReputationMap)
+
+	for peer, rep := range reps {
+
+		key := table.key(peer)
+
+		if _, ok := resolved[key]; ok && key != peer {
+			continue
+		}
+
+		resolved[key] = rep
+
+	}
+
+	return resolved
+
+}
+
+/**
+ * Returns the name of the peer whose signature-based
+ * reputation is stored under the given key.
+ * Thread unsafe.
+ */
+func (table *ReputationTable) sigHandle(key string) string {
+
+	if id, ok := table.identities[key]; ok {
+		return id.Name
+	}
+
+	return key
+
+}
+
+/**
+ * Returns the address of the peer whose contribution-based
+ * reputation is stored under the given key, or an empty
+ * string if no address is bound to its identity.
+ * Thread unsafe.
+ */
+func (table *ReputationTable) contribHandle(key string) string {
+
+	if _, ok := table.identities[key]; ok {
+		return table.addresses[key]
+	}
+
+	return key
+
+}
@@ -0,0 +1,187 @@
+package rep
+
+/*
+   Imports
+*/
+
+import (
+	"crypto"
+	"testing"
+	"time"
+
+	"github.com/No-Trust/peerster/awot"
+)
+
+/*
+   Functions
+*/
+
+/**
+ * Returns a new Ed25519 private key, failing the test on error.
+ */
+func newTestKey(t *testing.T) crypto.Signer {
+
+	key, err := awot.GenerateKey(awot.KeyAlgorithmEd25519, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+
+}
+
+/**
+ * Challenges the given address and answers the challenge
+ * in the name of the given peer with the given key.
+ */
+func answerChallenge(t *testing.T, table *ReputationTable, addr, name string, key crypto.Signer) []byte {
+
+	nonce, ok := table.Challenge(addr)
+	if !ok {
+		t.Fatalf("challenge of %v should be sent", addr)
+	}
+
+	signature, err := SignBinding(name, addr, nonce, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signature
+
+}
+
+// TestBind tests that an address is only bound to an identity with a proof of its key
+func TestBind(t *testing.T) {
+	table := newRankedTable(ReputationMap{})
+	alice, mallory := newTestKey(t), newTestKey(t)
+	table.RecordDownload("1.1.1.1:1", TRAFFIC_DATA, 10000)
+	table.RecordDownload("6.6.6.6:6", TRAFFIC_DATA, 10)
+
+	// without a challenge, a signature is rejected
+	signature, _ := SignBinding("alice", "6.6.6.6:6", []byte("nonce"), mallory)
+	if err := table.Bind("6.6.6.6:6", "alice", alice.Public(), signature); err == nil {
+		t.Fatalf("a binding without challenge should be rejected")
+	}
+
+	// a challenge answered with another key is rejected
+	signature = answerChallenge(t, table, "6.6.6.6:6", "alice", mallory)
+	if err := table.Bind("6.6.6.6:6", "alice", alice.Public(), signature); err == nil {
+		t.Fatalf("a binding signed with another key should be rejected")
+	}
+	if _, ok := table.Challenge("6.6.6.6:6"); ok {
+		t.Fatalf("a second challenge should not be sent while one is pending")
+	}
+
+	// the proof of alice binds her address
+	signature = answerChallenge(t, table, "1.1.1.1:1", "alice", alice)
+	if err := table.Bind("1.1.1.1:1", "alice", alice.Public(), signature); err != nil {
+		t.Fatalf("a valid binding should be accepted : %v", err)
+	}
+	if !table.IsBound("1.1.1.1:1", "alice") || table.IsBound("6.6.6.6:6", "alice") {
+		t.Fatalf("only the address of alice should be bound to alice")
+	}
+	if id, ok := table.GetIdentity("1.1.1.1:1"); !ok || id.Fingerprint != awot.Fingerprint(alice.Public()) {
+		t.Fatalf("the address should have the identity of alice, got %v (%v)", id, ok)
+	}
+
+	// the contribution of the address follows the identity
+	if rep, ok := table.GetContribRep("alice"); !ok || rep <= INIT_REP {
+		t.Fatalf("the contribution of the address should be kept for alice, got %v (%v)", rep, ok)
+	}
+
+	// a proof cannot be replayed
+	if err := table.Bind("1.1.1.1:1", "alice", alice.Public(), signature); err == nil {
+		t.Fatalf("an answered challenge should not be accepted again")
+	}
+
+	// nor used for another address
+	nonce, _ := table.Challenge("7.7.7.7:7")
+	signature, _ = SignBinding("alice", "1.1.1.1:1", nonce, alice)
+	if err := table.Bind("7.7.7.7:7", "alice", alice.Public(), signature); err == nil {
+		t.Fatalf("a binding signed for another address should be rejected")
+	}
+
+	// the challenges expire
+	table.challenges["7.7.7.7:7"] = challenge{nonce: nonce, sent: time.Now().Add(-2 * BINDING_CHALLENGE_TIMEOUT)}
+	signature, _ = SignBinding("alice", "7.7.7.7:7", nonce, alice)
+	if err := table.Bind("7.7.7.7:7", "alice", alice.Public(), signature); err == nil {
+		t.Fatalf("an expired challenge should not be accepted")
+	}
+}
+
+// TestIdentify tests that the reputations and addresses follow a new key of a peer
+func TestIdentify(t *testing.T) {
+	table := newRankedTable(ReputationMap{"alice": 0.8})
+	key := newTestKey(t)
+
+	table.Identify("alice", "old")
+	if rep, ok := table.GetSigRep("alice"); !ok || rep != 0.8 {
+		t.Fatalf("the reputation of the name should follow the identity, got %v (%v)", rep, ok)
+	}
+	if _, ok := table.sigReps["alice"]; ok {
+		t.Fatalf("the reputation should no longer be kept under the name")
+	}
+
+	signature := answerChallenge(t, table, "1.1.1.1:1", "alice", key)
+	if err := table.Bind("1.1.1.1:1", "alice", key.Public(), signature); err != nil {
+		t.Fatal(err)
+	}
+
+	// the key of the binding is the new trusted key of alice
+	id, _ := table.GetIdentity("alice")
+	if id.Fingerprint != awot.Fingerprint(key.Public()) {
+		t.Fatalf("alice should be identified by her new key, got %v", id)
+	}
+	if rep, ok := table.GetSigRep("alice"); !ok || rep != 0.8 {
+		t.Fatalf("the reputation should follow the new key, got %v (%v)", rep, ok)
+	}
+	if _, ok := table.identities["alice"+IDENTITY_SEPARATOR+"old"]; ok {
+		t.Fatalf("the old identity should be forgotten")
+	}
+	if table.bindings["1.1.1.1:1"] != id.Key() || table.addresses[id.Key()] != "1.1.1.1:1" {
+		t.Fatalf("the address should be bound to the new identity")
+	}
+}
+
+// TestMigrate tests that migrated reputations, ledgers and times are merged
+func TestMigrate(t *testing.T) {
+	table := newRankedTable(ReputationMap{"from": 0.7, "to": 0.6})
+	table.RecordDownload("from", TRAFFIC_DATA, 100)
+	table.RecordDownload("to", TRAFFIC_DATA, 50)
+	table.sigTimes["from"] = time.Now()
+	table.sigTimes["to"] = time.Now().Add(-time.Hour)
+
+	table.mutex.Lock()
+	table.migrate("from", "to")
+	table.mutex.Unlock()
+
+	// the deviations from the initial reputation are added
+	if rep := table.sigReps["to"]; rep < 0.79 || rep > 0.81 {
+		t.Fatalf("migrated reputation should be 0.6 + 0.2, got %v", rep)
+	}
+	if _, ok := table.sigReps["from"]; ok {
+		t.Fatalf("the reputation should no longer be kept under the old key")
+	}
+	if len(table.sigIndex.keys) != len(table.sigReps) {
+		t.Fatalf("the index should follow the migration, got %v", table.sigIndex.keys)
+	}
+
+	// the ledger entries are summed
+	if _, ok := table.ledger["from"]; ok {
+		t.Fatalf("the ledger entry should no longer be kept under the old key")
+	}
+	if entry := table.ledger["to"]; entry.Downloaded.Data != 150 {
+		t.Fatalf("the ledger entries should be summed, got %v", entry.Downloaded)
+	}
+
+	// the latest interaction is kept
+	if time.Since(table.sigTimes["to"]) > time.Minute {
+		t.Fatalf("the latest interaction should be kept, got %v", table.sigTimes["to"])
+	}
+
+	// the migration of a key to itself changes nothing
+	table.migrate("to", "to")
+	if _, ok := table.sigReps["to"]; !ok {
+		t.Fatalf("the migration of a key to itself should keep its reputation")
+	}
+}
@@ -0,0 +1,232 @@
+package rep
+
+/*
+   Imports
+*/
+
+import "github.com/No-Trust/peerster/common"
+
+/*
+   Type definitions
+*/
+
+/**
+ * The class of the traffic exchanged with a peer.
+ */
+type TrafficClass string
+
+const (
+	TRAFFIC_RUMOR   TrafficClass = "rumor"   // rumors and private messages
+	TRAFFIC_DATA    TrafficClass = "data"    // data requests and replies
+	TRAFFIC_CONTROL TrafficClass = "control" // status, key and reputation packets
+)
+
+/**
+ * A number of bytes per traffic class.
+ */
+type Traffic struct {
+	Rumor   uint64
+	Data    uint64
+	Control uint64
+}
+
+/**
+ * The bytes uploaded to and downloaded from a peer.
+ */
+type LedgerEntry struct {
+	Uploaded   Traffic
+	Downloaded Traffic
+}
+
+/*
+   Functions
+*/
+
+/**
+ * Records that the given number of bytes of the given
+ * class was sent to the given peer, and updates its
+ * contribution-based reputation.
+ */
+func (table *ReputationTable) RecordUpload(peer string, class TrafficClass, bytes int) {
+	table.record(peer, class, bytes, true)
+}
+
+/**
+ * Records that the given number of bytes of the given
+ * class was received from the given peer, and updates
+ * its contribution-based reputation.
+ */
+func (table *ReputationTable) RecordDownload(peer string, class TrafficClass, bytes int) {
+	table.record(peer, class, bytes, false)
+}
+
+/**
+ * Returns the ledger entry of the given peer.
+ */
+func (table *ReputationTable) GetLedger(peer string) ( /*entry*/ LedgerEntry /*ok*/, bool) {
+
+	table.mutex.Lock()
+
+	entry, ok := table.ledger[table.key(peer)]
+
+	table.mutex.Unlock()
+
+	if !ok {
+		return LedgerEntry{}, false
+	}
+
+	return *entry, true
+
+}
+
+/**
+ * Returns the balance of the given peer : the weighted
+ * bytes received from it minus the ones sent to it (see
+ * Config). A positive balance means the peer gave more
+ * than it received.
+ */
+func (table *ReputationTable) Balance(peer string) float64 {
+
+	table.mutex.Lock()
+
+	var balance float64 = 0
+	if entry, ok := table.ledger[table.key(peer)]; ok {
+		balance = table.weigh(entry.Downloaded) - table.weigh(entry.Uploaded)
+	}
+
+	table.mutex.Unlock()
+
+	return balance
+
+}
+
+/**
+ * Records the given traffic with the given peer. The contribution-
+ * based reputation of the peer moves by the change of its download
+ * ratio (see ratio), so that the adjustments made by reputation
+ * updates and decay are kept. The traffic of a class without weight
+ * does not create a ledger entry, as it does not move the reputation.
+ * The changes by traffic are added to the history once they sum
+ * up to HISTORY_TRAFFIC_STEP, rather than for every packet.
+ */
+func (table *ReputationTable) record(peer string, class TrafficClass, bytes int, uploaded bool) {
+
+	if bytes <= 0 {
+		return
+	}
+
+	table.mutex.Lock()
+
+	key := table.key(peer)
+	weighted := table.classWeight(class) > 0
+
+	entry, ok := table.ledger[key]
+	if !ok && !weighted {
+		table.mutex.Unlock()
+		return
+	}
+	if !ok {
+		entry = &LedgerEntry{}
+		table.ledger[key] = entry
+	}
+
+	before := table.ratio(entry)
+
+	traffic := &entry.Downloaded
+	if uploaded {
+		traffic = &entry.Uploaded
+	}
+
+	switch class {
+	case TRAFFIC_RUMOR:
+		traffic.Rumor += uint64(bytes)
+	case TRAFFIC_DATA:
+		traffic.Data += uint64(bytes)
+	default:
+		traffic.Control += uint64(bytes)
+	}
+
+	table.initContribRep(key)
+	rep := table.contribReps[key]
+	table.setRep(CONTRIB_REP, key, common.ClampFloat32(
+		rep+table.ratio(entry)-before, MIN_REP, MAX_REP))
+
+	// Record the changes by traffic since the last event at once
+	table.traffic[key] += table.contribReps[key] - rep
+	if change := table.traffic[key]; change >= HISTORY_TRAFFIC_STEP || change <= -HISTORY_TRAFFIC_STEP {
+		table.audit(CONTRIB_REP, key, CAUSE_TRAFFIC, "", 0, table.contribReps[key]-change, table.contribReps[key])
+		delete(table.traffic, key)
+	}
+
+	// Only the traffic that counts is an interaction
+	if weighted {
+		touch(table.contribTimes, key)
+	}
+
+	table.mutex.Unlock()
+
+}
+
+/**
+ * Returns the share of the weighted traffic with the peer of
+ * the given entry that was downloaded from it, mapped to the
+ * reputation range. The prior bytes of the Config count as
+ * both uploaded and downloaded, so that an empty entry has
+ * the initial reputation.
+ * Thread unsafe.
+ */
+func (table *ReputationTable) ratio(entry *LedgerEntry) float32 {
+
+	prior := float64(table.config.LedgerPrior)
+	downloaded := table.weigh(entry.Downloaded) + prior
+	uploaded := table.weigh(entry.Uploaded) + prior
+
+	if downloaded+uploaded == 0 {
+		return INIT_REP
+	}
+
+	return MIN_REP + REP_RANGE*float32(downloaded/(downloaded+uploaded))
+
+}
+
+/**
+ * Returns the given traffic weighted by class (see Config).
+ * Thread unsafe.
+ */
+func (table *ReputationTable) weigh(traffic Traffic) float64 {
+
+	return float64(table.config.RumorWeight)*float64(traffic.Rumor) +
+		float64(table.config.DataWeight)*float64(traffic.Data) +
+		float64(table.config.ControlWeight)*float64(traffic.Control)
+
+}
+
+/**
+ * Returns the weight of the given traffic class (see Config).
+ * Thread unsafe.
+ */
+func (table *ReputationTable) classWeight(class TrafficClass) float32 {
+
+	switch class {
+	case TRAFFIC_RUMOR:
+		return table.config.RumorWeight
+	case TRAFFIC_DATA:
+		return table.config.DataWeight
+	default:
+		return table.config.ControlWeight
+	}
+
+}
+
+/**
+ * Returns the sum of the two given traffics.
+ */
+func (traffic Traffic) add(other Traffic) Traffic {
+
+	return Traffic{
+		Rumor:   traffic.Rumor + other.Rumor,
+		Data:    traffic.Data + other.Data,
+		Control: traffic.Control + other.Control,
+	}
+
+}
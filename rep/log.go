@@ -58,6 +58,53 @@ func (table *ReputationTable) Log() {
 
 	writer.Flush()
 
+	if table.config.GlobalTrust {
+
+		fmt.Println("\nGlobal Reputations:")
+		fmt.Fprintln(writer, "Peer \t Sig-Rep \t Contrib-Rep")
+
+		for peer, rep := range table.global.sigReps {
+
+			fmt.Fprintln(writer, peer, "\t", rep, "\t", table.global.contribReps[peer])
+
+		}
+
+		for peer, rep := range table.global.contribReps {
+
+			if _, ok := table.global.sigReps[peer]; !ok {
+				fmt.Fprintln(writer, peer, "\t", "-", "\t", rep)
+			}
+
+		}
+
+		writer.Flush()
+
+	}
+
+	fmt.Println("\nContribution Ledger:")
+	fmt.Fprintln(writer, "Peer \t Uploaded (rumor/data/control) \t Downloaded (rumor/data/control)")
+
+	for peer, entry := range table.ledger {
+
+		fmt.Fprintf(writer, "%s \t %d/%d/%d \t %d/%d/%d\n", peer,
+			entry.Uploaded.Rumor, entry.Uploaded.Data, entry.Uploaded.Control,
+			entry.Downloaded.Rumor, entry.Downloaded.Data, entry.Downloaded.Control)
+
+	}
+
+	writer.Flush()
+
+	fmt.Println("\nIdentity Bindings:")
+	fmt.Fprintln(writer, "Address \t Identity")
+
+	for addr, key := range table.bindings {
+
+		fmt.Fprintln(writer, addr, "\t", key)
+
+	}
+
+	writer.Flush()
+
 	table.mutex.Unlock()
 
 	fmt.Println("*************************************")
@@ -0,0 +1,244 @@
+package rep
+
+/*
+   Imports
+*/
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+/*
+   Type definitions
+*/
+
+/**
+ * What a reputation score is used for.
+ */
+type Purpose string
+
+const (
+	PURPOSE_KEY_SIGNING Purpose = "keysigning" // trust in the key signatures of a peer
+	PURPOSE_UPLOAD      Purpose = "upload"     // priority of a peer for the upload slots
+	PURPOSE_RELAY       Purpose = "relay"      // choice of the peers rumors and statuses are sent to
+	PURPOSE_UPDATE      Purpose = "update"     // weight of the reputation updates of a peer
+)
+
+/**
+ * The weights of the signals combined into a reputation
+ * score. The score is the weighted mean of the signals
+ * known for the peer.
+ */
+type Weights struct {
+	Sig     float32 // signature-based reputation, the global one if enabled
+	Contrib float32 // contribution-based reputation, the global one if enabled
+	Ledger  float32 // share of the traffic with the peer that it uploaded, see Balance
+}
+
+/*
+   Functions
+*/
+
+/**
+ * Returns the default weights of each purpose, under which
+ * each subsystem uses the reputations it used before the
+ * scores, but the updates which are weighted by both kinds.
+ */
+func DefaultWeights() map[Purpose]Weights {
+
+	return map[Purpose]Weights{
+		PURPOSE_KEY_SIGNING: {Sig: 1},
+		PURPOSE_UPLOAD:      {Ledger: 1},
+		PURPOSE_RELAY:       {Contrib: 1},
+		PURPOSE_UPDATE:      {Sig: 1, Contrib: 1},
+	}
+
+}
+
+/**
+ * Returns the reputation score of the given peer for the
+ * given purpose, or false if no signal with a weight is
+ * known for the peer.
+ */
+func (table *ReputationTable) Score(purpose Purpose, peer string) ( /*score*/ float32 /*ok*/, bool) {
+
+	table.mutex.Lock()
+
+	score, ok := table.score(purpose, table.key(peer))
+
+	table.mutex.Unlock()
+
+	return score, ok
+
+}
+
+/**
+ * Returns the address of a random peer, chosen with a probability
+ * proportional to its score for the given purpose. The identities
+ * without a bound address, and the addresses for which the given
+ * function returns true, are not chosen. The function may be nil,
+ * it is called with the table locked and must not use the table.
+ * Returns an empty string if no peer can be chosen.
+ */
+func (table *ReputationTable) RandomPeer(purpose Purpose, excluded func( /*peer*/ string) bool) string {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	peers := make([]string, 0)
+	scores := make([]float32, 0)
+	var total float32 = 0
+
+	for key := range table.contribReps {
+
+		peer := table.contribHandle(key)
+		if peer == "" || (excluded != nil && excluded(peer)) {
+			continue
+		}
+
+		// The peers without any signal are chosen as new peers
+		score, ok := table.score(purpose, key)
+		if !ok {
+			score = INIT_REP
+		}
+
+		peers = append(peers, peer)
+		scores = append(scores, score)
+		total += score
+
+	}
+
+	random := rand.Float32() * total
+
+	var counter float32 = 0
+	for i, peer := range peers {
+
+		counter += scores[i]
+
+		if random < counter {
+			return peer
+		}
+
+	}
+
+	return ""
+
+}
+
+/**
+ * Parses the weights of the given purposes, given as
+ * purpose=signal:weight,signal:weight;purpose=... with the
+ * signals sig, contrib and ledger, and sets them in the given
+ * map. The signals not given for a purpose have a null weight.
+ */
+func ParseWeights(spec string, weights map[Purpose]Weights) error {
+
+	for _, entry := range strings.Split(spec, ";") {
+
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return errors.New("reputation weights must be of the form purpose=signal:weight,...")
+		}
+
+		purpose := Purpose(strings.TrimSpace(parts[0]))
+		if _, ok := DefaultWeights()[purpose]; !ok {
+			return errors.New("unknown reputation purpose " + string(purpose))
+		}
+
+		w := Weights{}
+
+		for _, signal := range strings.Split(parts[1], ",") {
+
+			pair := strings.SplitN(signal, ":", 2)
+			if len(pair) != 2 {
+				return errors.New("reputation weights must be of the form purpose=signal:weight,...")
+			}
+
+			value, err := strconv.ParseFloat(strings.TrimSpace(pair[1]), 32)
+			if err != nil {
+				return err
+			}
+
+			switch strings.TrimSpace(pair[0]) {
+			case "sig":
+				w.Sig = float32(value)
+			case "contrib":
+				w.Contrib = float32(value)
+			case "ledger":
+				w.Ledger = float32(value)
+			default:
+				return errors.New("unknown reputation signal " + pair[0])
+			}
+
+		}
+
+		weights[purpose] = w
+
+	}
+
+	return nil
+
+}
+
+/**
+ * Returns an error if a weight is negative or if all the
+ * weights are null.
+ */
+func (w Weights) validate() error {
+
+	if w.Sig < 0 || w.Contrib < 0 || w.Ledger < 0 {
+		return errors.New("reputation weights must not be negative")
+	}
+
+	if w.Sig+w.Contrib+w.Ledger == 0 {
+		return errors.New("reputation weights must not all be null")
+	}
+
+	return nil
+
+}
+
+/**
+ * Returns the score for the given purpose of the peer whose
+ * reputations are stored under the given key. A purpose
+ * without weights falls back to its default weights.
+ * Thread unsafe.
+ */
+func (table *ReputationTable) score(purpose Purpose, key string) (float32, bool) {
+
+	weights, ok := table.config.Weights[purpose]
+	if !ok {
+		weights = DefaultWeights()[purpose]
+	}
+
+	var sum, total float32 = 0, 0
+
+	if rep, ok := table.sigReps[key]; ok && weights.Sig > 0 {
+		sum += weights.Sig * table.globalOr(table.global.sigReps, key, rep)
+		total += weights.Sig
+	}
+
+	if rep, ok := table.contribReps[key]; ok && weights.Contrib > 0 {
+		sum += weights.Contrib * table.globalOr(table.global.contribReps, key, rep)
+		total += weights.Contrib
+	}
+
+	if entry, ok := table.ledger[key]; ok && weights.Ledger > 0 {
+		sum += weights.Ledger * table.ratio(entry)
+		total += weights.Ledger
+	}
+
+	if total == 0 {
+		return 0, false
+	}
+
+	return sum / total, true
+
+}
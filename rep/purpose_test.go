@@ -0,0 +1,103 @@
+package rep
+
+/*
+   Imports
+*/
+
+import (
+	"net"
+	"testing"
+
+	"github.com/No-Trust/peerster/common"
+)
+
+/*
+   Functions
+*/
+
+// TestScore tests the weighted mean of the signals of each purpose
+func TestScore(t *testing.T) {
+	peerSet := common.NewSet(net.UDPAddr{})
+	config := DefaultConfig()
+	config.Weights[PURPOSE_UPDATE] = Weights{Sig: 3, Contrib: 1}
+	table := NewReputationTable(&peerSet, config)
+
+	table.setRep(SIG_REP, "a", 0.9)
+	table.setRep(CONTRIB_REP, "a", 0.1)
+
+	if score, ok := table.Score(PURPOSE_KEY_SIGNING, "a"); !ok || score != 0.9 {
+		t.Fatalf("key-signing score should be the sig-based reputation, got %v (%v)", score, ok)
+	}
+	if score, ok := table.Score(PURPOSE_RELAY, "a"); !ok || score != 0.1 {
+		t.Fatalf("relay score should be the contrib-based reputation, got %v (%v)", score, ok)
+	}
+	if score, ok := table.Score(PURPOSE_UPDATE, "a"); !ok || common.AbsFloat32(score-0.7) > 1e-6 {
+		t.Fatalf("update score should be 0.7, got %v (%v)", score, ok)
+	}
+
+	// a peer without the signals of a purpose has no score
+	if _, ok := table.Score(PURPOSE_UPLOAD, "a"); ok {
+		t.Fatalf("upload score should not exist without a ledger entry")
+	}
+
+	// the signals known are used alone
+	table.setRep(SIG_REP, "b", 0.4)
+	if score, ok := table.Score(PURPOSE_UPDATE, "b"); !ok || score != 0.4 {
+		t.Fatalf("update score of a peer with only a sig-based reputation should be it, got %v (%v)", score, ok)
+	}
+
+	// the ledger signal follows the traffic
+	table.RecordDownload("c", TRAFFIC_DATA, 8000)
+	if score, ok := table.Score(PURPOSE_UPLOAD, "c"); !ok || score <= INIT_REP {
+		t.Fatalf("upload score of a peer that uploaded should be above the initial reputation, got %v (%v)", score, ok)
+	}
+}
+
+// TestRandomPeer tests the choice of a peer by score
+func TestRandomPeer(t *testing.T) {
+	peerSet := common.NewSet(net.UDPAddr{})
+	table := NewReputationTable(&peerSet, DefaultConfig())
+
+	table.setRep(CONTRIB_REP, "a", 0)
+	table.setRep(CONTRIB_REP, "b", 1)
+	table.setRep(CONTRIB_REP, "c", 1)
+
+	for i := 0; i < 100; i++ {
+		if peer := table.RandomPeer(PURPOSE_RELAY, nil); peer != "b" && peer != "c" {
+			t.Fatalf("peer with a null score chosen : %v", peer)
+		}
+		if peer := table.RandomPeer(PURPOSE_RELAY, func(p string) bool { return p == "b" }); peer != "c" {
+			t.Fatalf("excluded peer chosen : %v", peer)
+		}
+	}
+}
+
+// TestParseWeights tests the parsing of the weights of the purposes
+func TestParseWeights(t *testing.T) {
+	weights := DefaultWeights()
+	if err := ParseWeights("relay=contrib:1,ledger:0.5; update=sig:2", weights); err != nil {
+		t.Fatalf("could not parse weights: %v", err)
+	}
+	if weights[PURPOSE_RELAY] != (Weights{Contrib: 1, Ledger: 0.5}) || weights[PURPOSE_UPDATE] != (Weights{Sig: 2}) {
+		t.Fatalf("wrong weights parsed : %v", weights)
+	}
+	if weights[PURPOSE_KEY_SIGNING] != DefaultWeights()[PURPOSE_KEY_SIGNING] {
+		t.Fatalf("weights of the purposes not given should not change")
+	}
+
+	for _, spec := range []string{"relay", "unknown=sig:1", "relay=other:1", "relay=sig:x", "relay=sig"} {
+		if err := ParseWeights(spec, DefaultWeights()); err == nil {
+			t.Fatalf("invalid weights %v parsed", spec)
+		}
+	}
+
+	config := DefaultConfig()
+	config.Weights[PURPOSE_RELAY] = Weights{Sig: -1}
+	if config.Validate() == nil {
+		t.Fatalf("negative weights should not be valid")
+	}
+	config.Weights[PURPOSE_RELAY] = Weights{}
+	if config.Validate() == nil {
+		t.Fatalf("null weights should not be valid")
+	}
+}
@@ -0,0 +1,359 @@
+package rep
+
+/*
+   Imports
+*/
+
+import (
+	"math"
+	"sort"
+)
+
+/*
+   Type definitions
+*/
+
+/**
+ * The kind of a reputation.
+ */
+type RepKind string
+
+const (
+	SIG_REP     RepKind = "sig"     // signature-based reputation, peers given by name
+	CONTRIB_REP RepKind = "contrib" // contribution-based reputation, peers given by address
+)
+
+/**
+ * A peer along with its reputation, as returned by the
+ * ranked queries.
+ */
+type RankedPeer struct {
+	Peer string  // name or address of the peer, depending on the kind of reputation
+	Rep  float32 // local reputation of the peer
+}
+
+/**
+ * The keys of a peer->rep map ordered by increasing
+ * reputation, the ties being ordered by key. It is kept
+ * up to date by setRep and deleteRep.
+ */
+type rankIndex struct {
+	reps ReputationMap
+	keys []string
+}
+
+/*
+   Functions
+*/
+
+/**
+ * Returns the n peers with the highest reputation of the given
+ * kind, from the highest. The identities that cannot be reached
+ * (see ForEachContribRep) are skipped.
+ */
+func (table *ReputationTable) TopPeers(kind RepKind, n uint) []RankedPeer {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	index := table.index(kind)
+
+	return table.rankedPeers(kind, index.keys, n, true)
+
+}
+
+/**
+ * Returns the n peers with the lowest reputation of the given
+ * kind, from the lowest. The identities that cannot be reached
+ * (see ForEachContribRep) are skipped.
+ */
+func (table *ReputationTable) BottomPeers(kind RepKind, n uint) []RankedPeer {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	index := table.index(kind)
+
+	return table.rankedPeers(kind, index.keys, n, false)
+
+}
+
+/**
+ * Returns the peers whose reputation of the given kind is between
+ * the given bounds, included, from the lowest. The identities that
+ * cannot be reached (see ForEachContribRep) are skipped.
+ */
+func (table *ReputationTable) PeersInRange(kind RepKind, min, max float32) []RankedPeer {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	index := table.index(kind)
+
+	from := sort.Search(len(index.keys), func(i int) bool {
+		return index.reps[index.keys[i]] >= min
+	})
+	to := sort.Search(len(index.keys), func(i int) bool {
+		return index.reps[index.keys[i]] > max
+	})
+
+	if from >= to {
+		return []RankedPeer{}
+	}
+
+	return table.rankedPeers(kind, index.keys[from:to], uint(to-from), false)
+
+}
+
+/**
+ * Returns the reputation of the given kind under which the given
+ * share (between 0 and 1) of the peers of the table are, with the
+ * nearest-rank method, or false if the table has no reputation of
+ * that kind.
+ */
+func (table *ReputationTable) Percentile(kind RepKind, share float32) ( /*rep*/ float32 /*ok*/, bool) {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	index := table.index(kind)
+
+	if len(index.keys) == 0 {
+		return 0, false
+	}
+
+	rank := int(math.Ceil(float64(share*float32(len(index.keys))))) - 1
+	if rank < 0 {
+		rank = 0
+	} else if rank >= len(index.keys) {
+		rank = len(index.keys) - 1
+	}
+
+	return index.reps[index.keys[rank]], true
+
+}
+
+/**
+ * Returns the share of the peers of the table whose reputation
+ * of the given kind is lower than the one of the given peer, or
+ * false if the peer has no reputation of that kind.
+ */
+func (table *ReputationTable) PercentileRank(kind RepKind, peer string) ( /*share*/ float32 /*ok*/, bool) {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	index := table.index(kind)
+
+	rep, ok := index.reps[table.key(peer)]
+	if !ok {
+		return 0, false
+	}
+
+	lower := sort.Search(len(index.keys), func(i int) bool {
+		return index.reps[index.keys[i]] >= rep
+	})
+
+	return float32(lower) / float32(len(index.keys)), true
+
+}
+
+/**
+ * Returns true if the peer stored under the given key is among
+ * the n peers with the highest reputation of the given kind,
+ * along with its reputation.
+ * Thread unsafe.
+ */
+func (table *ReputationTable) isTopKey(kind RepKind, key string, n uint) ( /*rep*/ float32 /*ok*/, bool) {
+
+	index := table.index(kind)
+
+	rep, ok := index.reps[key]
+	if !ok || n == 0 {
+		return 0, false
+	}
+
+	// Number of keys ranked after the given one
+	position := index.search(key)
+	if uint(len(index.keys)-1-position) >= n {
+		return 0, false
+	}
+
+	return rep, true
+
+}
+
+/**
+ * Returns at most n peers of the given kind from the given
+ * ordered keys, from the end if fromTop is set, skipping the
+ * identities that cannot be reached.
+ * Thread unsafe.
+ */
+func (table *ReputationTable) rankedPeers(kind RepKind, keys []string, n uint, fromTop bool) []RankedPeer {
+
+	peers := make([]RankedPeer, 0)
+	reps := table.reps(kind)
+
+	for i := 0; i < len(keys) && uint(len(peers)) < n; i++ {
+
+		key := keys[i]
+		if fromTop {
+			key = keys[len(keys)-1-i]
+		}
+
+		peer := table.sigHandle(key)
+		if kind == CONTRIB_REP {
+			peer = table.contribHandle(key)
+		}
+
+		if peer != "" {
+			peers = append(peers, RankedPeer{Peer: peer, Rep: reps[key]})
+		}
+
+	}
+
+	return peers
+
+}
+
+/**
+ * Returns the peer->rep map of the given kind.
+ * Thread unsafe.
+ */
+func (table *ReputationTable) reps(kind RepKind) ReputationMap {
+
+	if kind == SIG_REP {
+		return table.sigReps
+	}
+
+	return table.contribReps
+
+}
+
+/**
+ * Returns the index of the peer->rep map of the given kind.
+ * Thread unsafe.
+ */
+func (table *ReputationTable) index(kind RepKind) *rankIndex {
+
+	if kind == SIG_REP {
+		return table.sigIndex
+	}
+
+	return table.contribIndex
+
+}
+
+/**
+ * Sets the reputation of the given kind stored under the
+ * given key, keeping the index ordered.
+ * Thread unsafe.
+ */
+func (table *ReputationTable) setRep(kind RepKind, key string, rep float32) {
+
+	index := table.index(kind)
+
+	if _, ok := index.reps[key]; ok {
+		index.remove(key)
+	}
+
+	index.reps[key] = rep
+	index.insert(key)
+
+}
+
+/**
+ * Deletes the reputation of the given kind stored under the
+ * given key, keeping the index ordered.
+ * Thread unsafe.
+ */
+func (table *ReputationTable) deleteRep(kind RepKind, key string) {
+
+	index := table.index(kind)
+
+	if _, ok := index.reps[key]; ok {
+		index.remove(key)
+		delete(index.reps, key)
+	}
+
+}
+
+/**
+ * Returns a new index of the given peer->rep map.
+ */
+func newRankIndex(reps ReputationMap) *rankIndex {
+
+	index := &rankIndex{reps: reps}
+	index.rebuild()
+
+	return index
+
+}
+
+/**
+ * Orders the keys of the indexed map again, after the
+ * reputations were changed without setRep.
+ */
+func (index *rankIndex) rebuild() {
+
+	index.keys = make([]string, 0, len(index.reps))
+	for key := range index.reps {
+		index.keys = append(index.keys, key)
+	}
+
+	sort.Slice(index.keys, func(i, j int) bool {
+		return index.less(index.keys[i], index.keys[j])
+	})
+
+}
+
+/**
+ * Returns the position at which the given key is, or would
+ * be, in the ordered keys, given its current reputation.
+ */
+func (index *rankIndex) search(key string) int {
+
+	return sort.Search(len(index.keys), func(i int) bool {
+		return !index.less(index.keys[i], key)
+	})
+
+}
+
+/**
+ * Inserts the given key, whose reputation is set, at its position.
+ */
+func (index *rankIndex) insert(key string) {
+
+	position := index.search(key)
+
+	index.keys = append(index.keys, "")
+	copy(index.keys[position+1:], index.keys[position:])
+	index.keys[position] = key
+
+}
+
+/**
+ * Removes the given key, whose reputation is not yet changed.
+ */
+func (index *rankIndex) remove(key string) {
+
+	position := index.search(key)
+
+	if position < len(index.keys) && index.keys[position] == key {
+		index.keys = append(index.keys[:position], index.keys[position+1:]...)
+	}
+
+}
+
+/**
+ * Returns true if the first key is ordered before the second one.
+ */
+func (index *rankIndex) less(a, b string) bool {
+
+	if index.reps[a] != index.reps[b] {
+		return index.reps[a] < index.reps[b]
+	}
+
+	return a < b
+
+}
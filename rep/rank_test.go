@@ -0,0 +1,205 @@
+package rep
+
+/*
+   Imports
+*/
+
+import (
+	"math/rand"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/No-Trust/peerster/common"
+)
+
+/*
+   Functions
+*/
+
+/**
+ * Returns a new reputation table with the given
+ * signature-based reputations.
+ */
+func newRankedTable(reps ReputationMap) *ReputationTable {
+
+	peerSet := common.NewSet(net.UDPAddr{})
+	table := NewReputationTable(&peerSet, DefaultConfig())
+
+	for peer, rep := range reps {
+		table.setRep(SIG_REP, peer, rep)
+	}
+
+	return table
+
+}
+
+/**
+ * Returns the names of the given ranked peers.
+ */
+func rankedNames(peers []RankedPeer) []string {
+
+	names := make([]string, len(peers))
+	for i, peer := range peers {
+		names[i] = peer.Peer
+	}
+
+	return names
+
+}
+
+// TestTopBottomPeers tests the top-k and bottom-k queries
+func TestTopBottomPeers(t *testing.T) {
+	table := newRankedTable(ReputationMap{"a": 0.9, "b": 0.1, "c": 0.5, "d": 0.7, "e": 0.3})
+
+	if got := rankedNames(table.TopPeers(SIG_REP, 3)); !reflect.DeepEqual(got, []string{"a", "d", "c"}) {
+		t.Fatalf("top 3 peers should be a, d, c, got %v", got)
+	}
+	if got := rankedNames(table.BottomPeers(SIG_REP, 2)); !reflect.DeepEqual(got, []string{"b", "e"}) {
+		t.Fatalf("bottom 2 peers should be b, e, got %v", got)
+	}
+	if got := table.TopPeers(SIG_REP, 10); len(got) != 5 || got[0].Rep != 0.9 {
+		t.Fatalf("top 10 peers should be the 5 peers from the highest, got %v", got)
+	}
+	if got := table.TopPeers(SIG_REP, 0); len(got) != 0 {
+		t.Fatalf("top 0 peers should be empty, got %v", got)
+	}
+	if got := table.TopPeers(CONTRIB_REP, 3); len(got) != 0 {
+		t.Fatalf("top contribution-based peers should be empty, got %v", got)
+	}
+
+	// ties are ordered by key
+	tied := newRankedTable(ReputationMap{"x": 0.5, "y": 0.5, "z": 0.5})
+	if got := rankedNames(tied.TopPeers(SIG_REP, 2)); !reflect.DeepEqual(got, []string{"z", "y"}) {
+		t.Fatalf("top 2 tied peers should be z, y, got %v", got)
+	}
+}
+
+// TestRankIndexUpdates tests that the ranks follow the changes of the reputations
+func TestRankIndexUpdates(t *testing.T) {
+	table := newRankedTable(ReputationMap{"a": 0.9, "b": 0.1, "c": 0.5})
+
+	// wrong signatures make a the lowest
+	table.DecreaseSigRep("a", 1)
+	table.DecreaseSigRep("a", 1)
+	if got := rankedNames(table.BottomPeers(SIG_REP, 1)); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Fatalf("a should be the lowest peer after wrong signatures, got %v", got)
+	}
+
+	// the reputations follow an identity
+	table.Identify("c", "fp")
+	if got := table.TopPeers(SIG_REP, 1); len(got) != 1 || got[0].Peer != "c" || got[0].Rep != 0.5 {
+		t.Fatalf("c should be the highest peer after being identified, got %v", got)
+	}
+	if len(table.sigIndex.keys) != 3 {
+		t.Fatalf("index should hold 3 keys after the migration, got %v", table.sigIndex.keys)
+	}
+
+	// the decay keeps the index ordered
+	table.lastDecay = table.lastDecay.Add(-table.config.SigHalfLife)
+	table.Decay()
+	assertOrdered(t, table.sigIndex)
+
+	// the contribution-based ranks follow the ledger
+	table.RecordDownload("p", TRAFFIC_DATA, 10000)
+	table.RecordUpload("q", TRAFFIC_DATA, 10000)
+	if got := rankedNames(table.TopPeers(CONTRIB_REP, 2)); !reflect.DeepEqual(got, []string{"p", "q"}) {
+		t.Fatalf("top contribution-based peers should be p, q, got %v", got)
+	}
+}
+
+// TestRankIndexRandom tests the index against a sort after random changes
+func TestRankIndexRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	table := newRankedTable(ReputationMap{})
+	peers := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+
+	for i := 0; i < 1000; i++ {
+		peer := peers[r.Intn(len(peers))]
+		if r.Intn(5) == 0 {
+			table.deleteRep(SIG_REP, peer)
+		} else {
+			// few distinct values, for ties
+			table.setRep(SIG_REP, peer, float32(r.Intn(5))/4)
+		}
+		assertOrdered(t, table.sigIndex)
+	}
+}
+
+// assertOrdered fails if the keys of the index are not the ones of its map, ordered
+func assertOrdered(t *testing.T, index *rankIndex) {
+	if len(index.keys) != len(index.reps) {
+		t.Fatalf("index has %d keys for %d reputations", len(index.keys), len(index.reps))
+	}
+	if !sort.SliceIsSorted(index.keys, func(i, j int) bool { return index.less(index.keys[i], index.keys[j]) }) {
+		t.Fatalf("index keys are not ordered : %v", index.keys)
+	}
+	for _, key := range index.keys {
+		if _, ok := index.reps[key]; !ok {
+			t.Fatalf("index key %v has no reputation", key)
+		}
+	}
+}
+
+// TestPercentiles tests the percentile queries
+func TestPercentiles(t *testing.T) {
+	table := newRankedTable(ReputationMap{"a": 0.1, "b": 0.2, "c": 0.3, "d": 0.4, "e": 0.5,
+		"f": 0.6, "g": 0.7, "h": 0.8, "i": 0.9, "j": 1.0})
+
+	cases := []struct {
+		share float32
+		rep   float32
+	}{{0, 0.1}, {0.1, 0.1}, {0.25, 0.3}, {0.5, 0.5}, {0.9, 0.9}, {1, 1.0}, {2, 1.0}}
+	for _, c := range cases {
+		if rep, ok := table.Percentile(SIG_REP, c.share); !ok || rep != c.rep {
+			t.Fatalf("percentile %v should be %v, got %v (%v)", c.share, c.rep, rep, ok)
+		}
+	}
+
+	if share, ok := table.PercentileRank(SIG_REP, "a"); !ok || share != 0 {
+		t.Fatalf("percentile rank of the lowest peer should be 0, got %v (%v)", share, ok)
+	}
+	if share, ok := table.PercentileRank(SIG_REP, "f"); !ok || share != 0.5 {
+		t.Fatalf("percentile rank of f should be 0.5, got %v (%v)", share, ok)
+	}
+	if _, ok := table.PercentileRank(SIG_REP, "unknown"); ok {
+		t.Fatalf("percentile rank of an unknown peer should not exist")
+	}
+	if _, ok := table.Percentile(CONTRIB_REP, 0.5); ok {
+		t.Fatalf("percentile of an empty kind should not exist")
+	}
+}
+
+// TestPeersInRange tests the range queries
+func TestPeersInRange(t *testing.T) {
+	table := newRankedTable(ReputationMap{"a": 0.1, "b": 0.3, "c": 0.3, "d": 0.6, "e": 0.9})
+
+	if got := rankedNames(table.PeersInRange(SIG_REP, 0.3, 0.6)); !reflect.DeepEqual(got, []string{"b", "c", "d"}) {
+		t.Fatalf("peers between 0.3 and 0.6 should be b, c, d, got %v", got)
+	}
+	if got := table.PeersInRange(SIG_REP, 0.7, 0.8); len(got) != 0 {
+		t.Fatalf("no peer should be between 0.7 and 0.8, got %v", got)
+	}
+	if got := table.PeersInRange(SIG_REP, 0.9, 0.1); len(got) != 0 {
+		t.Fatalf("an empty range should give no peer, got %v", got)
+	}
+}
+
+// TestUpdateTrustGate tests that only the updates of the most reputable peers are applied
+func TestUpdateTrustGate(t *testing.T) {
+	table := newRankedTable(ReputationMap{"a": 0.9, "b": 0.8, "c": 0.7, "d": 0.2, "target": 0.5})
+	table.config.RequestPeerCount = 3
+
+	// d is not among the 3 most reputable peers
+	table.applyUpdate(&RepUpdate{SigReps: ReputationMap{"target": 0}}, "d")
+	if rep, _ := table.GetSigRep("target"); rep != 0.5 {
+		t.Fatalf("update of a peer that is not among the most reputable should be ignored, got %v", rep)
+	}
+
+	// c is, even though the lowest of them
+	table.applyUpdate(&RepUpdate{SigReps: ReputationMap{"target": 0}}, "c")
+	if rep, _ := table.GetSigRep("target"); rep >= 0.5 {
+		t.Fatalf("update of a most reputable peer should be applied, got %v", rep)
+	}
+}
@@ -18,14 +18,25 @@ func (table *ReputationTable) InitSigRepForPeer(peer string) {
 
 	table.mutex.Lock()
 
-	if _, ok := table.sigReps[peer]; !ok {
-		table.sigReps[peer] = INIT_REP
-	}
+	table.initSigRep(table.key(peer))
 
 	table.mutex.Unlock()
 
 }
 
+/**
+ * Initializes the signature-based reputation stored
+ * under the given key if it does not exist.
+ * Thread unsafe.
+ */
+func (table *ReputationTable) initSigRep(key string) {
+
+	if _, ok := table.sigReps[key]; !ok {
+		table.setRep(SIG_REP, key, INIT_REP)
+	}
+
+}
+
 /**
  * Returns the signature-based reputation of the given peer.
  */
@@ -34,7 +45,7 @@ func (table *ReputationTable) GetSigRep(peer string) ( /*rep*/ float32 /*ok*/, b
 	table.mutex.Lock()
 
 	// Get the reputation from the table
-	rep, ok := table.sigReps[peer]
+	rep, ok := table.sigReps[table.key(peer)]
 
 	table.mutex.Unlock()
 
@@ -44,26 +55,51 @@ func (table *ReputationTable) GetSigRep(peer string) ( /*rep*/ float32 /*ok*/, b
 }
 
 /**
- * Returns the signature-based reputation of the given peer.
- * Same as GetSigRep, for awot's ReputationTable interface compatibility.
+ * Returns the key-signing score of the given peer (see Score),
+ * by default its signature-based reputation, the global one if
+ * enabled (see Config).
+ * For awot's ReputationTable interface compatibility.
  */
 func (table *ReputationTable) Reputation(peer string) (float32, bool) {
-	return table.GetSigRep(peer)
+	return table.Score(PURPOSE_KEY_SIGNING, peer)
 }
 
 /**
  * Performs an operation for each entry in the signature-based
  * reputation table. The operation is defined as a callback
- * function that takes a peer and a reputation as parameters.
+ * function that takes a peer and a reputation as parameters,
+ * the peer being given by name.
  */
 func (table *ReputationTable) ForEachSigRep(callback func( /*peer*/ string /*rep*/, float32)) {
 
 	// Loop through the entries
-	for peer, rep := range table.sigReps {
+	for key, rep := range table.sigReps {
 		// Call the given callback for each (peer, rep) pair
-		callback(peer, rep)
+		callback(table.sigHandle(key), rep)
+	}
+
+}
+
+/**
+ * Raises the signature-based and contribution-based reputations
+ * of the given peer that are lower than the given reputation to
+ * it, e.g. when a peer is given a new chance.
+ */
+func (table *ReputationTable) Rehabilitate(peer string, floor float32) {
+
+	table.mutex.Lock()
+
+	key := table.key(peer)
+
+	for _, kind := range []RepKind{SIG_REP, CONTRIB_REP} {
+		if rep, ok := table.reps(kind)[key]; ok && rep < floor {
+			table.setRep(kind, key, floor)
+			table.audit(kind, key, CAUSE_REHABILITATION, "", 0, rep, floor)
+		}
 	}
 
+	table.mutex.Unlock()
+
 }
 
 func (table *ReputationTable) DecreaseSigRep(peer string, confidence float32) {
@@ -80,28 +116,37 @@ func (table *ReputationTable) IncreaseSigRep(peer string, confidence float32) {
  */
 func (table *ReputationTable) updateSigRep(peer string, confidence float32, correctSig bool) {
 
-	table.InitSigRepForPeer(peer)
-
 	table.mutex.Lock()
 
+	peer = table.key(peer)
+	table.initSigRep(peer)
+	touch(table.sigTimes, peer)
+
+	before := table.sigReps[peer]
+	cause := CAUSE_INVALID_SIGNATURE
+
 	// If the signature is correct, increase the reputation
 	// of the sending peer linearly by a factor that depends
 	// on the confidence level in the public key association
 	if correctSig {
 
-		table.sigReps[peer] = common.ClampFloat32(table.sigReps[peer]+
-			sigRepIncreaseFactor(confidence), MIN_REP, MAX_REP)
+		cause = CAUSE_VALID_SIGNATURE
+
+		table.setRep(SIG_REP, peer, common.ClampFloat32(table.sigReps[peer]+
+			table.sigRepIncreaseFactor(confidence), MIN_REP, MAX_REP))
 
 		// Otherwise, decrease the reputation of the sending peer
 		// exponentially by a factor that depends on the confidence
 		// level in the public key association
 	} else {
 
-		table.sigReps[peer] = common.ClampFloat32(table.sigReps[peer]*
-			sigRepDecreaseFactor(confidence), MIN_REP, MAX_REP)
+		table.setRep(SIG_REP, peer, common.ClampFloat32(table.sigReps[peer]*
+			table.sigRepDecreaseFactor(confidence), MIN_REP, MAX_REP))
 
 	}
 
+	table.audit(SIG_REP, peer, cause, "", confidence, before, table.sigReps[peer])
+
 	table.mutex.Unlock()
 
 }
@@ -110,14 +155,14 @@ func (table *ReputationTable) updateSigRep(peer string, confidence float32, corr
  * Returns the signature-based reputation increase factor
  * to be used for the given level of confidence.
  */
-func sigRepIncreaseFactor(confidence float32) float32 {
-	return confidence * SIG_INCREASE_LIMIT
+func (table *ReputationTable) sigRepIncreaseFactor(confidence float32) float32 {
+	return confidence * table.config.SigIncreaseLimit
 }
 
 /**
  * Returns the signature-based reputation decrease factor
  * to be used for the given level of confidence.
  */
-func sigRepDecreaseFactor(confidence float32) float32 {
-	return 1 - confidence*SIG_DECREASE_LIMIT
+func (table *ReputationTable) sigRepDecreaseFactor(confidence float32) float32 {
+	return 1 - confidence*table.config.SigDecreaseLimit
 }
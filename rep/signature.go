@@ -0,0 +1,124 @@
+package rep
+
+/*
+   Imports
+*/
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/No-Trust/peerster/awot"
+)
+
+/*
+   Constants
+*/
+
+const repUpdatePrefix = "REPUPDATE"
+
+/*
+   Functions
+*/
+
+/**
+ * Returns the SHA-256 hash of the data signed in this update :
+ * its issuer, timestamp, sequence number and reputations, the
+ * latter being sorted by peer.
+ */
+func (update *RepUpdate) Hash() []byte {
+
+	hash := sha256.New()
+
+	hash.Write([]byte(repUpdatePrefix))
+	hash.Write([]byte(update.Issuer))
+	binary.Write(hash, binary.BigEndian, update.Timestamp)
+	binary.Write(hash, binary.BigEndian, update.Seq)
+
+	// Separate the two maps, so that an entry
+	// cannot be moved from one to the other
+	for i, reps := range []ReputationMap{update.SigReps, update.ContribReps} {
+
+		binary.Write(hash, binary.BigEndian, uint32(i))
+		binary.Write(hash, binary.BigEndian, uint32(len(reps)))
+
+		peers := make([]string, 0, len(reps))
+		for peer := range reps {
+			peers = append(peers, peer)
+		}
+		sort.Strings(peers)
+
+		for _, peer := range peers {
+			binary.Write(hash, binary.BigEndian, uint32(len(peer)))
+			hash.Write([]byte(peer))
+			binary.Write(hash, binary.BigEndian, math.Float32bits(reps[peer]))
+		}
+
+	}
+
+	return hash.Sum(nil)
+
+}
+
+/**
+ * Signs the given update in the name of the given issuer with
+ * the given private key, after setting its timestamp and a new
+ * sequence number. The sequence numbers start from the current
+ * time, so that they keep increasing when the gossiper restarts.
+ */
+func (table *ReputationTable) SignUpdate(update *RepUpdate, issuer string, key crypto.Signer) error {
+
+	now := time.Now()
+
+	table.mutex.Lock()
+
+	if table.seq == 0 {
+		table.seq = uint64(now.UnixNano())
+	}
+	table.seq++
+	seq := table.seq
+
+	table.mutex.Unlock()
+
+	update.Issuer = issuer
+	update.Timestamp = now.UnixNano()
+	update.Seq = seq
+
+	signature, err := awot.Sign(key, update.Hash())
+	if err != nil {
+		return err
+	}
+	update.Signature = signature
+
+	return nil
+
+}
+
+/**
+ * Records the sequence number of the given update, and returns
+ * false if an update of the same kind with a sequence number at
+ * least as high was already accepted from its issuer.
+ */
+func (table *ReputationTable) checkSeq(update *RepUpdate) bool {
+
+	kind := "/contrib"
+	if update.SigReps != nil {
+		kind = "/sig"
+	}
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	if last, ok := table.lastSeqs[update.Issuer+kind]; ok && update.Seq <= last {
+		return false
+	}
+
+	table.lastSeqs[update.Issuer+kind] = update.Seq
+
+	return true
+
+}
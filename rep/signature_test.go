@@ -0,0 +1,113 @@
+package rep
+
+/*
+   Imports
+*/
+
+import (
+	"crypto"
+	"testing"
+	"time"
+
+	"github.com/No-Trust/peerster/awot"
+)
+
+/*
+   Functions
+*/
+
+// TestUpdateRejections tests each reason for which a reputation update is rejected
+func TestUpdateRejections(t *testing.T) {
+	issuer, forger := newTestKey(t), newTestKey(t)
+
+	// resign signs the update again with the given key, after it was changed
+	resign := func(update *RepUpdate, key crypto.Signer) {
+		signature, err := awot.Sign(key, update.Hash())
+		if err != nil {
+			t.Fatal(err)
+		}
+		update.Signature = signature
+	}
+
+	cases := []struct {
+		name     string
+		sender   string
+		key      crypto.PublicKey
+		prepare  func(table *ReputationTable, update *RepUpdate)
+		rejected bool
+		penalty  bool // the issuer loses signature-based reputation
+	}{
+		{name: "valid", sender: "issuer", key: issuer.Public()},
+		{name: "empty", sender: "issuer", key: issuer.Public(), rejected: true,
+			prepare: func(table *ReputationTable, update *RepUpdate) {
+				update.SigReps = nil
+			}},
+		{name: "no key", sender: "issuer", rejected: true},
+		{name: "no issuer", sender: "issuer", key: issuer.Public(), rejected: true,
+			prepare: func(table *ReputationTable, update *RepUpdate) {
+				update.Issuer = ""
+			}},
+		{name: "forged by an unauthenticated sender", sender: "issuer", key: issuer.Public(), rejected: true,
+			prepare: func(table *ReputationTable, update *RepUpdate) {
+				resign(update, forger)
+			}},
+		{name: "forged from the bound address of the issuer", sender: "1.1.1.1:1", key: issuer.Public(),
+			rejected: true, penalty: true,
+			prepare: func(table *ReputationTable, update *RepUpdate) {
+				table.bind("1.1.1.1:1", table.identify("issuer", awot.Fingerprint(issuer.Public())))
+				resign(update, forger)
+			}},
+		{name: "forged from another bound address", sender: "6.6.6.6:6", key: issuer.Public(), rejected: true,
+			prepare: func(table *ReputationTable, update *RepUpdate) {
+				table.bind("6.6.6.6:6", table.identify("mallory", "fp"))
+				resign(update, forger)
+			}},
+		{name: "stale", sender: "issuer", key: issuer.Public(), rejected: true,
+			prepare: func(table *ReputationTable, update *RepUpdate) {
+				update.Timestamp = time.Now().Add(-2 * REP_UPDATE_MAX_AGE).UnixNano()
+				resign(update, issuer)
+			}},
+		{name: "from the future", sender: "issuer", key: issuer.Public(), rejected: true,
+			prepare: func(table *ReputationTable, update *RepUpdate) {
+				update.Timestamp = time.Now().Add(2 * REP_UPDATE_MAX_SKEW).UnixNano()
+				resign(update, issuer)
+			}},
+		{name: "replayed", sender: "issuer", key: issuer.Public(), rejected: true,
+			prepare: func(table *ReputationTable, update *RepUpdate) {
+				table.lastSeqs["issuer/sig"] = update.Seq
+			}},
+		{name: "older sequence number", sender: "issuer", key: issuer.Public(), rejected: true,
+			prepare: func(table *ReputationTable, update *RepUpdate) {
+				table.lastSeqs["issuer/sig"] = update.Seq + 1
+			}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			table := newRankedTable(ReputationMap{"issuer": 0.9, "target": 0.5})
+			table.config.RequestPeerCount = 3
+
+			update := &RepUpdate{SigReps: ReputationMap{"target": 0}}
+			if err := newRankedTable(ReputationMap{}).SignUpdate(update, "issuer", issuer); err != nil {
+				t.Fatal(err)
+			}
+			if c.prepare != nil {
+				c.prepare(table, update)
+			}
+
+			err := table.UpdateReputations(update, c.sender, c.key, 1)
+			if rejected := err != nil; rejected != c.rejected {
+				t.Fatalf("update should be rejected : %v, got %v", c.rejected, err)
+			}
+
+			if rep, _ := table.GetSigRep("target"); (rep < 0.5) == c.rejected {
+				t.Fatalf("update should be applied : %v, got target reputation %v", !c.rejected, rep)
+			}
+
+			// an applied update changes the reputation of its issuer by its distance
+			if rep, _ := table.GetSigRep("issuer"); c.rejected && (rep < 0.9) != c.penalty {
+				t.Fatalf("issuer should be penalized : %v, got reputation %v", c.penalty, rep)
+			}
+		})
+	}
+}
@@ -0,0 +1,661 @@
+package rep
+
+/*
+   Imports
+*/
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/No-Trust/peerster/awot"
+	"github.com/No-Trust/peerster/common"
+)
+
+/*
+   Type definitions
+*/
+
+/**
+ * The scripted behaviour of a simulated peer.
+ */
+type behaviour string
+
+const (
+	HONEST     behaviour = "honest"    // serves data, signs correctly, sends its true reputations
+	FREE_RIDER behaviour = "freerider" // downloads data but never serves any
+	BAD_SIGNER behaviour = "badsigner" // signs most of its messages with a forged key, and vouches for forged keys of the others
+	COLLUDER   behaviour = "colluder"  // only serves the members of its ring, and praises them in its updates
+	LIAR       behaviour = "liar"      // sends the opposite of its reputations in its updates
+)
+
+/**
+ * The parameters of a simulation.
+ */
+type simConfig struct {
+	Rep          Config            // parameters of the reputation tables
+	Peers        map[behaviour]int // number of peers of each behaviour
+	Rounds       int               // number of rounds
+	Exchanges    int               // data requests, signed messages and key signatures sent by each peer per round
+	Introducers  int               // number of peers whose key each peer trusts from the start, the others being learned from key signatures
+	ChunkSize    int               // bytes of a data reply
+	ForgeRate    float64           // share of the messages and key signatures of a bad signer using a forged key
+	UpdateRounds int               // number of rounds between two reputation update requests, 0 for none
+	Threshold    float32           // reputation under which a peer is classified as misbehaving, and its key signatures ignored
+	Seed         int64             // seed of the random choices
+}
+
+/**
+ * A simulated peer : its reputation table and key ring,
+ * its key and the forged key the bad signers vouch for
+ * in its name.
+ */
+type simPeer struct {
+	name      string
+	behaviour behaviour
+	table     *ReputationTable
+	ring      awot.KeyRing
+	key       ed25519.PrivateKey
+	forged    ed25519.PrivateKey
+}
+
+/**
+ * A simulation of a network of peers running the reputation
+ * system in-process, without gossiping : the peers exchange
+ * data, signed messages and signed reputation updates by
+ * calling the tables of each other.
+ */
+type simulation struct {
+	config simConfig
+	peers  []*simPeer
+	byName map[string]*simPeer
+	rand   *rand.Rand
+}
+
+/**
+ * The state of a simulation after a round, as seen by the
+ * honest peers : the mean reputation of each behaviour, the
+ * share of the peers classified wrongly, per kind of
+ * reputation, and the share of the peers whose key in the
+ * key ring is a forged one.
+ */
+type simPoint struct {
+	Round           int
+	SigMeans        map[behaviour]float32
+	ContribMeans    map[behaviour]float32
+	SigMisclass     float32
+	ContribMisclass float32
+	ForgedKeys      float32
+}
+
+/*
+   Functions
+*/
+
+// Writes the convergence curves of the simulations to the given file, as CSV
+var simOutput = flag.String("simout", "", "file to write the convergence curves of the reputation simulations to")
+
+/**
+ * Returns the parameters of a simulation with the default
+ * reputation parameters and the given peers.
+ */
+func defaultSimConfig(peers map[behaviour]int) simConfig {
+
+	return simConfig{
+		Rep:          DefaultConfig(),
+		Peers:        peers,
+		Rounds:       40,
+		Exchanges:    3,
+		Introducers:  3,
+		ChunkSize:    8 * 1024,
+		ForgeRate:    0.8,
+		UpdateRounds: 2,
+		Threshold:    0.4,
+		Seed:         1,
+	}
+
+}
+
+/**
+ * Returns a new simulation of the given network, in which
+ * each peer trusts the keys of a few random peers, and has
+ * to learn the keys of the others from key signatures.
+ */
+func newSimulation(config simConfig) *simulation {
+
+	peerSet := common.NewSet(net.UDPAddr{})
+
+	sim := &simulation{
+		config: config,
+		byName: make(map[string]*simPeer),
+		rand:   rand.New(rand.NewSource(config.Seed)),
+	}
+
+	// Behaviours in a fixed order, for the simulation to be reproducible
+	for _, b := range []behaviour{HONEST, FREE_RIDER, BAD_SIGNER, COLLUDER, LIAR} {
+		for i := 0; i < config.Peers[b]; i++ {
+
+			peer := &simPeer{
+				name:      fmt.Sprintf("%s%d", b, i),
+				behaviour: b,
+				table:     NewReputationTable(&peerSet, config.Rep),
+				key:       sim.newKey(),
+				forged:    sim.newKey(),
+			}
+
+			sim.peers = append(sim.peers, peer)
+			sim.byName[peer.name] = peer
+
+		}
+	}
+
+	for _, peer := range sim.peers {
+
+		records := make([]awot.TrustedKeyRecord, 0)
+		introducers := make(map[*simPeer]bool)
+		for len(introducers) < config.Introducers && len(introducers) < len(sim.peers)-1 {
+			other := sim.randomPeer(peer)
+			if introducers[other] {
+				continue
+			}
+			introducers[other] = true
+			records = append(records, awot.TrustedKeyRecord{
+				KeyRecord:  awot.KeyRecord{Owner: other.name, KeyPub: other.key.Public()},
+				Confidence: 1,
+			})
+		}
+
+		peer.ring = awot.NewKeyRing(peer.name, peer.key.Public(), records, 0)
+
+	}
+
+	return sim
+
+}
+
+/**
+ * Returns a new key drawn from the random source of the simulation.
+ */
+func (sim *simulation) newKey() ed25519.PrivateKey {
+
+	seed := make([]byte, ed25519.SeedSize)
+	sim.rand.Read(seed)
+
+	return ed25519.NewKeyFromSeed(seed)
+
+}
+
+/**
+ * Runs the simulation and returns the state after each round.
+ */
+func (sim *simulation) run() []simPoint {
+
+	points := make([]simPoint, 0, sim.config.Rounds)
+
+	for round := 1; round <= sim.config.Rounds; round++ {
+
+		sim.round(round)
+		points = append(points, sim.measure(round))
+
+	}
+
+	return points
+
+}
+
+/**
+ * Runs a round of the simulation : each peer requests data
+ * from, sends a signed message and a key signature to random
+ * peers, and every few rounds requests reputation updates
+ * from its most reputable peers.
+ */
+func (sim *simulation) round(round int) {
+
+	for _, peer := range sim.peers {
+		for i := 0; i < sim.config.Exchanges; i++ {
+			sim.requestData(peer, sim.randomPeer(peer))
+			sim.sendMessage(peer, sim.randomPeer(peer), round)
+			sim.signKey(peer, sim.randomPeer(peer), sim.randomPeer(peer))
+		}
+	}
+
+	if sim.config.UpdateRounds > 0 && round%sim.config.UpdateRounds == 0 {
+		for _, peer := range sim.peers {
+			sim.requestUpdates(peer)
+		}
+	}
+
+}
+
+/**
+ * Returns a random peer other than the given one.
+ */
+func (sim *simulation) randomPeer(peer *simPeer) *simPeer {
+
+	for {
+		if other := sim.peers[sim.rand.Intn(len(sim.peers))]; other != peer {
+			return other
+		}
+	}
+
+}
+
+/**
+ * Simulates a data request from the given requester to the
+ * given provider, which serves it according to its behaviour.
+ */
+func (sim *simulation) requestData(requester, provider *simPeer) {
+
+	switch provider.behaviour {
+	case FREE_RIDER:
+		return
+	case COLLUDER:
+		if requester.behaviour != COLLUDER {
+			return
+		}
+	}
+
+	provider.table.RecordUpload(requester.name, TRAFFIC_DATA, sim.config.ChunkSize)
+	requester.table.RecordDownload(provider.name, TRAFFIC_DATA, sim.config.ChunkSize)
+
+}
+
+/**
+ * Simulates a signed message from the given sender to the
+ * given receiver, which verifies it with the key it trusts
+ * for the sender and updates its signature-based reputation.
+ */
+func (sim *simulation) sendMessage(sender, receiver *simPeer, round int) {
+
+	content := make([]byte, 8)
+	binary.BigEndian.PutUint64(content, uint64(round))
+	hashed := sha256.Sum256(append([]byte(sender.name), content...))
+
+	key := sender.key
+	if sender.behaviour == BAD_SIGNER && sim.rand.Float64() < sim.config.ForgeRate {
+		key = sender.forged
+	}
+
+	signature, err := awot.Sign(key, hashed[:])
+	if err != nil {
+		panic(err)
+	}
+
+	record, ok := receiver.ring.GetRecord(sender.name)
+	if !ok {
+		return
+	}
+
+	if awot.VerifySignature(record.KeyPub, hashed[:], signature) == nil {
+		receiver.table.IncreaseSigRep(sender.name, record.Confidence)
+	} else {
+		receiver.table.DecreaseSigRep(sender.name, record.Confidence)
+	}
+
+}
+
+/**
+ * Simulates a key signature of the given signer for the key
+ * of the given owner, sent to the given receiver, which adds
+ * it to its key ring unless the signer's signature-based
+ * reputation is under the threshold, as done by the gossiper.
+ */
+func (sim *simulation) signKey(signer, owner, receiver *simPeer) {
+
+	if owner == receiver {
+		return
+	}
+
+	key := owner.key.Public()
+	if signer.behaviour == BAD_SIGNER && sim.rand.Float64() < sim.config.ForgeRate {
+		key = owner.forged.Public()
+	}
+
+	if rep, ok := receiver.table.GetSigRep(signer.name); ok && rep < sim.config.Threshold {
+		return
+	}
+
+	repOwner, ok := receiver.table.Score(PURPOSE_KEY_SIGNING, owner.name)
+	if !ok {
+		repOwner = INIT_REP
+	}
+
+	receiver.ring.Add(awot.KeyRecord{Owner: owner.name, KeyPub: key}, signer.name, repOwner)
+
+}
+
+/**
+ * Simulates the reputation update requests of the given peer
+ * to its most reputable peers, as done by the gossiper.
+ */
+func (sim *simulation) requestUpdates(peer *simPeer) {
+
+	count := sim.config.Rep.RequestPeerCount
+
+	for _, ranked := range peer.table.TopPeers(SIG_REP, count) {
+		if updater, ok := sim.byName[ranked.Peer]; ok {
+			sim.sendUpdate(updater, peer, updater.table.GetSigUpdate())
+		}
+	}
+
+	for _, ranked := range peer.table.TopPeers(CONTRIB_REP, count) {
+		if updater, ok := sim.byName[ranked.Peer]; ok {
+			sim.sendUpdate(updater, peer, updater.table.GetContribUpdate())
+		}
+	}
+
+}
+
+/**
+ * Simulates a reputation update from the given updater to the
+ * given receiver, altered according to the updater's behaviour.
+ */
+func (sim *simulation) sendUpdate(updater, receiver *simPeer, update *RepUpdate) {
+
+	reps := update.SigReps
+	if reps == nil {
+		reps = update.ContribReps
+	}
+
+	switch updater.behaviour {
+	case LIAR:
+		for name, rep := range reps {
+			reps[name] = MAX_REP - (rep - MIN_REP)
+		}
+	case COLLUDER:
+		for _, peer := range sim.peers {
+			if peer.behaviour == COLLUDER && peer != updater {
+				reps[peer.name] = MAX_REP
+			} else if peer != updater {
+				reps[peer.name] = MIN_REP
+			}
+		}
+	}
+
+	if err := updater.table.SignUpdate(update, updater.name, updater.key); err != nil {
+		panic(err)
+	}
+
+	record, ok := receiver.ring.GetRecord(updater.name)
+	if !ok {
+		return
+	}
+
+	receiver.table.UpdateReputations(update, updater.name, record.KeyPub, record.Confidence)
+
+}
+
+/**
+ * Returns the state of the simulation after the given round,
+ * as seen by the honest peers. A peer is expected to be
+ * classified as misbehaving for a kind of reputation if its
+ * behaviour deviates in that kind : the free riders and the
+ * colluders in contribution, the bad signers in signatures,
+ * and the liars and the colluders in both, through their
+ * updates.
+ */
+func (sim *simulation) measure(round int) simPoint {
+
+	sigSums := make(map[behaviour]float32)
+	contribSums := make(map[behaviour]float32)
+	counts := make(map[behaviour]float32)
+
+	var sigWrong, contribWrong, forged, total float32 = 0, 0, 0, 0
+
+	for _, observer := range sim.peers {
+
+		if observer.behaviour != HONEST {
+			continue
+		}
+
+		for _, peer := range sim.peers {
+
+			if peer == observer {
+				continue
+			}
+
+			sigRep := repOrInit(observer.table.GetSigRep(peer.name))
+			contribRep := repOrInit(observer.table.GetContribRep(peer.name))
+
+			sigSums[peer.behaviour] += sigRep
+			contribSums[peer.behaviour] += contribRep
+			counts[peer.behaviour]++
+			total++
+
+			sigBad := peer.behaviour == BAD_SIGNER || peer.behaviour == LIAR || peer.behaviour == COLLUDER
+			contribBad := peer.behaviour == FREE_RIDER || peer.behaviour == LIAR || peer.behaviour == COLLUDER
+
+			if (sigRep < sim.config.Threshold) != sigBad {
+				sigWrong++
+			}
+			if (contribRep < sim.config.Threshold) != contribBad {
+				contribWrong++
+			}
+			if key, ok := observer.ring.GetKey(peer.name); ok && awot.Fingerprint(key) == awot.Fingerprint(peer.forged.Public()) {
+				forged++
+			}
+
+		}
+
+	}
+
+	point := simPoint{
+		Round:        round,
+		SigMeans:     make(map[behaviour]float32),
+		ContribMeans: make(map[behaviour]float32),
+	}
+
+	for b, count := range counts {
+		point.SigMeans[b] = sigSums[b] / count
+		point.ContribMeans[b] = contribSums[b] / count
+	}
+
+	if total > 0 {
+		point.SigMisclass = sigWrong / total
+		point.ContribMisclass = contribWrong / total
+		point.ForgedKeys = forged / total
+	}
+
+	return point
+
+}
+
+/**
+ * Returns the given reputation if it exists, INIT_REP otherwise.
+ */
+func repOrInit(rep float32, ok bool) float32 {
+
+	if !ok {
+		return INIT_REP
+	}
+
+	return rep
+
+}
+
+/**
+ * Runs a simulation with the given parameters, logs its
+ * convergence curves and appends them to the -simout file
+ * if given. Returns the state after the last round.
+ */
+func runSimulation(t *testing.T, config simConfig) simPoint {
+
+	points := newSimulation(config).run()
+
+	behaviours := make([]behaviour, 0)
+	for _, b := range []behaviour{HONEST, FREE_RIDER, BAD_SIGNER, COLLUDER, LIAR} {
+		if config.Peers[b] > 0 {
+			behaviours = append(behaviours, b)
+		}
+	}
+
+	header := []string{"round", "sigmisclass", "contribmisclass", "forgedkeys"}
+	for _, b := range behaviours {
+		header = append(header, "sig:"+string(b), "contrib:"+string(b))
+	}
+
+	lines := []string{strings.Join(header, ",")}
+	for _, point := range points {
+		line := []string{fmt.Sprint(point.Round),
+			fmt.Sprintf("%.3f", point.SigMisclass), fmt.Sprintf("%.3f", point.ContribMisclass), fmt.Sprintf("%.3f", point.ForgedKeys)}
+		for _, b := range behaviours {
+			line = append(line, fmt.Sprintf("%.3f", point.SigMeans[b]), fmt.Sprintf("%.3f", point.ContribMeans[b]))
+		}
+		lines = append(lines, strings.Join(line, ","))
+	}
+
+	t.Logf("%s\n%s", t.Name(), strings.Join(lines, "\n"))
+
+	if *simOutput != "" {
+
+		file, err := os.OpenFile(*simOutput, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatalf("could not open simulation output: %v", err)
+		}
+		defer file.Close()
+
+		fmt.Fprintf(file, "# %s\n%s\n", t.Name(), strings.Join(lines, "\n"))
+
+	}
+
+	return points[len(points)-1]
+
+}
+
+// TestSimulationHonest tests that a network of honest peers classifies few peers as misbehaving
+// The contribution-based reputations of honest peers spread around INIT_REP, as the exchanges are random
+func TestSimulationHonest(t *testing.T) {
+	last := runSimulation(t, defaultSimConfig(map[behaviour]int{HONEST: 12}))
+
+	if last.SigMisclass != 0 || last.ContribMisclass > 0.2 {
+		t.Fatalf("honest peers misclassified : sig %v, contrib %v", last.SigMisclass, last.ContribMisclass)
+	}
+	if last.SigMeans[HONEST] <= INIT_REP {
+		t.Fatalf("signature-based reputation of honest peers should increase, got %v", last.SigMeans[HONEST])
+	}
+}
+
+// TestSimulationFreeRiders tests that the free riders lose their contribution-based reputation
+func TestSimulationFreeRiders(t *testing.T) {
+	last := runSimulation(t, defaultSimConfig(map[behaviour]int{HONEST: 12, FREE_RIDER: 4}))
+
+	if last.ContribMeans[FREE_RIDER] >= last.ContribMeans[HONEST] {
+		t.Fatalf("free riders should have a lower contribution-based reputation than honest peers : %v >= %v",
+			last.ContribMeans[FREE_RIDER], last.ContribMeans[HONEST])
+	}
+	if last.ContribMisclass > 0.1 {
+		t.Fatalf("contribution-based misclassification rate too high : %v", last.ContribMisclass)
+	}
+}
+
+// TestSimulationBadSigners tests that the bad signers lose their signature-based reputation, and that
+// the forged keys they vouch for are seldom selected by the key rings of the honest peers
+func TestSimulationBadSigners(t *testing.T) {
+	last := runSimulation(t, defaultSimConfig(map[behaviour]int{HONEST: 12, BAD_SIGNER: 4}))
+
+	if last.SigMeans[BAD_SIGNER] >= last.SigMeans[HONEST] {
+		t.Fatalf("bad signers should have a lower signature-based reputation than honest peers : %v >= %v",
+			last.SigMeans[BAD_SIGNER], last.SigMeans[HONEST])
+	}
+	if last.SigMisclass > 0.1 {
+		t.Fatalf("signature-based misclassification rate too high : %v", last.SigMisclass)
+	}
+	if last.ForgedKeys > 0.1 {
+		t.Fatalf("share of forged keys in the key rings too high : %v", last.ForgedKeys)
+	}
+}
+
+// TestSimulationCollusion tests that a ring of colluders praising each other loses its contribution-based reputation
+// The colluders keep their signature-based one : as they sign correctly, they are among the most reputable peers
+// and their updates drag the honest peers down, so the signature-based misclassification is not bounded here
+func TestSimulationCollusion(t *testing.T) {
+	last := runSimulation(t, defaultSimConfig(map[behaviour]int{HONEST: 12, COLLUDER: 4}))
+
+	if last.ContribMeans[COLLUDER] >= last.ContribMeans[HONEST] {
+		t.Fatalf("colluders should have a lower contribution-based reputation than honest peers : %v >= %v",
+			last.ContribMeans[COLLUDER], last.ContribMeans[HONEST])
+	}
+	if last.ContribMisclass > 0.1 {
+		t.Fatalf("contribution-based misclassification rate too high : %v", last.ContribMisclass)
+	}
+}
+
+// TestSimulationLiars tests that peers sending the opposite of their reputations do not drag the honest peers down
+// The liars serve data and sign correctly, so they are not detected : at most the 4 liars out of the 15 peers
+// seen by an honest peer are misclassified
+func TestSimulationLiars(t *testing.T) {
+	last := runSimulation(t, defaultSimConfig(map[behaviour]int{HONEST: 12, LIAR: 4}))
+
+	if last.SigMisclass > 0.3 || last.ContribMisclass > 0.3 {
+		t.Fatalf("honest peers misclassified : sig %v, contrib %v", last.SigMisclass, last.ContribMisclass)
+	}
+	if last.SigMeans[HONEST] <= INIT_REP {
+		t.Fatalf("signature-based reputation of honest peers should increase, got %v", last.SigMeans[HONEST])
+	}
+}
+
+// TestSimulationMixed tests the reputations in a network with every behaviour
+// The colluders drag part of the honest peers down in signature-based reputation, as in TestSimulationCollusion
+func TestSimulationMixed(t *testing.T) {
+	last := runSimulation(t, defaultSimConfig(map[behaviour]int{
+		HONEST: 16, FREE_RIDER: 3, BAD_SIGNER: 3, COLLUDER: 3, LIAR: 3}))
+
+	if last.SigMisclass > 0.5 || last.ContribMisclass > 0.2 {
+		t.Fatalf("misclassification rates too high : sig %v, contrib %v", last.SigMisclass, last.ContribMisclass)
+	}
+	if last.ForgedKeys > 0.1 {
+		t.Fatalf("share of forged keys in the key rings too high : %v", last.ForgedKeys)
+	}
+	if last.SigMeans[BAD_SIGNER] >= last.SigMeans[HONEST] {
+		t.Fatalf("bad signers should have a lower signature-based reputation than honest peers : %v >= %v",
+			last.SigMeans[BAD_SIGNER], last.SigMeans[HONEST])
+	}
+	for _, b := range []behaviour{FREE_RIDER, COLLUDER} {
+		if last.ContribMeans[b] >= last.ContribMeans[HONEST] {
+			t.Fatalf("%v should have a lower contribution-based reputation than honest peers : %v >= %v",
+				b, last.ContribMeans[b], last.ContribMeans[HONEST])
+		}
+	}
+}
+
+// BenchmarkSimulationRound measures a round of a network with every behaviour
+func BenchmarkSimulationRound(b *testing.B) {
+	config := defaultSimConfig(map[behaviour]int{
+		HONEST: 32, FREE_RIDER: 4, BAD_SIGNER: 4, COLLUDER: 4, LIAR: 4})
+	config.UpdateRounds = 1
+	sim := newSimulation(config)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sim.round(i + 1)
+	}
+}
+
+// BenchmarkUpdateReputations measures the verification and application of a signed reputation update
+func BenchmarkUpdateReputations(b *testing.B) {
+	sim := newSimulation(defaultSimConfig(map[behaviour]int{HONEST: 32}))
+	for round := 1; round <= 5; round++ {
+		sim.round(round)
+	}
+	updater, receiver := sim.peers[0], sim.peers[1]
+	record, _ := receiver.ring.GetRecord(updater.name)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		update := updater.table.GetSigUpdate()
+		if err := updater.table.SignUpdate(update, updater.name, updater.key); err != nil {
+			b.Fatal(err)
+		}
+		if err := receiver.table.UpdateReputations(update, updater.name, record.KeyPub, record.Confidence); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
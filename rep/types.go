@@ -4,7 +4,10 @@ package rep
    Imports
 */
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 /*
    Type definitions
@@ -16,22 +19,66 @@ import "sync"
  */
 type ReputationMap map[string]float32
 
+/**
+ * A verified identity of a peer : its name along with the
+ * fingerprint of the public key trusted for that name.
+ */
+type Identity struct {
+	Name        string
+	Fingerprint string
+}
+
 /**
  * A data structure assotiating signature-based and
  * contribution-based reputations to peers using
  * ReputationMap fields.
+ * Reputations are keyed by identity (see Identity.Key) once
+ * the peer is identified, and by address (contribution-based)
+ * or by name (signature-based) until then.
  */
 type ReputationTable struct {
-	sigReps     ReputationMap
-	contribReps ReputationMap
-	mutex       *sync.Mutex
+	sigReps      ReputationMap
+	contribReps  ReputationMap
+	sigIndex     *rankIndex                // keys of sigReps ordered by reputation
+	contribIndex *rankIndex                // keys of contribReps ordered by reputation
+	identities   map[string]Identity       // identity key -> identity
+	names        map[string]string         // name -> identity key
+	bindings     map[string]string         // address -> identity key
+	addresses    map[string]string         // identity key -> last bound address
+	challenges   map[string]challenge      // address -> pending identity challenge, see Challenge
+	seq          uint64                    // sequence number of the last update signed
+	lastSeqs     map[string]uint64         // issuer/kind -> sequence number of the last update accepted
+	global       *globalTrust              // EigenTrust-like global reputation, see ComputeGlobalTrust
+	sigTimes     map[string]time.Time      // key -> time of the last sig-based interaction
+	contribTimes map[string]time.Time      // key -> time of the last contrib-based interaction
+	lastDecay    time.Time                 // time of the last decay of the reputations
+	ledger       map[string]*LedgerEntry   // key -> bytes exchanged with the peer
+	history      map[string][]HistoryEvent // key -> changes of the reputations of the peer, see GetHistory
+	traffic      map[string]float32        // key -> change of the contrib-based reputation by traffic not yet in the history
+	config       Config
+	mutex        *sync.Mutex
+}
+
+/**
+ * A challenge sent to an address, whose nonce must be
+ * signed by the peer at that address to bind it to its
+ * identity (see Bind).
+ */
+type challenge struct {
+	nonce []byte
+	sent  time.Time
 }
 
 /**
  * A reputation table update, holding either signature-
- * based and/or contribution-based reputations.
+ * based and/or contribution-based reputations, signed
+ * by its issuer (see SignUpdate).
  */
 type RepUpdate struct {
 	SigReps     ReputationMap
 	ContribReps ReputationMap
+	Issuer      string // name of the issuer
+	Timestamp   int64  // time of signature, in nanoseconds since the epoch
+	Seq         uint64 // sequence number, increasing for each update of the issuer
+	Signature   []byte // signature of the issuer, see Hash
 }
@@ -4,7 +4,14 @@ package rep
    Imports
 */
 
-import "github.com/No-Trust/peerster/common"
+import (
+	"crypto"
+	"errors"
+	"time"
+
+	"github.com/No-Trust/peerster/awot"
+	"github.com/No-Trust/peerster/common"
+)
 
 /*
    Functions
@@ -13,6 +20,10 @@ import "github.com/No-Trust/peerster/common"
 /**
  * Returns a new reputation update with the signature-
  * based and contribution-based reputations in this table.
+ * Both maps are keyed the same way : by identity for the
+ * identified peers, whose address-keyed and name-keyed
+ * reputations were merged, and by address or name for
+ * the others.
  */
 func (table *ReputationTable) GetUpdate() *RepUpdate {
 
@@ -78,6 +89,108 @@ func (table *ReputationTable) GetContribUpdate() *RepUpdate {
 
 }
 
+/**
+ * Returns a new reputation update with the reputations in
+ * this table keyed by the handles this gossiper uses for
+ * its peers : by name for the signature-based reputations
+ * and by address for the contribution-based ones.
+ */
+func (table *ReputationTable) GetPeerView() *RepUpdate {
+
+	repUpdate := RepUpdate{
+		SigReps:     make(ReputationMap),
+		ContribReps: make(ReputationMap),
+	}
+
+	table.mutex.Lock()
+
+	for key, rep := range table.sigReps {
+		repUpdate.SigReps[table.sigHandle(key)] = rep
+	}
+
+	for key, rep := range table.contribReps {
+		if peer := table.contribHandle(key); peer != "" {
+			repUpdate.ContribReps[peer] = rep
+		}
+	}
+
+	table.mutex.Unlock()
+
+	return &repUpdate
+
+}
+
+/**
+ * Verifies the given signed reputation update with the key of its
+ * issuer, and if it is valid, fresh and not replayed, updates the
+ * reputations in this table with it (see applyUpdate).
+ * An update whose signature does not match the key of its issuer
+ * decreases the signature-based reputation of the issuer by a factor
+ * that depends on the given confidence in the key, if the given
+ * sender is an address bound to the identity of the issuer (see
+ * Bind). Otherwise the sender is not authenticated, as the origin of
+ * a private message, and anyone could have sent the update in the
+ * name of the issuer : the update is only dropped.
+ * Returns an error if the update is rejected.
+ */
+func (table *ReputationTable) UpdateReputations(update *RepUpdate, sender string,
+	issuerKey crypto.PublicKey, confidence float32) error {
+
+	// Reject the invalid updates
+	if update.SigReps == nil && update.ContribReps == nil {
+		return errors.New("empty reputation update")
+	}
+
+	// Reject the updates that cannot be verified
+	if update.Issuer == "" || issuerKey == nil {
+		return errors.New("no trusted key for issuer " + update.Issuer)
+	}
+
+	// Reject the updates with an invalid signature, and penalize
+	// their issuer if they were received from its bound address
+	if err := awot.VerifySignature(issuerKey, update.Hash(), update.Signature); err != nil {
+
+		table.mutex.Lock()
+		key, identified := table.names[update.Issuer]
+		authenticated := identified && table.bindings[sender] == key
+		table.mutex.Unlock()
+
+		if authenticated {
+			table.DecreaseSigRep(update.Issuer, confidence)
+		}
+
+		return errors.New("invalid signature of reputation update from " + update.Issuer)
+
+	}
+
+	// Reject the stale updates
+	age := time.Since(time.Unix(0, update.Timestamp))
+	if age > table.config.UpdateMaxAge || age < -table.config.UpdateMaxSkew {
+		return errors.New("stale reputation update from " + update.Issuer)
+	}
+
+	// Reject the replayed updates
+	if !table.checkSeq(update) {
+		return errors.New("replayed reputation update from " + update.Issuer)
+	}
+
+	table.mutex.Lock()
+	global := table.config.GlobalTrust
+	if global {
+		table.recordOpinion(update)
+	}
+	table.mutex.Unlock()
+
+	// With the global reputation, the update is only
+	// taken into account by ComputeGlobalTrust
+	if !global {
+		table.applyUpdate(update, update.Issuer)
+	}
+
+	return nil
+
+}
+
 /**
  * Updates the reputations in this table with the ones in
  * the given reputation update weighted by the reputation
@@ -85,22 +198,22 @@ func (table *ReputationTable) GetContribUpdate() *RepUpdate {
  * reputation based on the degree of similarity between
  * their update and this table.
  */
-func (table *ReputationTable) UpdateReputations(update *RepUpdate, sender string) {
+func (table *ReputationTable) applyUpdate(update *RepUpdate, sender string) {
 
-	// The peer->rep map to update and the one
-	// in the update to use for updating
-	var refReps ReputationMap
+	// The kind of reputation to update and the
+	// peer->rep map in the update to use for updating
+	var kind RepKind
 	var senderReps ReputationMap
 
 	// If the signature-based map in the update is
 	// non-nil, then it is a signature-based update
 	if update.SigReps != nil {
-		refReps = table.sigReps
+		kind = SIG_REP
 		senderReps = update.SigReps
 		// Otherwise, if the contribution-based map in the update
 		// is non-nil, then it is a contribution-based update
 	} else if update.ContribReps != nil {
-		refReps = table.contribReps
+		kind = CONTRIB_REP
 		senderReps = update.ContribReps
 		// Otherwise, return as the update is invalid
 	} else {
@@ -109,43 +222,37 @@ func (table *ReputationTable) UpdateReputations(update *RepUpdate, sender string
 
 	table.mutex.Lock()
 
-	// Compute the highest reputations
-	highestReps := highestReps(refReps, REP_REQ_PEER_COUNT)
-
-	table.mutex.Unlock()
-
-	// The updater's reputation
-	var updaterRep float32
-	found := false
-
-	// Loop through the highest reputations and look for the updater
-	for peer, rep := range highestReps {
-		if peer == sender {
-			updaterRep = rep
-			found = true
-			break
-		}
-	}
+	// Key the sender and their reputations like this table,
+	// so that peers known under another name or address
+	// by the sender are matched
+	sender = table.key(sender)
+	senderReps = table.resolve(senderReps)
 
 	// If the updater is not among the most reputable peers,
 	// then return as they are not reputable enough to have
 	// their updates taken into consideration
+	updaterRep, found := table.isTopKey(kind, sender, table.config.RequestPeerCount)
 	if !found {
+		table.mutex.Unlock()
 		return
 	}
 
 	// Compute the update weight based on the update
-	// weight limit and the updater's reputation
-	updateWeight := updaterRep * UPDATE_WEIGHT_LIMIT
+	// weight limit and the updater's score, falling back
+	// to their reputation of the kind of the update
+	if score, ok := table.score(PURPOSE_UPDATE, sender); ok {
+		updaterRep = score
+	}
+	updateWeight := updaterRep * table.config.UpdateWeightLimit
 	oneMinusUpdateWeight := 1 - updateWeight
 
-	table.mutex.Lock()
-
 	// Loop through the updater's reputations and use
 	// them to update the reputations in this table
+	refReps := table.reps(kind)
 	for peer, rep := range senderReps {
 		if oldRep, ok := refReps[peer]; ok {
-			refReps[peer] = updateWeight*rep + oneMinusUpdateWeight*oldRep
+			table.setRep(kind, peer, updateWeight*rep+oneMinusUpdateWeight*oldRep)
+			table.audit(kind, peer, CAUSE_UPDATE, table.sigHandle(sender), updateWeight, oldRep, refReps[peer])
 		}
 	}
 
@@ -200,20 +307,20 @@ func averageHammingDistance(reps1, reps2 ReputationMap) float32 {
  */
 func (table *ReputationTable) updateUpdaterReputation(update *RepUpdate, updater string) {
 
-	// The peer->rep map and the one in the update
+	// The kind of reputation and the map in the update
 	// to use for when computing the Hamming distance
-	var refReps ReputationMap
+	var kind RepKind
 	var updateReps ReputationMap
 
 	// If the signature-based map in the update is
 	// non-nil, then it is a signature-based update
 	if update.SigReps != nil {
-		refReps = table.sigReps
+		kind = SIG_REP
 		updateReps = update.SigReps
 		// Otherwise, if the contribution-based map in the update
 		// is non-nil, then it is a contribution-based update
 	} else if update.ContribReps != nil {
-		refReps = table.contribReps
+		kind = CONTRIB_REP
 		updateReps = update.ContribReps
 		// Otherwise, return as the update is invalid
 	} else {
@@ -222,11 +329,17 @@ func (table *ReputationTable) updateUpdaterReputation(update *RepUpdate, updater
 
 	table.mutex.Lock()
 
+	refReps := table.reps(kind)
+
 	// Compute the average Hamming distance
-	avgDist := averageHammingDistance(refReps, updateReps)
+	avgDist := averageHammingDistance(refReps, table.resolve(updateReps))
 
 	// Update the updater's reputation
-	refReps[updater] *= 1 - avgDist*UPDATER_DECREASE_LIMIT
+	key := table.key(updater)
+	if rep, ok := refReps[key]; ok {
+		table.setRep(kind, key, rep*(1-avgDist*table.config.UpdaterDecreaseLimit))
+		table.audit(kind, key, CAUSE_UPDATE_DISTANCE, "", avgDist, rep, refReps[key])
+	}
 
 	table.mutex.Unlock()
 